@@ -12,7 +12,6 @@ import (
 	"sort"
 	"sync"
 
-	"github.com/mannheim-network/go-ipfs-encryptor/spacex"
 	multierror "github.com/hashicorp/go-multierror"
 
 	version "github.com/ipfs/go-ipfs"
@@ -24,6 +23,7 @@ import (
 	commands "github.com/ipfs/go-ipfs/core/commands"
 	corehttp "github.com/ipfs/go-ipfs/core/corehttp"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	corenode "github.com/ipfs/go-ipfs/core/node"
 	libp2p "github.com/ipfs/go-ipfs/core/node/libp2p"
 	nodeMount "github.com/ipfs/go-ipfs/fuse/node"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
@@ -439,20 +439,25 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 
 	// initialize metrics collector
 	prometheus.MustRegister(&corehttp.IpfsNodeCollector{Node: node})
+	prometheus.MustRegister(&corehttp.SpacexCollector{Node: node})
 
 	// start MFS pinning thread
 	startPinMFS(daemonConfigPollInterval, cctx, &ipfsPinMFSNode{node})
 
-	// Set spacex
+	// Report spacex
 	cfg, err := repo.Config()
 	if err != nil {
 		return err
 	}
 
-	if cc, ok := cfg.Datastore.Spec["spacex"]; ok {
-		if len(cc.(string)) != 0 {
-			spacex.Worker.SetUrl(cc.(string))
-			fmt.Printf("Spacex storage url: %s\n", cc.(string))
+	if url, err := corenode.SpacexURL(cfg); err == nil && len(url) != 0 {
+		fmt.Printf("Spacex storage url: %s\n", url)
+	}
+	for _, rs := range node.SealRecovery {
+		if rs.Resumed {
+			fmt.Printf("Resuming interrupted seal of %s\n", rs.Root)
+		} else {
+			fmt.Printf("Aborted interrupted seal of %s\n", rs.Root)
 		}
 	}
 
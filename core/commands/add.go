@@ -38,6 +38,7 @@ const (
 	onlyHashOptionName    = "only-hash"
 	chunkerOptionName     = "chunker"
 	pinOptionName         = "pin"
+	sealOptionName        = "seal"
 	rawLeavesOptionName   = "raw-leaves"
 	noCopyOptionName      = "nocopy"
 	fstoreCacheOptionName = "fscache"
@@ -107,6 +108,13 @@ You can now check what blocks have been created by:
   QmerURi9k4XzKCaaPbsK6BL5pMEjF7PGphjDvkkjDtsVf3 868
   QmQB28iwSriSUSMqG2nXDTLtdPHgWb4rebBrU7Q1j4vxPv 338
 
+The seal option, '--seal', seals the added blocks to the sWorker once the
+content is added and pinned, whatever the seal policy says, and replaces
+them in the repo with sealed stubs. It requires sealing to be configured,
+see 'ipfs spacex', and the content to be pinned. Until the seal completes,
+the added blocks are stored in plaintext in the repo: a node stopped in
+between resumes the seal once it starts again.
+
 Finally, a note on hash determinism. While not guaranteed, adding the same
 file/directory with the same flags will almost always result in the same output
 hash. However, almost all of the flags provided by this command (other than pin,
@@ -133,6 +141,7 @@ only-hash, and progress/status related flags) will change the final hash.
 		cmds.BoolOption(wrapOptionName, "w", "Wrap files with a directory object."),
 		cmds.StringOption(chunkerOptionName, "s", "Chunking algorithm, size-[bytes], rabin-[min]-[avg]-[max] or buzhash").WithDefault("size-262144"),
 		cmds.BoolOption(pinOptionName, "Pin this object when adding.").WithDefault(true),
+		cmds.BoolOption(sealOptionName, "Seal the added blocks to the sWorker. Requires --pin."),
 		cmds.BoolOption(rawLeavesOptionName, "Use raw blocks for leaf nodes. (experimental)"),
 		cmds.BoolOption(noCopyOptionName, "Add the file using filestore. Implies raw-leaves. (experimental)"),
 		cmds.BoolOption(fstoreCacheOptionName, "Check the filestore for pre-existing blocks. (experimental)"),
@@ -180,6 +189,20 @@ only-hash, and progress/status related flags) will change the final hash.
 		hashFunStr, _ := req.Options[hashOptionName].(string)
 		inline, _ := req.Options[inlineOptionName].(bool)
 		inlineLimit, _ := req.Options[inlineLimitOptionName].(int)
+		seal, _ := req.Options[sealOptionName].(bool)
+
+		if seal {
+			if !dopin || hash || nocopy {
+				return fmt.Errorf("--%s requires --%s and can't be used with --%s or --%s", sealOptionName, pinOptionName, onlyHashOptionName, noCopyOptionName)
+			}
+			n, err := cmdenv.GetNode(env)
+			if err != nil {
+				return err
+			}
+			if !n.SealBackend.Enabled() {
+				return errors.New("sealing isn't configured, see 'ipfs spacex set-url'")
+			}
+		}
 
 		hashFunCode, ok := mh.Names[strings.ToLower(hashFunStr)]
 		if !ok {
@@ -210,6 +233,7 @@ only-hash, and progress/status related flags) will change the final hash.
 			options.Unixfs.HashOnly(hash),
 			options.Unixfs.FsCache(fscache),
 			options.Unixfs.Nocopy(nocopy),
+			options.Unixfs.Seal(seal),
 
 			options.Unixfs.Progress(progress),
 			options.Unixfs.Silent(silent),
@@ -198,6 +198,7 @@ func TestCommands(t *testing.T) {
 		"/refs",
 		"/refs/local",
 		"/repo",
+		"/repo/compact-sealed",
 		"/repo/fsck",
 		"/repo/gc",
 		"/repo/stat",
@@ -210,6 +211,16 @@ func TestCommands(t *testing.T) {
 		"/stats/bw",
 		"/stats/dht",
 		"/stats/repo",
+		"/spacex",
+		"/spacex/ls",
+		"/spacex/policy",
+		"/spacex/policy/explain",
+		"/spacex/policy/reload",
+		"/spacex/release",
+		"/spacex/set-url",
+		"/spacex/stat",
+		"/spacex/status",
+		"/spacex/unseal-root",
 		"/swarm",
 		"/swarm/addrs",
 		"/swarm/addrs/listen",
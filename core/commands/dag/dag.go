@@ -18,6 +18,7 @@ import (
 	cidenc "github.com/ipfs/go-cidutil/cidenc"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	files "github.com/ipfs/go-ipfs-files"
+	"github.com/ipfs/go-ipfs-pinner/dspinner"
 	ipld "github.com/ipfs/go-ipld-format"
 	mdag "github.com/ipfs/go-merkledag"
 	traverse "github.com/ipfs/go-merkledag/traverse"
@@ -38,6 +39,7 @@ const (
 	progressOptionName = "progress"
 	silentOptionName   = "silent"
 	pinRootsOptionName = "pin-roots"
+	sealOptionName     = "seal"
 )
 
 var DagCmd = &cmds.Command{
@@ -301,6 +303,13 @@ Note:
   currently present in the blockstore does not represent a complete DAG,
   pinning of that individual root will fail.
 
+  With --seal, the DAGs of the pinned roots are sealed to the sWorker once
+  pinned, whatever the seal policy says and even if they were pinned
+  already, and their blocks replaced in the repo with sealed stubs. Blocks
+  which aren't reachable from a root stay in plaintext. The imported blocks
+  are stored in plaintext until the seal of their root completes: a node
+  stopped in between resumes the seal once it starts again.
+
 Maximum supported CAR version: 1
 `,
 	},
@@ -310,6 +319,7 @@ Maximum supported CAR version: 1
 	Options: []cmds.Option{
 		cmds.BoolOption(silentOptionName, "No output."),
 		cmds.BoolOption(pinRootsOptionName, "Pin optional roots listed in the .car headers after importing.").WithDefault(true),
+		cmds.BoolOption(sealOptionName, "Seal the blocks of the pinned roots to the sWorker. Requires --pin-roots."),
 	},
 	Type: CarImportOutput{},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
@@ -342,6 +352,21 @@ Maximum supported CAR version: 1
 		defer unlocker.Unlock()
 
 		doPinRoots, _ := req.Options[pinRootsOptionName].(bool)
+		seal, _ := req.Options[sealOptionName].(bool)
+
+		var sealer dspinner.RootSealer
+		if seal {
+			if !doPinRoots {
+				return fmt.Errorf("--%s requires --%s", sealOptionName, pinRootsOptionName)
+			}
+			if !node.SealBackend.Enabled() {
+				return errors.New("sealing isn't configured, see 'ipfs spacex set-url'")
+			}
+			var ok bool
+			if sealer, ok = node.Pinning.(dspinner.RootSealer); !ok {
+				return errors.New("the pinner of this node can't seal")
+			}
+		}
 
 		retCh := make(chan importResult, 1)
 		go importWorker(req, res, api, retCh)
@@ -391,6 +416,10 @@ Maximum supported CAR version: 1
 					ret.PinErrorMsg = err.Error()
 				} else if err := node.Pinning.Flush(req.Context); err != nil {
 					ret.PinErrorMsg = err.Error()
+				} else if sealer != nil {
+					if err := sealer.SealRoot(req.Context, c, nil); err != nil {
+						ret.PinErrorMsg = err.Error()
+					}
 				}
 
 				if ret.PinErrorMsg != "" {
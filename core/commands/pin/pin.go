@@ -8,16 +8,19 @@ import (
 	"os"
 	"time"
 
+	humanize "github.com/dustin/go-humanize"
 	bserv "github.com/ipfs/go-blockservice"
 	cid "github.com/ipfs/go-cid"
 	cidenc "github.com/ipfs/go-cidutil/cidenc"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	offline "github.com/ipfs/go-ipfs-exchange-offline"
 	dag "github.com/ipfs/go-merkledag"
+	dagutils "github.com/ipfs/go-merkledag/dagutils"
 	verifcid "github.com/ipfs/go-verifcid"
 	coreiface "github.com/ipfs/interface-go-ipfs-core"
 	options "github.com/ipfs/interface-go-ipfs-core/options"
 	"github.com/ipfs/interface-go-ipfs-core/path"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 
 	core "github.com/ipfs/go-ipfs/core"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
@@ -46,6 +49,15 @@ type PinOutput struct {
 type AddPinOutput struct {
 	Pins     []string
 	Progress int `json:",omitempty"`
+
+	// Seal tells how much of the pinned DAGs was sealed so far. It is only
+	// set with the progress option, when sealing is enabled.
+	Seal *spacex.SealCounts `json:",omitempty"`
+
+	// Unsealed lists, in the final output, the pinned roots which didn't
+	// reach sealed storage: the seal journal has no completed seal of them,
+	// as the seal policy kept them in plain or their seal failed.
+	Unsealed []string `json:",omitempty"`
 }
 
 const (
@@ -57,6 +69,15 @@ var addPinCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline:          "Pin objects to local storage.",
 		ShortDescription: "Stores an IPFS object(s) from a given path locally to disk.",
+		LongDescription: `
+Stores an IPFS object(s) from a given path locally to disk.
+
+With --progress, the number of nodes fetched or processed so far is reported
+while pinning. When sealing is enabled, so are the blocks and bytes sealed,
+the seal errors and the sWorker retries. The final output then lists the
+recursive pins which didn't reach sealed storage, pinned just now or before:
+those kept in plain by the seal policy, and those whose seal failed.
+`,
 	},
 
 	Arguments: []cmds.Argument{
@@ -98,6 +119,22 @@ var addPinCmd = &cmds.Command{
 		v := new(dag.ProgressTracker)
 		ctx := v.DeriveContext(req.Context)
 
+		var sp *spacex.SealProgress
+		var journal *spacex.SealJournal
+		if n, err := cmdenv.GetNode(env); err == nil && n.SealBackend != nil && n.SealBackend.Enabled() {
+			sp = spacex.NewSealProgress(n.SealBackend)
+			ctx = spacex.WithSealProgress(ctx, sp)
+			journal = spacex.NewSealJournal(n.Repo.Datastore())
+		}
+		progress := func() *AddPinOutput {
+			out := &AddPinOutput{Progress: v.Value()}
+			if sp != nil {
+				counts := sp.Value()
+				out.Seal = &counts
+			}
+			return out
+		}
+
 		type pinResult struct {
 			pins []string
 			err  error
@@ -120,13 +157,23 @@ var addPinCmd = &cmds.Command{
 				}
 
 				if pv := v.Value(); pv != 0 {
-					if err := res.Emit(&AddPinOutput{Progress: v.Value()}); err != nil {
+					if err := res.Emit(progress()); err != nil {
 						return err
 					}
 				}
-				return res.Emit(&AddPinOutput{Pins: val.pins})
+				out := &AddPinOutput{Pins: val.pins}
+				if sp != nil {
+					out = progress()
+					out.Pins = val.pins
+					if recursive {
+						if out.Unsealed, err = unsealedPins(journal, val.pins); err != nil {
+							return err
+						}
+					}
+				}
+				return res.Emit(out)
 			case <-ticker.C:
-				if err := res.Emit(&AddPinOutput{Progress: v.Value()}); err != nil {
+				if err := res.Emit(progress()); err != nil {
 					return err
 				}
 			case <-ctx.Done():
@@ -169,8 +216,15 @@ var addPinCmd = &cmds.Command{
 				}
 				if out.Pins == nil {
 					// this can only happen if the progress option is set
-					fmt.Fprintf(os.Stderr, "Fetched/Processed %d nodes\r", out.Progress)
+					if out.Seal != nil {
+						fmt.Fprintf(os.Stderr, "Fetched/Processed %d nodes, sealed %d (%s)\r", out.Progress, out.Seal.Blocks, humanize.Bytes(out.Seal.Bytes))
+					} else {
+						fmt.Fprintf(os.Stderr, "Fetched/Processed %d nodes\r", out.Progress)
+					}
 				} else {
+					if out.Seal != nil {
+						printSealSummary(os.Stderr, out)
+					}
 					err = re.Emit(out)
 					if err != nil {
 						return err
@@ -181,6 +235,43 @@ var addPinCmd = &cmds.Command{
 	},
 }
 
+// unsealedPins returns the pins whose last seal didn't complete according
+// to journal.
+func unsealedPins(journal *spacex.SealJournal, pins []string) ([]string, error) {
+	var unsealed []string
+	for _, p := range pins {
+		c, err := cid.Decode(p)
+		if err != nil {
+			return nil, err
+		}
+		sealed, err := journal.Sealed(c)
+		if err != nil {
+			return nil, err
+		}
+		if !sealed {
+			unsealed = append(unsealed, p)
+		}
+	}
+	return unsealed, nil
+}
+
+// printSealSummary tells how many of the blocks of a pin were sealed, and
+// which pins didn't reach sealed storage.
+func printSealSummary(w io.Writer, out *AddPinOutput) {
+	s := out.Seal
+	fmt.Fprintf(w, "\nFetched/Processed %d nodes: %d sealed (%s), %d kept in plain\n",
+		out.Progress, s.Blocks, humanize.Bytes(s.Bytes), s.Plain)
+	for _, p := range out.Unsealed {
+		fmt.Fprintf(w, "not sealed: %s\n", p)
+	}
+	if s.Errors > 0 || s.Retries > 0 {
+		fmt.Fprintf(w, "%d seal errors, %d sWorker retries\n", s.Errors, s.Retries)
+	}
+	if s.LastError != "" {
+		fmt.Fprintf(w, "last seal error: %s\n", s.LastError)
+	}
+}
+
 func pinAddMany(ctx context.Context, api coreiface.CoreAPI, enc cidenc.Encoder, paths []string, recursive bool) ([]string, error) {
 	added := make([]string, len(paths))
 	for i, b := range paths {
@@ -260,9 +351,11 @@ collected if needed. (By default, recursively. Use -r=false for direct pins.)
 }
 
 const (
-	pinTypeOptionName   = "type"
-	pinQuietOptionName  = "quiet"
-	pinStreamOptionName = "stream"
+	pinTypeOptionName       = "type"
+	pinQuietOptionName      = "quiet"
+	pinStreamOptionName     = "stream"
+	pinSealStatusOptionName = "seal-status"
+	pinSealedOptionName     = "sealed"
 )
 
 var listPinCmd = &cmds.Command{
@@ -306,6 +399,22 @@ Example:
 	QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN direct
 	$ ipfs pin ls QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN
 	QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN direct
+
+Use --seal-status to also tell how the DAG of each recursive pin is stored.
+Every block is read, which takes time on large repos. The state is one of:
+    * "plain": no block is sealed.
+    * "sealing": the pin is being sealed.
+    * "sealed": all the blocks are sealed, but the ones the sealing policy
+      keeps in plain.
+    * "partial": some blocks are sealed, the missing ones are counted, as
+      are the sealed ones which can't be unsealed to walk their links.
+    * "failed": the last seal of the pin failed, its error is reported.
+The sealed bytes and the number of replicas held by the sWorkers are listed
+along.
+
+Use --sealed=true to only list the recursive pins which are fully sealed, or
+--sealed=false to only list the others. It implies --seal-status; direct and
+indirect pins are left out.
 `,
 	},
 
@@ -316,6 +425,8 @@ Example:
 		cmds.StringOption(pinTypeOptionName, "t", "The type of pinned keys to list. Can be \"direct\", \"indirect\", \"recursive\", or \"all\".").WithDefault("all"),
 		cmds.BoolOption(pinQuietOptionName, "q", "Write just hashes of objects."),
 		cmds.BoolOption(pinStreamOptionName, "s", "Enable streaming of pins as they are discovered."),
+		cmds.BoolOption(pinSealStatusOptionName, "Include the seal status of recursive pins."),
+		cmds.BoolOption(pinSealedOptionName, "Only list the recursive pins which are, or are not, fully sealed. Implies --seal-status."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -325,6 +436,8 @@ Example:
 
 		typeStr, _ := req.Options[pinTypeOptionName].(string)
 		stream, _ := req.Options[pinStreamOptionName].(bool)
+		withSeal, _ := req.Options[pinSealStatusOptionName].(bool)
+		sealed, filterSealed := req.Options[pinSealedOptionName].(bool)
 
 		switch typeStr {
 		case "all", "direct", "indirect", "recursive":
@@ -339,15 +452,38 @@ Example:
 		if !stream {
 			emit = func(v interface{}) error {
 				obj := v.(*PinLsOutputWrapper)
-				lgcList[obj.PinLsObject.Cid] = PinLsType{Type: obj.PinLsObject.Type}
+				lgcList[obj.PinLsObject.Cid] = PinLsType{Type: obj.PinLsObject.Type, Seal: obj.PinLsObject.Seal}
 				return nil
 			}
 		}
 
+		var sealStatus func(c cid.Cid) (*spacex.SealStatus, error)
+		if withSeal || filterSealed {
+			n, err := cmdenv.GetNode(env)
+			if err != nil {
+				return err
+			}
+			getter := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+			sealStatus = func(c cid.Cid) (*spacex.SealStatus, error) {
+				return dagutils.SealStatus(req.Context, n.Repo.Datastore(), getter, n.SealPolicy, c)
+			}
+
+			if filterSealed {
+				next := emit
+				emit = func(v interface{}) error {
+					seal := v.(*PinLsOutputWrapper).PinLsObject.Seal
+					if seal == nil || (seal.State == spacex.SealStateSealed) != sealed {
+						return nil
+					}
+					return next(v)
+				}
+			}
+		}
+
 		if len(req.Arguments) > 0 {
-			err = pinLsKeys(req, typeStr, api, emit)
+			err = pinLsKeys(req, typeStr, api, sealStatus, emit)
 		} else {
-			err = pinLsAll(req, typeStr, api, emit)
+			err = pinLsAll(req, typeStr, api, sealStatus != nil, emit)
 		}
 		if err != nil {
 			return err
@@ -382,7 +518,7 @@ Example:
 				if quiet {
 					fmt.Fprintf(w, "%s\n", out.PinLsObject.Cid)
 				} else {
-					fmt.Fprintf(w, "%s %s\n", out.PinLsObject.Cid, out.PinLsObject.Type)
+					fmt.Fprintf(w, "%s %s%s\n", out.PinLsObject.Cid, out.PinLsObject.Type, sealSummary(out.PinLsObject.Seal))
 				}
 				return nil
 			}
@@ -391,7 +527,7 @@ Example:
 				if quiet {
 					fmt.Fprintf(w, "%s\n", k)
 				} else {
-					fmt.Fprintf(w, "%s %s\n", k, v.Type)
+					fmt.Fprintf(w, "%s %s%s\n", k, v.Type, sealSummary(v.Seal))
 				}
 			}
 
@@ -413,18 +549,42 @@ type PinLsList struct {
 	Keys map[string]PinLsType
 }
 
-// PinLsType contains the type of a pin
+// PinLsType contains the type of a pin, and the seal status of recursive
+// pins if asked for
 type PinLsType struct {
 	Type string
+	Seal *spacex.SealStatus `json:",omitempty"`
 }
 
 // PinLsObject contains the description of a pin
 type PinLsObject struct {
-	Cid  string `json:",omitempty"`
-	Type string `json:",omitempty"`
+	Cid  string             `json:",omitempty"`
+	Type string             `json:",omitempty"`
+	Seal *spacex.SealStatus `json:",omitempty"`
+}
+
+// sealSummary describes s in the text output of pin ls.
+func sealSummary(s *spacex.SealStatus) string {
+	if s == nil {
+		return ""
+	}
+	state := string(s.State)
+	switch s.State {
+	case spacex.SealStatePartial:
+		state = fmt.Sprintf("%s (%d missing)", state, s.Missing)
+	case spacex.SealStateFailed:
+		state = fmt.Sprintf("%s (%s)", state, s.LastError)
+	}
+	if s.Unreadable > 0 {
+		state = fmt.Sprintf("%s, %d unreadable", state, s.Unreadable)
+	}
+	return fmt.Sprintf(" %s, %d/%d blocks sealed (%s), %d replicas",
+		state, s.SealedBlocks, s.Blocks, humanize.Bytes(s.SealedBytes), s.Replicas)
 }
 
-func pinLsKeys(req *cmds.Request, typeStr string, api coreiface.CoreAPI, emit func(value interface{}) error) error {
+// pinLsKeys lists the pins among the arguments. If sealStatus is set, it
+// tells the seal status of the recursive ones.
+func pinLsKeys(req *cmds.Request, typeStr string, api coreiface.CoreAPI, sealStatus func(c cid.Cid) (*spacex.SealStatus, error), emit func(value interface{}) error) error {
 	enc, err := cmdenv.GetCidEncoder(req)
 	if err != nil {
 		return err
@@ -462,10 +622,18 @@ func pinLsKeys(req *cmds.Request, typeStr string, api coreiface.CoreAPI, emit fu
 			pinType = "indirect through " + pinType
 		}
 
+		var seal *spacex.SealStatus
+		if sealStatus != nil && pinType == "recursive" {
+			if seal, err = sealStatus(rp.Cid()); err != nil {
+				return err
+			}
+		}
+
 		err = emit(&PinLsOutputWrapper{
 			PinLsObject: PinLsObject{
 				Type: pinType,
 				Cid:  enc.Encode(rp.Cid()),
+				Seal: seal,
 			},
 		})
 		if err != nil {
@@ -476,7 +644,9 @@ func pinLsKeys(req *cmds.Request, typeStr string, api coreiface.CoreAPI, emit fu
 	return nil
 }
 
-func pinLsAll(req *cmds.Request, typeStr string, api coreiface.CoreAPI, emit func(value interface{}) error) error {
+// pinLsAll lists all the pins of typeStr, with the seal status of the
+// recursive ones if withSeal is set.
+func pinLsAll(req *cmds.Request, typeStr string, api coreiface.CoreAPI, withSeal bool, emit func(value interface{}) error) error {
 	enc, err := cmdenv.GetCidEncoder(req)
 	if err != nil {
 		return err
@@ -494,19 +664,20 @@ func pinLsAll(req *cmds.Request, typeStr string, api coreiface.CoreAPI, emit fun
 		panic("unhandled pin type")
 	}
 
-	pins, err := api.Pin().Ls(req.Context, opt)
+	pins, err := api.Pin().Ls(req.Context, opt, options.Pin.Ls.SealStatus(withSeal))
 	if err != nil {
 		return err
 	}
 
 	for p := range pins {
 		if p.Err() != nil {
-			return err
+			return p.Err()
 		}
 		err = emit(&PinLsOutputWrapper{
 			PinLsObject: PinLsObject{
 				Type: p.Type(),
 				Cid:  enc.Encode(p.Path().Cid()),
+				Seal: p.Seal(),
 			},
 		})
 		if err != nil {
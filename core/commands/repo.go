@@ -14,9 +14,11 @@ import (
 	humanize "github.com/dustin/go-humanize"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	"github.com/ipfs/go-ipfs/core/node"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
 	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 )
@@ -39,6 +41,8 @@ var RepoCmd = &cmds.Command{
 		"fsck":    repoFsckCmd,
 		"version": repoVersionCmd,
 		"verify":  repoVerifyCmd,
+
+		"compact-sealed": repoCompactSealedCmd,
 	},
 }
 
@@ -51,6 +55,7 @@ type GcResult struct {
 const (
 	repoStreamErrorsOptionName = "stream-errors"
 	repoQuietOptionName        = "quiet"
+	repoSealedOptionName       = "sealed"
 )
 
 var repoGcCmd = &cmds.Command{
@@ -143,6 +148,10 @@ stored objects. It outputs:
 RepoSize        int Size in bytes that the repo is currently taking.
 StorageMax      string Maximum datastore size (from configuration)
 NumObjects      int Number of objects in the local repo.
+LocalSize       int Size in bytes of the blocks stored in plain.
+SealedSize      int Size in bytes of the sealed blocks, not held locally.
+SealedStubSize  int Size in bytes of the stubs of the sealed blocks.
+NumSealed       int Number of sealed blocks.
 RepoPath        string The path to the repo being currently used.
 Version         string The repo version.
 `,
@@ -201,6 +210,10 @@ Version         string The repo version.
 			printSize("StorageMax", stat.StorageMax)
 
 			if !sizeOnly {
+				printSize("LocalSize", stat.LocalSize)
+				printSize("SealedSize", stat.SealedSize)
+				printSize("SealedStubSize", stat.SealedStubSize)
+				fmt.Fprintf(wtr, "NumSealed:\t%d\n", stat.NumSealed)
 				fmt.Fprintf(wtr, "RepoPath:\t%s\n", stat.RepoPath)
 				fmt.Fprintf(wtr, "Version:\t%s\n", stat.Version)
 			}
@@ -235,12 +248,23 @@ type VerifyProgress struct {
 	Progress int
 }
 
-func verifyWorkerRun(ctx context.Context, wg *sync.WaitGroup, keys <-chan cid.Cid, results chan<- string, bs bstore.Blockstore) {
+func verifyWorkerRun(ctx context.Context, wg *sync.WaitGroup, keys <-chan cid.Cid, results chan<- string, bs bstore.Blockstore, dstore ds.Datastore) {
 	defer wg.Done()
 
 	for k := range keys {
 		_, err := bs.Get(k)
 		if err != nil {
+			// Sealed blocks only keep a stub, their replicas are checked
+			// with --sealed.
+			if si, serr := bstore.GetSealedInfo(dstore, k); serr == nil && si != nil {
+				select {
+				case results <- "":
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+
 			select {
 			case results <- fmt.Sprintf("block %s was corrupt (%s)", k, err):
 			case <-ctx.Done():
@@ -258,7 +282,7 @@ func verifyWorkerRun(ctx context.Context, wg *sync.WaitGroup, keys <-chan cid.Ci
 	}
 }
 
-func verifyResultChan(ctx context.Context, keys <-chan cid.Cid, bs bstore.Blockstore) <-chan string {
+func verifyResultChan(ctx context.Context, keys <-chan cid.Cid, bs bstore.Blockstore, dstore ds.Datastore) <-chan string {
 	results := make(chan string)
 
 	go func() {
@@ -268,7 +292,7 @@ func verifyResultChan(ctx context.Context, keys <-chan cid.Cid, bs bstore.Blocks
 
 		for i := 0; i < runtime.NumCPU()*2; i++ {
 			wg.Add(1)
-			go verifyWorkerRun(ctx, &wg, keys, results, bs)
+			go verifyWorkerRun(ctx, &wg, keys, results, bs, dstore)
 		}
 
 		wg.Wait()
@@ -280,6 +304,16 @@ func verifyResultChan(ctx context.Context, keys <-chan cid.Cid, bs bstore.Blocks
 var repoVerifyCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Verify all blocks in repo are not corrupted.",
+		ShortDescription: `
+'ipfs repo verify' checks that the blocks stored in the repo match their
+hash. Sealed blocks are skipped, use --sealed to also check their replicas:
+the replicas the sWorkers don't have anymore are pruned and the blocks left
+without any are fetched from the network and sealed again under the pinned
+root they were sealed under.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(repoSealedOptionName, "Also check the replicas of sealed blocks."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		nd, err := cmdenv.GetNode(env)
@@ -287,6 +321,11 @@ var repoVerifyCmd = &cmds.Command{
 			return err
 		}
 
+		sealed, _ := req.Options[repoSealedOptionName].(bool)
+		if sealed && !nd.SealBackend.Enabled() {
+			return errors.New("sealing is disabled, set an sWorker url with 'ipfs spacex set-url'")
+		}
+
 		bs := bstore.NewBlockstore(nd.Repo.Datastore())
 		bs.HashOnRead(true)
 
@@ -296,7 +335,7 @@ var repoVerifyCmd = &cmds.Command{
 			return err
 		}
 
-		results := verifyResultChan(req.Context, keys, bs)
+		results := verifyResultChan(req.Context, keys, bs, nd.Repo.Datastore())
 
 		var fails int
 		var i int
@@ -313,6 +352,52 @@ var repoVerifyCmd = &cmds.Command{
 			}
 		}
 
+		if sealed {
+			scrubbed, err := nd.Scrubber.Scrub(req.Context, 0)
+			if err != nil {
+				return err
+			}
+
+			var checked, pruned, resealed int
+			for r := range scrubbed {
+				var msg string
+				switch {
+				case r.Err != nil && r.Replicas == 0:
+					msg = fmt.Sprintf("sealed block %s was corrupt (all replicas lost, %s)", r.Cid, r.Err)
+				case r.Err != nil:
+					msg = fmt.Sprintf("sealed block %s could not be checked (%s)", r.Cid, r.Err)
+				case r.Resealed:
+					msg = fmt.Sprintf("sealed block %s lost all its replicas, sealed it again", r.Cid)
+				case len(r.Pruned) > 0:
+					msg = fmt.Sprintf("sealed block %s: pruned %d dead replicas, %d left", r.Cid, len(r.Pruned), r.Replicas)
+				}
+				if r.Err != nil {
+					fails++
+				}
+				pruned += len(r.Pruned)
+				if r.Resealed {
+					resealed++
+				}
+				if msg != "" {
+					if err := res.Emit(&VerifyProgress{Msg: msg}); err != nil {
+						return err
+					}
+				}
+				checked++
+				if err := res.Emit(&VerifyProgress{Progress: i + checked}); err != nil {
+					return err
+				}
+			}
+			if err := req.Context.Err(); err != nil {
+				return err
+			}
+
+			msg := fmt.Sprintf("checked %d sealed blocks: pruned %d replicas, sealed %d blocks again", checked, pruned, resealed)
+			if err := res.Emit(&VerifyProgress{Msg: msg}); err != nil {
+				return err
+			}
+		}
+
 		if fails != 0 {
 			return errors.New("verify complete, some blocks were corrupt")
 		}
@@ -341,6 +426,55 @@ var repoVerifyCmd = &cmds.Command{
 	},
 }
 
+// CompactSealedResult is the result returned by "repo compact-sealed".
+type CompactSealedResult struct {
+	Stubs     int
+	Rewritten int
+	Released  int
+}
+
+var repoCompactSealedCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Drop duplicate and excess replicas from sealed blocks.",
+		ShortDescription: `
+'ipfs repo compact-sealed' rewrites the sealed blocks which point to the same
+replica more than once, or to more replicas than Spacex.MaxReplicas. The
+excess replicas are queued for release by the sWorker. The daemon must not be
+running.
+`,
+	},
+	NoRemote: true,
+	Type:     CompactSealedResult{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return err
+		}
+
+		stats, err := bstore.CompactSealedValues(n.Repo.Datastore(), n.SealBackend, node.MaxReplicas(cfg))
+		if err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, &CompactSealedResult{
+			Stubs:     stats.Stubs,
+			Rewritten: stats.Rewritten,
+			Released:  stats.Released,
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *CompactSealedResult) error {
+			_, err := fmt.Fprintf(w, "checked %d sealed blocks, rewrote %d, released %d replicas\n", out.Stubs, out.Rewritten, out.Released)
+			return err
+		}),
+	},
+}
+
 var repoVersionCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Show the repo version.",
@@ -142,6 +142,7 @@ var rootSubcommands = map[string]*cmds.Command{
 	"p2p":       P2PCmd,
 	"refs":      RefsCmd,
 	"resolve":   ResolveCmd,
+	"spacex":    SpacexCmd,
 	"swarm":     SwarmCmd,
 	"tar":       TarCmd,
 	"file":      unixfs.UnixFSCmd,
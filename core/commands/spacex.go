@@ -0,0 +1,772 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	"github.com/ipfs/go-ipfs/core/node"
+
+	humanize "github.com/dustin/go-humanize"
+	bserv "github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	pin "github.com/ipfs/go-ipfs-pinner"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	dagutils "github.com/ipfs/go-merkledag/dagutils"
+	path "github.com/ipfs/interface-go-ipfs-core/path"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
+)
+
+var SpacexCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Administer block sealing.",
+		ShortDescription: `
+'ipfs spacex' shows and controls how pinned blocks are sealed to the
+sWorker. Sealed blocks are kept in the repo as stubs pointing to one or more
+replicas held by the sWorker.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"status":  spacexStatusCmd,
+		"set-url": spacexSetURLCmd,
+		"ls":      spacexLsCmd,
+		"stat":    spacexStatCmd,
+		"release": spacexReleaseCmd,
+		"policy":  spacexPolicyCmd,
+
+		"unseal-root": spacexUnsealRootCmd,
+	},
+}
+
+// SpacexStatus is the result returned by "spacex status".
+type SpacexStatus struct {
+	Enabled         bool
+	Endpoints       []SpacexEndpoint
+	Sealing         []cid.Cid
+	PendingReleases int
+	ParkedReleases  int
+}
+
+// SpacexEndpoint describes an sWorker in "spacex status". The sWorker set in
+// the datastore spec has no name.
+type SpacexEndpoint struct {
+	Name    string `json:",omitempty"`
+	URL     string
+	Healthy bool
+}
+
+// unwrapBackend returns the backend wrapped by the release queue and the
+// metering of the node.
+func unwrapBackend(backend spacex.SealBackend) spacex.SealBackend {
+	if rq, ok := backend.(*spacex.ReleaseQueue); ok {
+		backend = rq.SealBackend
+	}
+	if mb, ok := backend.(*spacex.MeteredBackend); ok {
+		backend = mb.SealBackend
+	}
+	return backend
+}
+
+// sWorkerOf returns the sWorker behind backend, the one set in the datastore
+// spec if blocks are sealed to a pool, or nil if the node seals to another
+// backend.
+func sWorkerOf(backend spacex.SealBackend) *spacex.SWorker {
+	backend = unwrapBackend(backend)
+	if pool, ok := backend.(*spacex.Pool); ok {
+		backend = pool.Endpoint("")
+	}
+	sw, _ := backend.(*spacex.SWorker)
+	return sw
+}
+
+// endpointsOf lists the sWorkers behind backend.
+func endpointsOf(backend spacex.SealBackend) []SpacexEndpoint {
+	backend = unwrapBackend(backend)
+	if pool, ok := backend.(*spacex.Pool); ok {
+		var out []SpacexEndpoint
+		for _, e := range pool.Endpoints() {
+			sw, ok := e.Backend.(*spacex.SWorker)
+			if !ok || sw.GetUrl() == "" {
+				continue
+			}
+			out = append(out, SpacexEndpoint{Name: e.Name, URL: sw.GetUrl(), Healthy: pool.Healthy(e.Name)})
+		}
+		return out
+	}
+	if sw := sWorkerOf(backend); sw != nil && sw.GetUrl() != "" {
+		return []SpacexEndpoint{{URL: sw.GetUrl(), Healthy: true}}
+	}
+	return nil
+}
+
+var spacexStatusCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the sealing configuration and progress.",
+		ShortDescription: `
+'ipfs spacex status' prints the sWorker endpoints, the roots being sealed and
+the number of replicas waiting to be released. Replicas which still couldn't
+be released after many attempts are given up and counted apart.
+`,
+	},
+	Type: SpacexStatus{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		out := &SpacexStatus{Enabled: n.SealBackend.Enabled()}
+		out.Endpoints = endpointsOf(n.SealBackend)
+
+		out.Sealing, err = spacex.NewSealJournal(n.Repo.Datastore()).Roots()
+		if err != nil {
+			return err
+		}
+
+		if rq, ok := n.SealBackend.(*spacex.ReleaseQueue); ok {
+			pending, err := rq.Pending()
+			if err != nil {
+				return err
+			}
+			out.PendingReleases = len(pending)
+			parked, err := rq.Parked()
+			if err != nil {
+				return err
+			}
+			out.ParkedReleases = len(parked)
+		}
+
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *SpacexStatus) error {
+			enc, err := cmdenv.GetLowLevelCidEncoder(req)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(w, "Enabled: %t\n", out.Enabled)
+			fmt.Fprintf(w, "Endpoints:\n")
+			for _, e := range out.Endpoints {
+				name := e.Name
+				if name == "" {
+					name = "default"
+				}
+				state := "up"
+				if !e.Healthy {
+					state = "down"
+				}
+				fmt.Fprintf(w, "\t%s\t%s\t%s\n", name, e.URL, state)
+			}
+			fmt.Fprintf(w, "Sealing:\n")
+			for _, c := range out.Sealing {
+				fmt.Fprintf(w, "\t%s\n", enc.Encode(c))
+			}
+			fmt.Fprintf(w, "Pending releases: %d\n", out.PendingReleases)
+			if out.ParkedReleases > 0 {
+				fmt.Fprintf(w, "Releases given up: %d\n", out.ParkedReleases)
+			}
+			return nil
+		}),
+	},
+}
+
+var spacexSetURLCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Set the sWorker url.",
+		ShortDescription: `
+'ipfs spacex set-url' stores the sWorker url in the "spacex" entry of the
+datastore spec. A running daemon switches to the new url right away. An empty
+url disables sealing.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("url", true, false, "The sWorker url, e.g. http://127.0.0.1:12222/api/v0."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		u := req.Arguments[0]
+		if u != "" {
+			pu, err := url.Parse(u)
+			if err != nil {
+				return err
+			}
+			if pu.Scheme != "http" && pu.Scheme != "https" {
+				return fmt.Errorf("unsupported sWorker url scheme %q", pu.Scheme)
+			}
+		}
+
+		sw := sWorkerOf(n.SealBackend)
+		if sw == nil {
+			return errors.New("the node doesn't seal to an sWorker")
+		}
+
+		if err := n.Repo.SetConfigKey("Datastore.Spec.spacex", u); err != nil {
+			return err
+		}
+		sw.SetUrl(u)
+		return nil
+	},
+}
+
+// SealedRoot is an entry of the result returned by "spacex ls".
+type SealedRoot struct {
+	Root     cid.Cid
+	Replicas int
+}
+
+// SealedRootList is the result returned by "spacex ls".
+type SealedRootList struct {
+	Roots []SealedRoot
+}
+
+var spacexLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the sealed roots.",
+		ShortDescription: `
+'ipfs spacex ls' lists the roots which have replicas held by the sWorker,
+with the number of replicas of each.
+`,
+	},
+	Type: SealedRootList{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		roots, err := spacex.NewSealJournal(n.Repo.Datastore()).SealedRoots()
+		if err != nil {
+			return err
+		}
+
+		out := &SealedRootList{Roots: make([]SealedRoot, 0, len(roots))}
+		for c, replicas := range roots {
+			out.Roots = append(out.Roots, SealedRoot{Root: c, Replicas: replicas})
+		}
+		sort.Slice(out.Roots, func(i, j int) bool {
+			return out.Roots[i].Root.KeyString() < out.Roots[j].Root.KeyString()
+		})
+
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *SealedRootList) error {
+			enc, err := cmdenv.GetLowLevelCidEncoder(req)
+			if err != nil {
+				return err
+			}
+
+			for _, r := range out.Roots {
+				fmt.Fprintf(w, "%s %d\n", enc.Encode(r.Root), r.Replicas)
+			}
+			return nil
+		}),
+	},
+}
+
+// SealedBlockStat lists the replicas of a sealed block.
+type SealedBlockStat struct {
+	Cid   cid.Cid
+	Paths []string
+}
+
+// SealStat is the result returned by "spacex stat".
+type SealStat struct {
+	Root      cid.Cid
+	Sealed    int
+	Plaintext int
+	Missing   int
+	Blocks    []SealedBlockStat `json:",omitempty"`
+}
+
+var spacexStatCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show how the blocks under a root are stored.",
+		ShortDescription: `
+'ipfs spacex stat' walks the local blocks under <root> and counts the blocks
+stored as sealed stubs, the blocks stored in plain and the blocks missing
+from the repo, then lists the replica paths of every sealed block.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "The path of the root to inspect."),
+	},
+	Type: SealStat{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		rp, err := api.ResolvePath(req.Context, path.New(req.Arguments[0]))
+		if err != nil {
+			return err
+		}
+
+		// Only look at what the repo holds.
+		dserv := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+
+		out := &SealStat{Root: rp.Cid()}
+		getLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+			si, err := bstore.GetSealedInfo(n.Repo.Datastore(), c)
+			if err == bstore.ErrNotFound {
+				out.Missing++
+				return nil, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			if si == nil {
+				out.Plaintext++
+			} else {
+				out.Sealed++
+				paths := make([]string, 0, len(si.Sbs))
+				for _, sb := range si.Sbs {
+					paths = append(paths, sb.Path)
+				}
+				out.Blocks = append(out.Blocks, SealedBlockStat{Cid: c, Paths: paths})
+			}
+
+			nd, err := dserv.Get(ctx, c)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %s", c, err)
+			}
+			return nd.Links(), nil
+		}
+
+		if err := dag.Walk(req.Context, getLinks, out.Root, cid.NewSet().Visit); err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *SealStat) error {
+			enc, err := cmdenv.GetLowLevelCidEncoder(req)
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+			fmt.Fprintf(tw, "Sealed:\t%d\n", out.Sealed)
+			fmt.Fprintf(tw, "Plaintext:\t%d\n", out.Plaintext)
+			fmt.Fprintf(tw, "Missing:\t%d\n", out.Missing)
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+
+			for _, b := range out.Blocks {
+				for _, p := range b.Paths {
+					fmt.Fprintf(w, "%s %s\n", enc.Encode(b.Cid), p)
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+// SealRelease is the result returned by "spacex release".
+type SealRelease struct {
+	Root     cid.Cid
+	Released int
+}
+
+var spacexReleaseCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Release the replicas sealed under a root.",
+		ShortDescription: `
+'ipfs spacex release' queues the replicas sealed under <root> for release by
+the sWorker. <root> must not be pinned: 'ipfs pin rm' keeps the replicas of a
+root recorded, for this command, and leaves them to garbage collection, which
+releases them along with their stubs. Use it only for replicas no other pin
+still reads. Stubs still pointing to the released replicas are dropped on the
+next read or garbage collection.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "The cid of the root to release."),
+	},
+	Type: SealRelease{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		root, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		if !n.SealBackend.Enabled() {
+			return errors.New("sealing is not configured")
+		}
+
+		_, pinned, err := n.Pinning.IsPinnedWithType(req.Context, root, pin.Recursive)
+		if err != nil {
+			return err
+		}
+		if pinned {
+			return fmt.Errorf("%s is pinned, unpin it to release its replicas", root)
+		}
+
+		journal := spacex.NewSealJournal(n.Repo.Datastore())
+		sealing, err := journal.Roots()
+		if err != nil {
+			return err
+		}
+		for _, c := range sealing {
+			if c.Equals(root) {
+				return fmt.Errorf("%s is being sealed", root)
+			}
+		}
+
+		// Replicas are moved aside on unpin, those of a root never pinned,
+		// e.g. since its pin failed, are still recorded under it.
+		paths, err := journal.Replicas(root)
+		if err != nil {
+			return err
+		}
+		unpinned, err := journal.Unpinned(root)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, unpinned...)
+		for _, p := range paths {
+			if err := n.SealBackend.Release(p); err != nil {
+				return err
+			}
+		}
+		if err := journal.DropReplicas(root); err != nil {
+			return err
+		}
+		if err := journal.DropUnpinned(root); err != nil {
+			return err
+		}
+
+		return cmds.EmitOnce(res, &SealRelease{Root: root, Released: len(paths)})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *SealRelease) error {
+			enc, err := cmdenv.GetLowLevelCidEncoder(req)
+			if err != nil {
+				return err
+			}
+
+			_, err = fmt.Fprintf(w, "released %d replicas of %s\n", out.Released, enc.Encode(out.Root))
+			return err
+		}),
+	},
+}
+
+// UnsealProgress is the result returned by "spacex unseal-root". It is
+// emitted as blocks get unsealed, with Done set once a root is through.
+type UnsealProgress struct {
+	Root      cid.Cid
+	Blocks    int
+	Bytes     uint64
+	Released  int
+	Errors    int
+	LastError string `json:",omitempty"`
+	Done      bool
+}
+
+const unsealRateOptionName = "rate"
+
+var spacexUnsealRootCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Move sealed roots back to the local blockstore.",
+		ShortDescription: `
+'ipfs spacex unseal-root' walks the DAG of each <root>, reads every sealed
+block back from the sWorker, verifies it and stores it in plain in place of
+its stub, then releases its replicas. Use it before decommissioning an
+sWorker. Without a root, the unseals interrupted earlier are resumed.
+`,
+		LongDescription: `
+'ipfs spacex unseal-root' walks the DAG of each <root>, reads every sealed
+block back from the sWorker, verifies it and stores it in plain in place of
+its stub, then releases its replicas. Use it before decommissioning an
+sWorker.
+
+Roots are recorded in the repo until all their blocks are in plain, so an
+interrupted or failed unseal can be resumed by running the command again,
+with no root to resume all of them. Blocks already in plain are skipped.
+Once a root is through, the replicas still recorded under it are released
+too. Blocks shared with other sealed roots are unsealed for all of them.
+
+--rate limits how many sealed blocks are read back per second, to spare the
+sWorker; 0 means no limit.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", false, true, "The cids of the roots to unseal."),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption(unsealRateOptionName, "Maximum number of sealed blocks to unseal per second.").WithDefault(0),
+	},
+	Type: UnsealProgress{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !n.SealBackend.Enabled() {
+			return errors.New("sealing is not configured")
+		}
+		rate, _ := req.Options[unsealRateOptionName].(int)
+		if rate < 0 {
+			return errors.New("rate must not be negative")
+		}
+
+		unsealer := dagutils.NewUnsealer(n.Repo.Datastore(), n.DAG, n.SealBackend, n.GCLocker)
+
+		var roots []cid.Cid
+		for _, arg := range req.Arguments {
+			c, err := cid.Decode(arg)
+			if err != nil {
+				return err
+			}
+			roots = append(roots, c)
+		}
+		if len(roots) == 0 {
+			if roots, err = unsealer.Pending(); err != nil {
+				return err
+			}
+		}
+
+		failed := 0
+		for _, root := range roots {
+			results, err := unsealer.Unseal(req.Context, root, rate)
+			if err != nil {
+				return fmt.Errorf("%s: %s", root, err)
+			}
+
+			out := &UnsealProgress{Root: root}
+			ticker := time.NewTicker(500 * time.Millisecond)
+		loop:
+			for {
+				select {
+				case r, ok := <-results:
+					if !ok {
+						break loop
+					}
+					if r.Err != nil {
+						out.Errors++
+						out.LastError = fmt.Sprintf("%s: %s", r.Cid, r.Err)
+					}
+					if r.Unsealed {
+						out.Blocks++
+						out.Bytes += uint64(r.Size)
+					}
+					out.Released += r.Released
+				case <-ticker.C:
+					if err := res.Emit(out); err != nil {
+						ticker.Stop()
+						return err
+					}
+				}
+			}
+			ticker.Stop()
+			if err := req.Context.Err(); err != nil {
+				return err
+			}
+
+			out.Done = true
+			if err := res.Emit(out); err != nil {
+				return err
+			}
+			if out.Errors > 0 {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d roots not fully unsealed, run again to resume", failed, len(roots))
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *UnsealProgress) error {
+			enc, err := cmdenv.GetLowLevelCidEncoder(req)
+			if err != nil {
+				return err
+			}
+
+			line := fmt.Sprintf("%s: unsealed %d blocks (%s), released %d replicas",
+				enc.Encode(out.Root), out.Blocks, humanize.Bytes(out.Bytes), out.Released)
+			if out.Errors > 0 {
+				line += fmt.Sprintf(", %d errors", out.Errors)
+			}
+			if !out.Done {
+				_, err = fmt.Fprintf(w, "\r%s", line)
+				return err
+			}
+			if _, err = fmt.Fprintf(w, "\r%s\n", line); err != nil {
+				return err
+			}
+			if out.LastError != "" {
+				_, err = fmt.Fprintf(w, "last error: %s\n", out.LastError)
+			}
+			return err
+		}),
+	},
+}
+
+var spacexPolicyCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect and reload the sealing policy.",
+		ShortDescription: `
+'ipfs spacex policy' works with the sealing policy set in Spacex.Policy,
+which tells which pins are sealed and which blocks of a sealed DAG are kept
+in plain.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"reload":  spacexPolicyReloadCmd,
+		"explain": spacexPolicyExplainCmd,
+	},
+}
+
+var spacexPolicyReloadCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Reload the sealing policy.",
+		ShortDescription: `
+'ipfs spacex policy reload' applies the current Spacex.Policy settings and
+reads its allow and deny lists again. Running seals pick up the new rules for
+the blocks they fetch next.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		cfgRoot, err := cmdenv.GetConfigRoot(env)
+		if err != nil {
+			return err
+		}
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return err
+		}
+
+		rules, err := node.SealRules(cfg.Spacex.Policy, cfgRoot)
+		if err != nil {
+			return err
+		}
+		n.SealPolicy.Update(rules)
+
+		return cmds.EmitOnce(res, &MessageOutput{"reloaded the sealing policy\n"})
+	},
+	Type: MessageOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *MessageOutput) error {
+			fmt.Fprint(w, out.Message)
+			return nil
+		}),
+	},
+}
+
+// SealDecision is the result returned by "spacex policy explain".
+type SealDecision struct {
+	spacex.SealDecision
+
+	// Recorded is set when the decision was made when pinning the root,
+	// otherwise it is what the policy decides now.
+	Recorded bool
+}
+
+var spacexPolicyExplainCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Tell why a pin was or wasn't sealed.",
+		ShortDescription: `
+'ipfs spacex policy explain' prints the decision made by the sealing policy
+the last time <root> was pinned since the daemon started, including the
+blocks of the DAG kept in plain. Without such a decision, it prints what the
+current policy decides for <root>, which must be in the repo.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "The cid of the pinned root."),
+	},
+	Type: SealDecision{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		root, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		if d, ok := n.SealPolicy.Decision(root); ok {
+			return cmds.EmitOnce(res, &SealDecision{SealDecision: d, Recorded: true})
+		}
+
+		offlineDag := dag.NewDAGService(bserv.New(n.Blockstore, offline.Exchange(n.Blockstore)))
+		nd, err := offlineDag.Get(req.Context, root)
+		if err != nil {
+			return fmt.Errorf("%s: %s", root, err)
+		}
+		size, err := nd.Size()
+		if err != nil {
+			size = 0
+		}
+		d := n.SealPolicy.CheckRoot(root, size, len(nd.RawData()))
+		return cmds.EmitOnce(res, &SealDecision{SealDecision: d})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *SealDecision) error {
+			enc, err := cmdenv.GetLowLevelCidEncoder(req)
+			if err != nil {
+				return err
+			}
+
+			verdict := "not sealed"
+			switch {
+			case out.Sealed && out.Recorded:
+				verdict = "sealed"
+			case out.Sealed:
+				verdict = "would be sealed"
+			case !out.Recorded:
+				verdict = "would not be sealed"
+			}
+			fmt.Fprintf(w, "%s %s: %s\n", enc.Encode(out.Root), verdict, out.Reason)
+			fmt.Fprintf(w, "Priority: %d\n", out.Priority)
+
+			reasons := make([]string, 0, len(out.PlainBlocks))
+			for reason := range out.PlainBlocks {
+				reasons = append(reasons, reason)
+			}
+			sort.Strings(reasons)
+			for _, reason := range reasons {
+				fmt.Fprintf(w, "Kept %d blocks in plain: %s\n", out.PlainBlocks[reason], reason)
+			}
+			return nil
+		}),
+	},
+}
@@ -39,6 +39,7 @@ import (
 	record "github.com/libp2p/go-libp2p-record"
 	"github.com/libp2p/go-libp2p/p2p/discovery"
 	p2pbhost "github.com/libp2p/go-libp2p/p2p/host/basic"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 	ma "github.com/multiformats/go-multiaddr"
 
 	"github.com/ipfs/go-ipfs/core/bootstrap"
@@ -81,6 +82,11 @@ type IpfsNode struct {
 	Discovery       discovery.Service         `optional:"true"`
 	FilesRoot       *mfs.Root
 	RecordValidator record.Validator
+	SealBackend     spacex.SealBackend // the backend sealed blocks are stored in
+	SealStats       *spacex.SealStats  // the calls made to the seal backend
+	SealPolicy      *spacex.SealPolicy // tells which pins are sealed
+	SealRecovery    node.SealRecovery  `optional:"true"` // seal sessions recovered on start
+	Scrubber        *bserv.Scrubber    // checks the sealed replicas
 
 	// Online
 	PeerHost      p2phost.Host            `optional:"true"` // the network host (server+client)
@@ -36,6 +36,7 @@ import (
 	routing "github.com/libp2p/go-libp2p-core/routing"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	record "github.com/libp2p/go-libp2p-record"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 
 	"github.com/ipfs/go-ipfs/core"
 	"github.com/ipfs/go-ipfs/core/node"
@@ -53,6 +54,7 @@ type CoreAPI struct {
 	blockstore blockstore.GCBlockstore
 	baseBlocks blockstore.Blockstore
 	pinning    pin.Pinner
+	sealPolicy *spacex.SealPolicy
 
 	blocks bserv.BlockService
 	dag    ipld.DAGService
@@ -164,6 +166,7 @@ func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, e
 		blockstore: n.Blockstore,
 		baseBlocks: n.BaseBlocks,
 		pinning:    n.Pinning,
+		sealPolicy: n.SealPolicy,
 
 		blocks: n.Blocks,
 		dag:    n.DAG,
@@ -8,10 +8,14 @@ import (
 	"github.com/ipfs/go-cid"
 	offline "github.com/ipfs/go-ipfs-exchange-offline"
 	pin "github.com/ipfs/go-ipfs-pinner"
+	"github.com/ipfs/go-ipfs-pinner/dspinner"
+	ipld "github.com/ipfs/go-ipld-format"
 	"github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-merkledag/dagutils"
 	coreiface "github.com/ipfs/interface-go-ipfs-core"
 	caopts "github.com/ipfs/interface-go-ipfs-core/options"
 	"github.com/ipfs/interface-go-ipfs-core/path"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 )
 
 type PinAPI CoreAPI
@@ -27,6 +31,10 @@ func (api *PinAPI) Add(ctx context.Context, p path.Path, opts ...caopts.PinAddOp
 		return err
 	}
 
+	if settings.Seal && !settings.Recursive {
+		return fmt.Errorf("pin: sealing requires a recursive pin")
+	}
+
 	defer api.blockstore.PinLock().Unlock()
 
 	err = api.pinning.Pin(ctx, dagNode, settings.Recursive)
@@ -38,7 +46,21 @@ func (api *PinAPI) Add(ctx context.Context, p path.Path, opts ...caopts.PinAddOp
 		return err
 	}
 
-	return api.pinning.Flush(ctx)
+	if err := api.pinning.Flush(ctx); err != nil {
+		return err
+	}
+
+	if settings.Seal {
+		// The pin stays if the seal fails, the seal journal records why.
+		sealer, ok := api.pinning.(dspinner.RootSealer)
+		if !ok {
+			return fmt.Errorf("pin: the pinner can't seal")
+		}
+		if err := sealer.SealRoot(ctx, dagNode.Cid(), nil); err != nil {
+			return fmt.Errorf("pin: sealing: %s", err)
+		}
+	}
+	return nil
 }
 
 func (api *PinAPI) Ls(ctx context.Context, opts ...caopts.PinLsOption) (<-chan coreiface.Pin, error) {
@@ -53,7 +75,7 @@ func (api *PinAPI) Ls(ctx context.Context, opts ...caopts.PinLsOption) (<-chan c
 		return nil, fmt.Errorf("invalid type '%s', must be one of {direct, indirect, recursive, all}", settings.Type)
 	}
 
-	return api.pinLsAll(ctx, settings.Type), nil
+	return api.pinLsAll(ctx, settings.Type, settings.SealStatus), nil
 }
 
 func (api *PinAPI) IsPinned(ctx context.Context, p path.Path, opts ...caopts.PinIsPinnedOption) (string, bool, error) {
@@ -203,6 +225,7 @@ func (api *PinAPI) Verify(ctx context.Context) (<-chan coreiface.PinStatus, erro
 type pinInfo struct {
 	pinType string
 	path    path.Resolved
+	seal    *spacex.SealStatus
 	err     error
 }
 
@@ -218,19 +241,41 @@ func (p *pinInfo) Err() error {
 	return p.err
 }
 
+// Seal returns the seal status of a recursive pin listed with
+// caopts.Pin.Ls.SealStatus, or nil.
+func (p *pinInfo) Seal() *spacex.SealStatus {
+	return p.seal
+}
+
 // pinLsAll is an internal function for returning a list of pins
-func (api *PinAPI) pinLsAll(ctx context.Context, typeStr string) <-chan coreiface.Pin {
+func (api *PinAPI) pinLsAll(ctx context.Context, typeStr string, sealStatus bool) <-chan coreiface.Pin {
 	out := make(chan coreiface.Pin)
 
 	keys := cid.NewSet()
 
+	// Reading the sealed blocks with links unseals them, missing blocks are
+	// not fetched.
+	var sealGetter ipld.NodeGetter
+	if sealStatus {
+		sealGetter = merkledag.NewDAGService(bserv.New(api.blockstore, offline.Exchange(api.blockstore)))
+	}
+
 	AddToResultKeys := func(keyList []cid.Cid, typeStr string) error {
 		for _, c := range keyList {
 			if keys.Visit(c) {
+				var seal *spacex.SealStatus
+				if sealGetter != nil && typeStr == "recursive" {
+					var err error
+					seal, err = dagutils.SealStatus(ctx, api.repo.Datastore(), sealGetter, api.sealPolicy, c)
+					if err != nil {
+						return err
+					}
+				}
 				select {
 				case out <- &pinInfo{
 					pinType: typeStr,
 					path:    path.IpldPath(c),
+					seal:    seal,
 				}:
 				case <-ctx.Done():
 					return ctx.Err()
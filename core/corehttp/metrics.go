@@ -11,6 +11,7 @@ import (
 
 	ocprom "contrib.go.opencensus.io/exporter/prometheus"
 	quicmetrics "github.com/lucas-clemente/quic-go/metrics"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 	prometheus "github.com/prometheus/client_golang/prometheus"
 	promhttp "github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -179,3 +180,75 @@ func (c IpfsNodeCollector) PeersTotalValues() map[string]float64 {
 	}
 	return vals
 }
+
+var (
+	spacexRequestDurationMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "spacex", "request_duration_seconds"),
+		"Latency of the calls to the seal backend, by status code", []string{"op", "code"}, nil)
+	spacexSealedBytesMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "spacex", "sealed_bytes_total"),
+		"Number of bytes sealed, counting every replica", nil, nil)
+	spacexSealingBytesMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "spacex", "sealing_bytes"),
+		"Number of bytes sealed so far by the open seal sessions", []string{"root"}, nil)
+	spacexSealSessionsMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "spacex", "seal_sessions"),
+		"Number of open seal sessions", nil, nil)
+	spacexUnsealCorruptMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "spacex", "unseal_corrupt_total"),
+		"Number of sealed replicas unsealed to corrupted data", nil, nil)
+	spacexReplicaPruningsMetric = prometheus.NewDesc(
+		prometheus.BuildFQName("ipfs", "spacex", "replica_prunings_total"),
+		"Number of replicas pruned from sealed stubs, by reason", []string{"reason"}, nil)
+)
+
+// SpacexCollector exports the activity of the seal backend of a node.
+type SpacexCollector struct {
+	Node *core.IpfsNode
+}
+
+func (_ SpacexCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- spacexRequestDurationMetric
+	ch <- spacexSealedBytesMetric
+	ch <- spacexSealingBytesMetric
+	ch <- spacexSealSessionsMetric
+	ch <- spacexReplicaPruningsMetric
+	ch <- spacexUnsealCorruptMetric
+}
+
+func (c SpacexCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.Node.SealStats
+	if stats == nil {
+		return
+	}
+
+	for key, cs := range stats.Calls() {
+		buckets := make(map[float64]uint64, len(spacex.LatencyBuckets))
+		var cumulative uint64
+		for i, le := range spacex.LatencyBuckets {
+			cumulative += cs.Buckets[i]
+			buckets[le] = cumulative
+		}
+		ch <- prometheus.MustNewConstHistogram(
+			spacexRequestDurationMetric,
+			cs.Count,
+			cs.Sum,
+			buckets,
+			key.Op,
+			key.Code,
+		)
+	}
+
+	prunings := stats.Prunings()
+	for _, reason := range []string{spacex.PruneMissing, spacex.PruneLost, spacex.PruneCorrupt} {
+		ch <- prometheus.MustNewConstMetric(spacexReplicaPruningsMetric, prometheus.CounterValue, float64(prunings[reason]), reason)
+	}
+	ch <- prometheus.MustNewConstMetric(spacexUnsealCorruptMetric, prometheus.CounterValue, float64(prunings[spacex.PruneCorrupt]))
+
+	ch <- prometheus.MustNewConstMetric(spacexSealedBytesMetric, prometheus.CounterValue, float64(stats.SealedBytes()))
+	sealing := stats.Sealing()
+	for root, n := range sealing {
+		ch <- prometheus.MustNewConstMetric(spacexSealingBytesMetric, prometheus.GaugeValue, float64(n), root.String())
+	}
+	ch <- prometheus.MustNewConstMetric(spacexSealSessionsMetric, prometheus.GaugeValue, float64(len(sealing)))
+}
@@ -196,6 +196,9 @@ func PeriodicGC(ctx context.Context, node *core.IpfsNode) error {
 	}
 }
 
+// ConditionalGC runs a GC if the repo, once offset more bytes are stored
+// locally, goes over the GC watermark. Sealed content only takes the size of
+// its stubs, so offset shouldn't count it.
 func ConditionalGC(ctx context.Context, node *core.IpfsNode, offset uint64) error {
 	gc, err := NewGC(node)
 	if err != nil {
@@ -205,6 +208,8 @@ func ConditionalGC(ctx context.Context, node *core.IpfsNode, offset uint64) erro
 }
 
 func (gc *GC) maybeGC(ctx context.Context, offset uint64) error {
+	// The disk usage of the datastores, not the sum of the block sizes:
+	// sealed blocks are only held locally as stubs.
 	storage, err := gc.Repo.GetStorageUsage()
 	if err != nil {
 		return err
@@ -10,6 +10,7 @@ import (
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
 	humanize "github.com/dustin/go-humanize"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
 )
 
 // SizeStat wraps information about the repository size and its limit.
@@ -22,8 +23,17 @@ type SizeStat struct {
 type Stat struct {
 	SizeStat
 	NumObjects uint64
-	RepoPath   string
-	Version    string
+
+	// LocalSize is the size in bytes of the blocks stored in plain.
+	// SealedSize is the size of the NumSealed sealed blocks, which aren't
+	// held locally, SealedStubSize the size of their stubs, which are.
+	LocalSize      uint64
+	SealedSize     uint64
+	SealedStubSize uint64
+	NumSealed      uint64
+
+	RepoPath string
+	Version  string
 }
 
 // NoLimit represents the value for unlimited storage
@@ -46,6 +56,11 @@ func RepoStat(ctx context.Context, n *core.IpfsNode) (Stat, error) {
 		count++
 	}
 
+	usage, err := bstore.BlockUsage(n.Repo.Datastore())
+	if err != nil {
+		return Stat{}, err
+	}
+
 	path, err := fsrepo.BestKnownPath()
 	if err != nil {
 		return Stat{}, err
@@ -56,13 +71,19 @@ func RepoStat(ctx context.Context, n *core.IpfsNode) (Stat, error) {
 			RepoSize:   sizeStat.RepoSize,
 			StorageMax: sizeStat.StorageMax,
 		},
-		NumObjects: count,
-		RepoPath:   path,
-		Version:    fmt.Sprintf("fs-repo@%d", fsrepo.RepoVersion),
+		NumObjects:     count,
+		LocalSize:      usage.LocalSize,
+		SealedSize:     usage.SealedSize,
+		SealedStubSize: usage.SealedStubSize,
+		NumSealed:      usage.Sealed,
+		RepoPath:       path,
+		Version:        fmt.Sprintf("fs-repo@%d", fsrepo.RepoVersion),
 	}, nil
 }
 
 // RepoSize returns a *Stat object with the RepoSize and StorageMax fields set.
+// RepoSize is the disk usage of the datastores, where sealed blocks only take
+// the size of their stub.
 func RepoSize(ctx context.Context, n *core.IpfsNode) (SizeStat, error) {
 	r := n.Repo
 
@@ -13,6 +13,7 @@ import (
 	chunker "github.com/ipfs/go-ipfs-chunker"
 	"github.com/ipfs/go-ipfs-files"
 	"github.com/ipfs/go-ipfs-pinner"
+	"github.com/ipfs/go-ipfs-pinner/dspinner"
 	"github.com/ipfs/go-ipfs-posinfo"
 	ipld "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log"
@@ -69,6 +70,7 @@ type Adder struct {
 	Out        chan<- interface{}
 	Progress   bool
 	Pin        bool
+	Seal       bool // seal the added blocks once pinned, replacing them with sealed stubs
 	Trickle    bool
 	RawLeaves  bool
 	Silent     bool
@@ -79,6 +81,32 @@ type Adder struct {
 	tempRoot   cid.Cid
 	CidBuilder cid.Builder
 	liveNodes  uint64
+	added      []cid.Cid // file blocks to seal, see Seal
+}
+
+// sealRecorder records the nodes added through it, for the adder to seal
+// them as they are once the root is known.
+type sealRecorder struct {
+	ipld.DAGService
+	adder *Adder
+}
+
+func (r *sealRecorder) Add(ctx context.Context, nd ipld.Node) error {
+	if err := r.DAGService.Add(ctx, nd); err != nil {
+		return err
+	}
+	r.adder.added = append(r.adder.added, nd.Cid())
+	return nil
+}
+
+func (r *sealRecorder) AddMany(ctx context.Context, nds []ipld.Node) error {
+	if err := r.DAGService.AddMany(ctx, nds); err != nil {
+		return err
+	}
+	for _, nd := range nds {
+		r.adder.added = append(r.adder.added, nd.Cid())
+	}
+	return nil
 }
 
 func (adder *Adder) mfsRoot() (*mfs.Root, error) {
@@ -107,8 +135,13 @@ func (adder *Adder) add(reader io.Reader) (ipld.Node, error) {
 		return nil, err
 	}
 
+	var dserv ipld.DAGService = adder.bufferedDS
+	if adder.Seal {
+		dserv = &sealRecorder{DAGService: dserv, adder: adder}
+	}
+
 	params := ihelper.DagBuilderParams{
-		Dagserv:    adder.bufferedDS,
+		Dagserv:    dserv,
 		RawLeaves:  adder.RawLeaves,
 		Maxlinks:   ihelper.DefaultLinksPerBlock,
 		NoCopy:     adder.NoCopy,
@@ -182,6 +215,17 @@ func (adder *Adder) PinRoot(root ipld.Node) error {
 	return adder.pinning.Flush(adder.ctx)
 }
 
+// sealRoot seals the DAG of root once pinned: the file blocks the adder
+// wrote are sealed as they are, the directories above them are read back.
+// Seals are opened by root, which is only known once every block is added.
+func (adder *Adder) sealRoot(root cid.Cid) error {
+	sealer, ok := adder.pinning.(dspinner.RootSealer)
+	if !ok {
+		return errors.New("the pinner can't seal")
+	}
+	return sealer.SealRoot(adder.ctx, root, adder.added)
+}
+
 func (adder *Adder) outputDirs(path string, fsn mfs.FSNode) error {
 	switch fsn := fsn.(type) {
 	case *mfs.File:
@@ -330,7 +374,13 @@ func (adder *Adder) AddAllAndPin(file files.Node) (ipld.Node, error) {
 	if !adder.Pin {
 		return nd, nil
 	}
-	return nd, adder.PinRoot(nd)
+	if err := adder.PinRoot(nd); err != nil {
+		return nil, err
+	}
+	if adder.Seal {
+		return nd, adder.sealRoot(nd.Cid())
+	}
+	return nd, nil
 }
 
 func (adder *Adder) addFileNode(path string, file files.Node, toplevel bool) error {
@@ -379,6 +429,9 @@ func (adder *Adder) addSymlink(path string, l *files.Symlink) error {
 	if err != nil {
 		return err
 	}
+	if adder.Seal {
+		adder.added = append(adder.added, dagnode.Cid())
+	}
 
 	return adder.addNode(dagnode, path)
 }
@@ -22,10 +22,12 @@ import (
 	syncds "github.com/ipfs/go-datastore/sync"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	config "github.com/ipfs/go-ipfs-config"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
 	files "github.com/ipfs/go-ipfs-files"
 	pi "github.com/ipfs/go-ipfs-posinfo"
 	dag "github.com/ipfs/go-merkledag"
 	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 )
 
 const testPeerID = "QmTFauExutTsy4XP6JbMFcw2Wa9645HJt2bTqL6qYDCKfe"
@@ -366,3 +368,59 @@ func (fi *dummyFileInfo) Mode() os.FileMode  { return 0 }
 func (fi *dummyFileInfo) ModTime() time.Time { return fi.modTime }
 func (fi *dummyFileInfo) IsDir() bool        { return false }
 func (fi *dummyFileInfo) Sys() interface{}   { return nil }
+
+func TestAddSealed(t *testing.T) {
+	dstore := spacex.NewSealedDatastore(syncds.MutexWrap(datastore.NewMapDatastore()))
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: testPeerID, // required by offline node
+			},
+		},
+		D: dstore,
+	}
+	backend := spacex.NewLocalBackend()
+	node, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r, SealBackend: backend})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adder, err := NewAdder(context.Background(), node.Pinning, node.Blockstore, node.DAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Chunker = "size-1024"
+	adder.Seal = true
+
+	data := make([]byte, 3000)
+	rand.New(rand.NewSource(1)).Read(data)
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"a": files.NewBytesFile(data),
+		"b": files.NewBytesFile([]byte("testfileB")),
+	})
+	root, err := adder.AddAllAndPin(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every block of the DAG is sealed, and only them: the directories
+	// written while adding don't get a replica.
+	var count int
+	err = dag.Walk(context.Background(), dag.GetLinksDirect(node.DAG), root.Cid(), func(c cid.Cid) bool {
+		raw, err := dstore.Get(blockstore.BlockPrefix.Child(dshelp.CidToDsKey(c)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, _ := spacex.TryGetSealedInfo(raw); !ok {
+			t.Errorf("expected a sealed stub for %s", c)
+		}
+		count++
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend.Replicas() != count {
+		t.Fatalf("expected %d sealed replicas, got %d", count, backend.Replicas())
+	}
+}
@@ -17,6 +17,7 @@ import (
 	cfg "github.com/ipfs/go-ipfs-config"
 	ci "github.com/libp2p/go-libp2p-core/crypto"
 	peer "github.com/libp2p/go-libp2p-core/peer"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 )
 
 type BuildCfg struct {
@@ -40,6 +41,10 @@ type BuildCfg struct {
 	Routing libp2p.RoutingOption
 	Host    libp2p.HostOption
 	Repo    repo.Repo
+
+	// If SealBackend is set, it is used instead of the seal backend
+	// described by the repo config
+	SealBackend spacex.SealBackend
 }
 
 func (cfg *BuildCfg) getOpt(key string) bool {
@@ -62,7 +67,7 @@ func (cfg *BuildCfg) fillDefaults() error {
 		} else {
 			d = ds.NewMapDatastore()
 		}
-		r, err := defaultRepo(dsync.MutexWrap(d))
+		r, err := defaultRepo(spacex.NewSealedDatastore(dsync.MutexWrap(d)))
 		if err != nil {
 			return err
 		}
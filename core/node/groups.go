@@ -6,12 +6,14 @@ import (
 	"fmt"
 	"time"
 
+	humanize "github.com/dustin/go-humanize"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	config "github.com/ipfs/go-ipfs-config"
 	util "github.com/ipfs/go-ipfs-util"
 	log "github.com/ipfs/go-log"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 
 	"github.com/ipfs/go-ipfs/core/node/libp2p"
 	"github.com/ipfs/go-ipfs/p2p"
@@ -161,6 +163,15 @@ func Storage(bcfg *BuildCfg, cfg *config.Config) fx.Option {
 		cacheOpts.HasBloomFilterSize = 0
 	}
 
+	unsealCacheSize := uint64(DefaultUnsealCacheSize)
+	if cfg.Spacex.UnsealCacheSize != "" {
+		size, err := humanize.ParseBytes(cfg.Spacex.UnsealCacheSize)
+		if err != nil {
+			return fx.Error(fmt.Errorf("failure to parse config setting Spacex.UnsealCacheSize: %s", err))
+		}
+		unsealCacheSize = size
+	}
+
 	finalBstore := fx.Provide(GcBlockstoreCtor)
 	if cfg.Experimental.FilestoreEnabled || cfg.Experimental.UrlstoreEnabled {
 		finalBstore = fx.Provide(FilestoreBlockstoreCtor)
@@ -169,7 +180,10 @@ func Storage(bcfg *BuildCfg, cfg *config.Config) fx.Option {
 	return fx.Options(
 		fx.Provide(RepoConfig),
 		fx.Provide(Datastore),
-		fx.Provide(BaseBlockstoreCtor(cacheOpts, bcfg.NilRepo, cfg.Datastore.HashOnRead)),
+		fx.Provide(spacex.NewSealStats),
+		fx.Provide(SealPolicyCtor),
+		fx.Provide(SealBackendCtor(bcfg.SealBackend)),
+		fx.Provide(BaseBlockstoreCtor(cacheOpts, bcfg.NilRepo, cfg.Datastore.HashOnRead, int(unsealCacheSize), MaxReplicas(cfg))),
 		finalBstore,
 	)
 }
@@ -258,11 +272,21 @@ func Online(bcfg *BuildCfg, cfg *config.Config) fx.Option {
 		recordLifetime = d
 	}
 
-	/* don't provide from bitswap when the strategic provider service is active */
-	shouldBitswapProvide := !cfg.Experimental.StrategicProviding
+	var fetchCacheSize uint64
+	if cfg.Spacex.FetchCacheSize != "" {
+		size, err := humanize.ParseBytes(cfg.Spacex.FetchCacheSize)
+		if err != nil {
+			return fx.Error(fmt.Errorf("failure to parse config setting Spacex.FetchCacheSize: %s", err))
+		}
+		fetchCacheSize = size
+	}
+
+	/* don't provide from bitswap when the strategic provider service is active,
+	   nor when only sealed roots are announced */
+	shouldBitswapProvide := !cfg.Experimental.StrategicProviding && cfg.Reprovider.Strategy != "sealed"
 
 	return fx.Options(
-		fx.Provide(OnlineExchange(shouldBitswapProvide)),
+		fx.Provide(OnlineExchange(shouldBitswapProvide, int(fetchCacheSize))),
 		maybeProvide(Graphsync, cfg.Experimental.GraphsyncEnabled),
 		fx.Provide(Namesys(ipnsCacheSize)),
 		fx.Provide(Peering),
@@ -314,6 +338,15 @@ func IPFS(ctx context.Context, bcfg *BuildCfg) fx.Option {
 		return bcfgOpts // error
 	}
 
+	scrubInterval := time.Duration(cfg.Spacex.ScrubInterval)
+	if scrubInterval <= 0 {
+		scrubInterval = DefaultScrubInterval
+	}
+	scrubRate := cfg.Spacex.ScrubRate
+	if scrubRate == 0 {
+		scrubRate = DefaultScrubRate
+	}
+
 	// TEMP: setting global sharding switch here
 	uio.UseHAMTSharding = cfg.Experimental.ShardingEnabled
 
@@ -328,5 +361,7 @@ func IPFS(ctx context.Context, bcfg *BuildCfg) fx.Option {
 		Networked(bcfg, cfg),
 
 		Core,
+		maybeProvide(RecoverSeals(cfg.Spacex.ResumeInterruptedSeals.WithDefault(true)), bcfg.Permanent),
+		fx.Provide(SealScrubber(bcfg.Permanent && cfg.Spacex.Scrub.WithDefault(true), scrubInterval, scrubRate)),
 	)
 }
@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-pinner"
+	"github.com/ipfs/go-ipfs-pinner/dspinner"
 	"github.com/ipfs/go-ipfs-provider"
 	q "github.com/ipfs/go-ipfs-provider/queue"
 	"github.com/ipfs/go-ipfs-provider/simple"
 	ipld "github.com/ipfs/go-ipld-format"
 	"github.com/libp2p/go-libp2p-core/routing"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 	"go.uber.org/fx"
 
 	"github.com/ipfs/go-ipfs/core/node/helpers"
@@ -31,6 +34,47 @@ func SimpleProvider(mctx helpers.MetricsCtx, lc fx.Lifecycle, queue *q.Queue, rt
 	return simple.NewProvider(helpers.LifecycleCtx(mctx, lc), queue, rt)
 }
 
+// sealedRouting only announces the roots whose last seal completed, so that
+// the roots queued before their seal failed are withdrawn.
+type sealedRouting struct {
+	routing.ContentRouting
+	journal *spacex.SealJournal
+}
+
+func (r *sealedRouting) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	sealed, err := r.journal.Sealed(c)
+	if err != nil {
+		return err
+	}
+	if !sealed {
+		logger.Debugf("not announcing %s, it isn't sealed", c)
+		return nil
+	}
+	return r.ContentRouting.Provide(ctx, c, announce)
+}
+
+// SealedProvider creates new record provider announcing sealed roots only
+func SealedProvider(mctx helpers.MetricsCtx, lc fx.Lifecycle, queue *q.Queue, rt routing.Routing, repo repo.Repo) provider.Provider {
+	journal := spacex.NewSealJournal(repo.Datastore())
+	return simple.NewProvider(helpers.LifecycleCtx(mctx, lc), queue, &sealedRouting{ContentRouting: rt, journal: journal})
+}
+
+// SealedProvideHook has the roots announced as soon as their seal completes.
+func SealedProvideHook(pinning pin.Pinner, sys provider.System) {
+	notifier, ok := pinning.(dspinner.SealNotifier)
+	if !ok {
+		return
+	}
+	notifier.OnSeal(func(root cid.Cid, err error) {
+		if err != nil {
+			return
+		}
+		if err := sys.Provide(root); err != nil {
+			logger.Warnf("announcing sealed root %s: %s", root, err)
+		}
+	})
+}
+
 // SimpleReprovider creates new reprovider
 func SimpleReprovider(reproviderInterval time.Duration) interface{} {
 	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, rt routing.Routing, keyProvider simple.KeyChanFunc) (provider.Reprovider, error) {
@@ -97,6 +141,7 @@ func SimpleProviders(reprovideStrategy string, reprovideInterval string) fx.Opti
 		reproviderInterval = dur
 	}
 
+	providerOption := fx.Provide(SimpleProvider)
 	var keyProvider fx.Option
 	switch reprovideStrategy {
 	case "all":
@@ -107,13 +152,19 @@ func SimpleProviders(reprovideStrategy string, reprovideInterval string) fx.Opti
 		keyProvider = fx.Provide(pinnedProviderStrategy(true))
 	case "pinned":
 		keyProvider = fx.Provide(pinnedProviderStrategy(false))
+	case "sealed":
+		providerOption = fx.Options(
+			fx.Provide(SealedProvider),
+			fx.Invoke(SealedProvideHook),
+		)
+		keyProvider = fx.Provide(sealedProviderStrategy)
 	default:
 		return fx.Error(fmt.Errorf("unknown reprovider strategy '%s'", reprovideStrategy))
 	}
 
 	return fx.Options(
 		fx.Provide(ProviderQueue),
-		fx.Provide(SimpleProvider),
+		providerOption,
 		keyProvider,
 		fx.Provide(SimpleReprovider(reproviderInterval)),
 	)
@@ -124,3 +175,36 @@ func pinnedProviderStrategy(onlyRoots bool) interface{} {
 		return simple.NewPinnedProvider(onlyRoots, pinner, dag)
 	}
 }
+
+// sealedProviderStrategy announces the roots of the recursive pins whose last
+// seal completed.
+func sealedProviderStrategy(pinning pin.Pinner, repo repo.Repo) simple.KeyChanFunc {
+	journal := spacex.NewSealJournal(repo.Datastore())
+	return func(ctx context.Context) (<-chan cid.Cid, error) {
+		roots, err := pinning.RecursiveKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		out := make(chan cid.Cid)
+		go func() {
+			defer close(out)
+			for _, root := range roots {
+				sealed, err := journal.Sealed(root)
+				if err != nil {
+					logger.Errorf("reprovider: checking the seal of %s: %s", root, err)
+					continue
+				}
+				if !sealed {
+					continue
+				}
+				select {
+				case out <- root:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+}
@@ -0,0 +1,378 @@
+package node
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	config "github.com/ipfs/go-ipfs-config"
+	pin "github.com/ipfs/go-ipfs-pinner"
+	"github.com/ipfs/go-ipfs-pinner/dspinner"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/go-ipfs/core/node/helpers"
+	"github.com/ipfs/go-ipfs/repo"
+)
+
+// DefaultHealthCheckInterval is the time between two health checks of the
+// sWorkers when Spacex.HealthCheckInterval isn't set
+const DefaultHealthCheckInterval = 30 * time.Second
+
+const (
+	// DefaultScrubInterval is the time between two scrubs of the sealed
+	// replicas when Spacex.ScrubInterval isn't set.
+	DefaultScrubInterval = 24 * time.Hour
+
+	// DefaultScrubRate is the number of sealed blocks checked per second
+	// when Spacex.ScrubRate isn't set.
+	DefaultScrubRate = 10
+)
+
+// DefaultMaxReplicas is the maximum number of replicas kept per sealed block
+// when Spacex.MaxReplicas isn't set.
+const DefaultMaxReplicas = 4
+
+// MaxReplicas returns the maximum number of replicas kept per sealed block,
+// never below Spacex.Replicas, or 0 if Spacex.MaxReplicas removes the limit.
+func MaxReplicas(cfg *config.Config) int {
+	max := cfg.Spacex.MaxReplicas
+	switch {
+	case max < 0:
+		return 0
+	case max == 0:
+		max = DefaultMaxReplicas
+	}
+	if max < cfg.Spacex.Replicas {
+		max = cfg.Spacex.Replicas
+	}
+	return max
+}
+
+// SealBackendCtor creates the backend sealed blocks are written to. If
+// backend is set it is used as is, otherwise an sWorker is set up from the
+// "spacex" entry of the datastore spec; without an url the sWorker stays
+// disabled until one is set with 'ipfs spacex set-url'. If Spacex.Endpoints
+// lists more sWorkers, blocks are sealed to a pool of all of them, whose
+// health is checked for as long as the node runs. Releases go through the
+// persistent release queue, which is flushed for as long as the node runs.
+// The calls made to the backend are recorded in stats.
+func SealBackendCtor(backend spacex.SealBackend) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, cfg *config.Config, repo repo.Repo, stats *spacex.SealStats) (spacex.SealBackend, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, cfg *config.Config, repo repo.Repo, stats *spacex.SealStats) (spacex.SealBackend, error) {
+		ctx, cancel := context.WithCancel(helpers.LifecycleCtx(mctx, lc))
+
+		var pool *spacex.Pool
+		if backend == nil {
+			url, err := SpacexURL(cfg)
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			opts, err := sWorkerOptions(ctx, cfg, cfg.Spacex.Transport)
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			backend = spacex.NewSWorker(url, opts...)
+
+			if len(cfg.Spacex.Endpoints) > 0 {
+				endpoints := []spacex.Endpoint{{Backend: backend}}
+				for _, e := range cfg.Spacex.Endpoints {
+					if e.Name == "" {
+						cancel()
+						return nil, fmt.Errorf("Spacex.Endpoints: sWorker %s has no name", e.URL)
+					}
+					opts, err := sWorkerOptions(ctx, cfg, e.SpacexTransport)
+					if err != nil {
+						cancel()
+						return nil, fmt.Errorf("Spacex.Endpoints: %s: %s", e.Name, err)
+					}
+					endpoints = append(endpoints, spacex.Endpoint{
+						Name:    e.Name,
+						Backend: spacex.NewSWorker(e.URL, opts...),
+						Weight:  e.Weight,
+					})
+				}
+				pool, err = spacex.NewPool(endpoints, cfg.Spacex.Replicas)
+				if err != nil {
+					cancel()
+					return nil, fmt.Errorf("Spacex.Endpoints: %s", err)
+				}
+				backend = pool
+			}
+		}
+
+		interval := time.Duration(cfg.Spacex.HealthCheckInterval)
+		if interval <= 0 {
+			interval = DefaultHealthCheckInterval
+		}
+
+		rq := spacex.NewReleaseQueue(spacex.NewMeteredBackend(backend, stats), repo.Datastore())
+		done := make(chan struct{})
+		lc.Append(fx.Hook{
+			OnStart: func(_ context.Context) error {
+				go func() {
+					defer close(done)
+					if pool != nil {
+						go pool.Run(ctx, interval)
+					}
+					rq.Run(ctx)
+				}()
+				return nil
+			},
+			OnStop: func(_ context.Context) error {
+				cancel()
+				<-done
+				return nil
+			},
+		})
+		return rq, nil
+	}
+}
+
+// SealPolicyCtor creates the policy telling which pins are sealed from
+// Spacex.Policy.
+func SealPolicyCtor(cfg *config.Config, repo repo.Repo) (*spacex.SealPolicy, error) {
+	var root string
+	if r, ok := repo.(interface{ Path() string }); ok {
+		root = r.Path()
+	}
+	rules, err := SealRules(cfg.Spacex.Policy, root)
+	if err != nil {
+		return nil, err
+	}
+	return spacex.NewSealPolicy(rules), nil
+}
+
+// SealRules returns the sealing rules set by policy. Relative list files are
+// looked up in the repo at root.
+func SealRules(policy config.SpacexPolicy, root string) (spacex.SealRules, error) {
+	var rules spacex.SealRules
+	readList := func(name, file string) (map[string]bool, error) {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(root, file)
+		}
+		set, err := spacex.ReadCidListFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Spacex.Policy.%s: %s", name, err)
+		}
+		return set, nil
+	}
+
+	var err error
+	if policy.AllowFile != "" {
+		if rules.Allow, err = readList("AllowFile", policy.AllowFile); err != nil {
+			return rules, err
+		}
+	}
+	if policy.DenyFile != "" {
+		if rules.Deny, err = readList("DenyFile", policy.DenyFile); err != nil {
+			return rules, err
+		}
+	}
+	if policy.MaxDAGSize != "" {
+		if rules.MaxDAGSize, err = humanize.ParseBytes(policy.MaxDAGSize); err != nil {
+			return rules, fmt.Errorf("failure to parse config setting Spacex.Policy.MaxDAGSize: %s", err)
+		}
+	}
+	if policy.MaxBlockSize != "" {
+		if rules.MaxBlockSize, err = humanize.ParseBytes(policy.MaxBlockSize); err != nil {
+			return rules, fmt.Errorf("failure to parse config setting Spacex.Policy.MaxBlockSize: %s", err)
+		}
+	}
+	if policy.Codecs != nil {
+		rules.Codecs = make(map[uint64]bool)
+		for _, name := range policy.Codecs {
+			codec, err := spacex.CodecByName(name)
+			if err != nil {
+				return rules, fmt.Errorf("Spacex.Policy.Codecs: %s", err)
+			}
+			rules.Codecs[codec] = true
+		}
+	}
+	if len(policy.Priorities) > 0 {
+		rules.Priorities = make(map[string]int)
+		for k, priority := range policy.Priorities {
+			c, err := cid.Decode(k)
+			if err != nil {
+				return rules, fmt.Errorf("Spacex.Policy.Priorities: %s: %s", k, err)
+			}
+			rules.Priorities[string(c.Hash())] = priority
+		}
+	}
+	return rules, nil
+}
+
+// SealScrubber creates the scrubber of the sealed replicas. If run is set,
+// it scrubs them every interval in the background, checking at most rate
+// blocks per second.
+func SealScrubber(run bool, interval time.Duration, rate int) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, bs blockservice.BlockService, sealer spacex.SealBackend, locker blockstore.GCLocker) *blockservice.Scrubber {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, bs blockservice.BlockService, sealer spacex.SealBackend, locker blockstore.GCLocker) *blockservice.Scrubber {
+		scrubber := blockservice.NewScrubber(repo.Datastore(), bs, sealer, locker)
+		if run {
+			go scrubber.Run(helpers.LifecycleCtx(mctx, lc), interval, rate)
+		}
+		return scrubber
+	}
+}
+
+// sWorkerOptions returns the options of an sWorker reached through t. Its
+// requests are aborted once ctx is done.
+func sWorkerOptions(ctx context.Context, cfg *config.Config, t config.SpacexTransport) ([]spacex.SWorkerOption, error) {
+	opts := []spacex.SWorkerOption{spacex.WithContext(ctx)}
+	if cfg.Spacex.RequestTimeout > 0 {
+		opts = append(opts, spacex.WithTimeout(time.Duration(cfg.Spacex.RequestTimeout)))
+	}
+	if cfg.Spacex.Retries != 0 {
+		retries := cfg.Spacex.Retries
+		if retries < 0 {
+			retries = 0
+		}
+		opts = append(opts, spacex.WithRetries(retries, 100*time.Millisecond, 5*time.Second))
+	}
+	if cfg.Spacex.CapacityWait > 0 {
+		opts = append(opts, spacex.WithCapacityWait(time.Duration(cfg.Spacex.CapacityWait)))
+	}
+	if t.AuthToken != "" {
+		opts = append(opts, spacex.WithBearerToken(t.AuthToken))
+	}
+	if t.UnixSocket != "" {
+		opts = append(opts, spacex.WithUnixSocket(t.UnixSocket))
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" || t.CAFile != "" {
+		tlsConfig := &tls.Config{}
+		if t.CertFile != "" || t.KeyFile != "" {
+			cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("loading sWorker client certificate: %s", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		if t.CAFile != "" {
+			pem, err := ioutil.ReadFile(t.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("loading sWorker CA: %s", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("loading sWorker CA: no certificate in %s", t.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		opts = append(opts, spacex.WithTLSConfig(tlsConfig))
+	}
+	return opts, nil
+}
+
+// SpacexURL returns the sWorker url configured in the datastore spec, or
+// an empty string if sealing isn't configured.
+func SpacexURL(cfg *config.Config) (string, error) {
+	v, ok := cfg.Datastore.Spec["spacex"]
+	if !ok || v == nil {
+		return "", nil
+	}
+	url, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("Datastore.Spec.spacex must be a string, got %T", v)
+	}
+	return url, nil
+}
+
+// RecoveredSeal tells what was done on start with a seal session that an
+// interrupted daemon left open.
+type RecoveredSeal struct {
+	Root    cid.Cid
+	Resumed bool
+}
+
+// SealRecovery lists the seal sessions recovered on start.
+type SealRecovery []RecoveredSeal
+
+// RecoverSeals ends the seal sessions left open in the seal journal. If
+// resume is set, the interrupted sessions are then resumed the way they were
+// opened, once the node started and by decreasing priority, reusing the store
+// flags recorded so far; otherwise they are dropped.
+func RecoverSeals(resume bool) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, sealer spacex.SealBackend, policy *spacex.SealPolicy, pinning pin.Pinner) (SealRecovery, error) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, sealer spacex.SealBackend, policy *spacex.SealPolicy, pinning pin.Pinner) (SealRecovery, error) {
+		journal := spacex.NewSealJournal(repo.Datastore())
+		roots, err := journal.Roots()
+		if err != nil {
+			return nil, fmt.Errorf("reading seal journal: %s", err)
+		}
+		sort.SliceStable(roots, func(i, j int) bool {
+			return policy.Priority(roots[i]) > policy.Priority(roots[j])
+		})
+
+		var recovery SealRecovery
+		for _, root := range roots {
+			// The sWorker can't continue the old session, close it so the
+			// pin can open a new one.
+			if _, err := sealer.EndSeal(root); err != nil {
+				logger.Warnf("ending interrupted seal of %s: %s", root, err)
+			}
+
+			if !resume || !sealer.Enabled() {
+				if err := journal.Finish(root); err != nil {
+					return nil, err
+				}
+				logger.Warnf("aborted interrupted seal of %s", root)
+				recovery = append(recovery, RecoveredSeal{Root: root})
+				continue
+			}
+			recovery = append(recovery, RecoveredSeal{Root: root, Resumed: true})
+		}
+
+		resumer, ok := pinning.(dspinner.SealResumer)
+		if !ok {
+			return recovery, nil
+		}
+		ctx, cancel := context.WithCancel(helpers.LifecycleCtx(mctx, lc))
+		done := make(chan struct{})
+		lc.Append(fx.Hook{
+			OnStart: func(_ context.Context) error {
+				go func() {
+					defer close(done)
+					resumeSeals(ctx, resumer, pinning, recovery)
+				}()
+				return nil
+			},
+			OnStop: func(_ context.Context) error {
+				cancel()
+				<-done
+				return nil
+			},
+		})
+
+		return recovery, nil
+	}
+}
+
+// resumeSeals resumes the recovered seal sessions, keeping those failing in
+// the journal so that the next start retries them.
+func resumeSeals(ctx context.Context, resumer dspinner.SealResumer, pinning pin.Pinner, recovery SealRecovery) {
+	for _, rs := range recovery {
+		if !rs.Resumed {
+			continue
+		}
+		if err := resumer.ResumeSeal(ctx, rs.Root); err != nil {
+			logger.Errorf("resuming seal of %s: %s", rs.Root, err)
+			continue
+		}
+		if err := pinning.Flush(ctx); err != nil {
+			logger.Errorf("resuming seal of %s: %s", rs.Root, err)
+			continue
+		}
+		logger.Infof("resumed interrupted seal of %s", rs.Root)
+	}
+}
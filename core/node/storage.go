@@ -1,9 +1,13 @@
 package node
 
 import (
+	"fmt"
+
 	"github.com/ipfs/go-datastore"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	config "github.com/ipfs/go-ipfs-config"
+	metrics "github.com/ipfs/go-metrics-interface"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 	"go.uber.org/fx"
 
 	"github.com/ipfs/go-filestore"
@@ -26,11 +30,30 @@ func Datastore(repo repo.Repo) datastore.Datastore {
 // BaseBlocks is the lower level blockstore without GC or Filestore layers
 type BaseBlocks blockstore.Blockstore
 
+// DefaultUnsealCacheSize is the size of the unsealed data cache when
+// Spacex.UnsealCacheSize isn't set
+const DefaultUnsealCacheSize = 64 << 20
+
 // BaseBlockstoreCtor creates cached blockstore backed by the provided datastore
-func BaseBlockstoreCtor(cacheOpts blockstore.CacheOpts, nilRepo bool, hashOnRead bool) func(mctx helpers.MetricsCtx, repo repo.Repo, lc fx.Lifecycle) (bs BaseBlocks, err error) {
-	return func(mctx helpers.MetricsCtx, repo repo.Repo, lc fx.Lifecycle) (bs BaseBlocks, err error) {
+func BaseBlockstoreCtor(cacheOpts blockstore.CacheOpts, nilRepo bool, hashOnRead bool, unsealCacheSize int, maxReplicas int) func(mctx helpers.MetricsCtx, repo repo.Repo, lc fx.Lifecycle, sealer spacex.SealBackend) (bs BaseBlocks, err error) {
+	return func(mctx helpers.MetricsCtx, repo repo.Repo, lc fx.Lifecycle, sealer spacex.SealBackend) (bs BaseBlocks, err error) {
+		// sealed stubs written by older versions are stored as JSON
+		n, err := blockstore.MigrateSealedValues(repo.Datastore())
+		if err != nil {
+			return nil, fmt.Errorf("migrating sealed values: %s", err)
+		}
+		if n > 0 {
+			logger.Infof("migrated %d sealed values to the binary format", n)
+		}
+
+		var unsealCache *spacex.UnsealCache
+		if unsealCacheSize > 0 {
+			ctx := metrics.CtxSubScope(helpers.LifecycleCtx(mctx, lc), "spacex")
+			unsealCache = spacex.NewUnsealCache(ctx, unsealCacheSize)
+		}
+
 		// hash security
-		bs = blockstore.NewBlockstore(repo.Datastore())
+		bs = blockstore.NewSealingBlockstore(repo.Datastore(), sealer, unsealCache, maxReplicas)
 		bs = &verifbs.VerifBS{Blockstore: bs}
 
 		if !nilRepo {
@@ -122,5 +122,6 @@ replace github.com/ipfs/go-ds-leveldb => ./local/go-ds-leveldb
 replace github.com/ipfs/go-ipfs-pinner => ./local/go-ipfs-pinner
 replace github.com/ipfs/go-ipfs-config => ./local/go-ipfs-config
 replace github.com/mannheim-network/go-ipfs-encryptor => ./local/go-ipfs-encryptor
+replace github.com/ipfs/interface-go-ipfs-core => ./local/interface-go-ipfs-core
 
 go 1.14
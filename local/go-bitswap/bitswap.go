@@ -14,6 +14,7 @@ import (
 	deciface "github.com/ipfs/go-bitswap/decision"
 	bsbpm "github.com/ipfs/go-bitswap/internal/blockpresencemanager"
 	decision "github.com/ipfs/go-bitswap/internal/decision"
+	bsfc "github.com/ipfs/go-bitswap/internal/fetchcache"
 	bsgetter "github.com/ipfs/go-bitswap/internal/getter"
 	bsmq "github.com/ipfs/go-bitswap/internal/messagequeue"
 	notifications "github.com/ipfs/go-bitswap/internal/notifications"
@@ -111,6 +112,17 @@ func SetSendDontHaves(send bool) Option {
 	}
 }
 
+// WithFetchCache keeps the blocks fetched without a storage policy in an
+// in-memory cache of maxSize bytes instead of writing them to the blockstore.
+// Blocks fetched under a seal session are left to the sealing path either way.
+func WithFetchCache(maxSize int) Option {
+	return func(bs *Bitswap) {
+		if maxSize > 0 {
+			bs.fetchCache = bsfc.New(maxSize)
+		}
+	}
+}
+
 // Configures the engine to use the given score decision logic.
 func WithScoreLedger(scoreLedger deciface.ScoreLedger) Option {
 	return func(bs *Bitswap) {
@@ -293,6 +305,9 @@ type Bitswap struct {
 
 	// the score ledger used by the decision engine
 	engineScoreLedger deciface.ScoreLedger
+
+	// the cache blocks fetched with the StoreCached policy are kept in
+	fetchCache *bsfc.Cache
 }
 
 type counters struct {
@@ -335,8 +350,7 @@ func (bs *Bitswap) LedgerForPeer(p peer.ID) *decision.Receipt {
 // resources, provide a context with a reasonably short deadline (ie. not one
 // that lasts throughout the lifetime of the server)
 func (bs *Bitswap) GetBlocks(ctx context.Context, keys []cid.Cid) (<-chan blocks.Block, error) {
-	session := bs.sm.NewSession(ctx, bs.provSearchDelay, bs.rebroadcastDelay)
-	return session.GetBlocks(ctx, keys)
+	return bs.NewSession(ctx).GetBlocks(ctx, keys)
 }
 
 // HasBlock announces the existence of a block to this bitswap service. The
@@ -367,14 +381,17 @@ func (bs *Bitswap) receiveBlocksFrom(ctx context.Context, from peer.ID, blks []b
 		}
 	}
 
-	// Put wanted blocks into blockstore
-	if spacex.Worker.GetUrl() == "" {
-		if len(wanted) > 0 {
-			err := bs.blockstore.PutMany(wanted)
-			if err != nil {
-				log.Errorf("Error writing %d blocks to datastore: %s", len(wanted), err)
-				return err
-			}
+	// Put wanted blocks into blockstore, as asked by the requests that want
+	// them. Blocks added locally are always stored.
+	toStore := wanted
+	if from != "" {
+		toStore = bs.storeFetched(wanted)
+	}
+	if len(toStore) > 0 {
+		err := bs.blockstore.PutMany(toStore)
+		if err != nil {
+			log.Errorf("Error writing %d blocks to datastore: %s", len(toStore), err)
+			return err
 		}
 	}
 
@@ -434,6 +451,30 @@ func (bs *Bitswap) receiveBlocksFrom(ctx context.Context, from peer.ID, blks []b
 	return nil
 }
 
+// storeFetched keeps the fetched blocks according to their storage policy and
+// returns the ones to write to the blockstore.
+func (bs *Bitswap) storeFetched(blks []blocks.Block) []blocks.Block {
+	dflt := spacex.StorePlain
+	if bs.fetchCache != nil {
+		dflt = spacex.StoreCached
+	}
+
+	toStore := make([]blocks.Block, 0, len(blks))
+	for i, p := range bs.sim.StoragePolicies(blks, dflt) {
+		switch p {
+		case spacex.StorePlain:
+			toStore = append(toStore, blks[i])
+		case spacex.StoreCached:
+			if bs.fetchCache != nil {
+				bs.fetchCache.Add(blks[i])
+			} else {
+				toStore = append(toStore, blks[i])
+			}
+		}
+	}
+	return toStore
+}
+
 // ReceiveMessage is called by the network interface when a new message is
 // received.
 func (bs *Bitswap) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg.BitSwapMessage) {
@@ -580,5 +621,9 @@ func (bs *Bitswap) IsOnline() bool {
 // be more efficient in its requests to peers. If you are using a session
 // from go-blockservice, it will create a bitswap session automatically.
 func (bs *Bitswap) NewSession(ctx context.Context) exchange.Fetcher {
-	return bs.sm.NewSession(ctx, bs.provSearchDelay, bs.rebroadcastDelay)
+	session := bs.sm.NewSession(ctx, bs.provSearchDelay, bs.rebroadcastDelay)
+	if bs.fetchCache == nil {
+		return session
+	}
+	return &bsfc.Fetcher{Fetcher: session, Cache: bs.fetchCache}
 }
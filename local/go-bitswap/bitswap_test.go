@@ -24,6 +24,7 @@ import (
 	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
 	delay "github.com/ipfs/go-ipfs-delay"
 	mockrouting "github.com/ipfs/go-ipfs-routing/mock"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	p2ptestutil "github.com/libp2p/go-libp2p-netutil"
 	travis "github.com/libp2p/go-libp2p-testing/ci/travis"
@@ -111,6 +112,65 @@ func TestGetBlockFromPeerAfterPeerAnnounces(t *testing.T) {
 	}
 }
 
+func TestStoragePolicy(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	ig := testinstance.NewTestInstanceGenerator(net, nil, nil)
+	defer ig.Close()
+	cig := testinstance.NewTestInstanceGenerator(net, nil, []bitswap.Option{bitswap.WithFetchCache(1 << 20)})
+	defer cig.Close()
+	bgen := blocksutil.NewBlockGenerator()
+
+	hasBlock := ig.Next()
+	defer hasBlock.Exchange.Close()
+	wantsBlock := ig.Next()
+	defer wantsBlock.Exchange.Close()
+	cachesBlock := cig.Next()
+	defer cachesBlock.Exchange.Close()
+
+	plain, sealed, cached := bgen.Next(), bgen.Next(), bgen.Next()
+	for _, b := range []blocks.Block{plain, sealed, cached} {
+		if err := hasBlock.Exchange.HasBlock(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Blocks fetched without a policy are stored
+	if _, err := wantsBlock.Exchange.GetBlock(ctx, plain.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := wantsBlock.Blockstore().Has(plain.Cid()); !has {
+		t.Fatal("Expected block to be stored")
+	}
+
+	// Blocks fetched for sealing are left to the sealing path
+	sctx := spacex.WithStoragePolicy(ctx, spacex.StoreSealed)
+	if _, err := wantsBlock.Exchange.GetBlock(sctx, sealed.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := wantsBlock.Blockstore().Has(sealed.Cid()); has {
+		t.Fatal("Expected sealed block not to be stored")
+	}
+
+	// With a fetch cache, blocks fetched without a policy are only cached
+	if _, err := cachesBlock.Exchange.GetBlock(ctx, cached.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := cachesBlock.Blockstore().Has(cached.Cid()); has {
+		t.Fatal("Expected cached block not to be stored")
+	}
+	hasBlock.Exchange.Close()
+	received, err := cachesBlock.Exchange.GetBlock(ctx, cached.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(received.RawData(), cached.RawData()) {
+		t.Fatal("Data doesn't match")
+	}
+}
+
 func TestDoesNotProvideWhenConfiguredNotTo(t *testing.T) {
 	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
 	block := blocks.NewBlock([]byte("block"))
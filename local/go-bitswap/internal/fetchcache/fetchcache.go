@@ -0,0 +1,144 @@
+package fetchcache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+)
+
+// Cache keeps the blocks fetched with the StoreCached policy in memory. It is
+// bounded by the total size of the blocks it holds and evicts the least
+// recently used ones first.
+type Cache struct {
+	lk      sync.Mutex
+	maxSize int
+	size    int
+	lru     *list.List
+	items   map[cid.Cid]*list.Element
+}
+
+// New creates a cache holding up to maxSize bytes of blocks.
+func New(maxSize int) *Cache {
+	return &Cache{
+		maxSize: maxSize,
+		lru:     list.New(),
+		items:   make(map[cid.Cid]*list.Element),
+	}
+}
+
+// Add caches blk, evicting older blocks as needed. Blocks larger than the
+// cache aren't kept.
+func (c *Cache) Add(blk blocks.Block) {
+	size := len(blk.RawData())
+	if size > c.maxSize {
+		return
+	}
+
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	if e, ok := c.items[blk.Cid()]; ok {
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	for c.size+size > c.maxSize {
+		c.evict()
+	}
+	c.items[blk.Cid()] = c.lru.PushFront(blk)
+	c.size += size
+}
+
+func (c *Cache) evict() {
+	e := c.lru.Back()
+	blk := c.lru.Remove(e).(blocks.Block)
+	delete(c.items, blk.Cid())
+	c.size -= len(blk.RawData())
+}
+
+// Get returns the cached block for k.
+func (c *Cache) Get(k cid.Cid) (blocks.Block, bool) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	e, ok := c.items[k]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(blocks.Block), true
+}
+
+// Size returns the total size of the cached blocks.
+func (c *Cache) Size() int {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return c.size
+}
+
+// Fetcher serves the blocks held by the cache before asking the wrapped
+// fetcher for the others.
+type Fetcher struct {
+	exchange.Fetcher
+	Cache *Cache
+}
+
+// GetBlock returns the cached block for k, or fetches it.
+func (f *Fetcher) GetBlock(ctx context.Context, k cid.Cid) (blocks.Block, error) {
+	if blk, ok := f.Cache.Get(k); ok {
+		return blk, nil
+	}
+	return f.Fetcher.GetBlock(ctx, k)
+}
+
+// GetBlocks returns the cached blocks among keys, then the fetched ones.
+func (f *Fetcher) GetBlocks(ctx context.Context, keys []cid.Cid) (<-chan blocks.Block, error) {
+	var hits []blocks.Block
+	misses := make([]cid.Cid, 0, len(keys))
+	for _, k := range keys {
+		if blk, ok := f.Cache.Get(k); ok {
+			hits = append(hits, blk)
+		} else {
+			misses = append(misses, k)
+		}
+	}
+	if len(hits) == 0 {
+		return f.Fetcher.GetBlocks(ctx, keys)
+	}
+
+	var fetched <-chan blocks.Block
+	if len(misses) > 0 {
+		var err error
+		fetched, err = f.Fetcher.GetBlocks(ctx, misses)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		for _, blk := range hits {
+			select {
+			case out <- blk:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if fetched == nil {
+			return
+		}
+		for blk := range fetched {
+			select {
+			case out <- blk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,50 @@
+package fetchcache
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-bitswap/internal/testutil"
+)
+
+func TestEviction(t *testing.T) {
+	blks := testutil.GenerateBlocksOfSize(4, 1024)
+	c := New(3 * 1024)
+
+	for _, b := range blks[:3] {
+		c.Add(b)
+	}
+	if c.Size() != 3*1024 {
+		t.Fatal("Expected 3 blocks to be cached")
+	}
+
+	// Touch block 0 so that block 1 is the least recently used
+	if _, ok := c.Get(blks[0].Cid()); !ok {
+		t.Fatal("Expected block 0 to be cached")
+	}
+	c.Add(blks[3])
+
+	if _, ok := c.Get(blks[1].Cid()); ok {
+		t.Fatal("Expected block 1 to be evicted")
+	}
+	for _, i := range []int{0, 2, 3} {
+		if _, ok := c.Get(blks[i].Cid()); !ok {
+			t.Fatalf("Expected block %d to be cached", i)
+		}
+	}
+	if c.Size() != 3*1024 {
+		t.Fatal("Expected 3 blocks to be cached")
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	blks := testutil.GenerateBlocksOfSize(1, 2048)
+	c := New(1024)
+
+	c.Add(blks[0])
+	if _, ok := c.Get(blks[0].Cid()); ok {
+		t.Fatal("Expected block larger than the cache not to be cached")
+	}
+	if c.Size() != 0 {
+		t.Fatal("Expected empty cache")
+	}
+}
@@ -15,6 +15,7 @@ import (
 	logging "github.com/ipfs/go-log"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	loggables "github.com/libp2p/go-libp2p-loggables"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 	"go.uber.org/zap"
 )
 
@@ -92,8 +93,9 @@ const (
 )
 
 type op struct {
-	op   opType
-	keys []cid.Cid
+	op     opType
+	keys   []cid.Cid
+	policy spacex.StoragePolicy
 }
 
 // Session holds state for an individual bitswap transfer operation.
@@ -237,11 +239,12 @@ func (s *Session) GetBlock(parent context.Context, k cid.Cid) (blocks.Block, err
 // guaranteed on the returned blocks.
 func (s *Session) GetBlocks(ctx context.Context, keys []cid.Cid) (<-chan blocks.Block, error) {
 	ctx = logging.ContextWithLoggable(ctx, s.uuid)
+	policy := spacex.StoragePolicyFromContext(ctx)
 
 	return bsgetter.AsyncGetBlocks(ctx, s.ctx, keys, s.notif,
 		func(ctx context.Context, keys []cid.Cid) {
 			select {
-			case s.incoming <- op{op: opWant, keys: keys}:
+			case s.incoming <- op{op: opWant, keys: keys, policy: policy}:
 			case <-ctx.Done():
 			case <-s.ctx.Done():
 			}
@@ -307,6 +310,7 @@ func (s *Session) run(ctx context.Context) {
 				s.handleReceive(oper.keys)
 			case opWant:
 				// Client wants blocks
+				s.sim.RecordStoragePolicy(s.id, oper.keys, oper.policy)
 				s.wantBlocks(ctx, oper.keys)
 			case opCancel:
 				// Wants were cancelled
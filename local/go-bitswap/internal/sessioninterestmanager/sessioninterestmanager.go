@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	blocks "github.com/ipfs/go-block-format"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 
 	cid "github.com/ipfs/go-cid"
 )
@@ -12,6 +13,9 @@ import (
 type SessionInterestManager struct {
 	lk    sync.RWMutex
 	wants map[cid.Cid]map[uint64]bool
+
+	// The storage policy each session requested its wanted blocks with
+	policies map[cid.Cid]map[uint64]spacex.StoragePolicy
 }
 
 // New initializes a new SessionInterestManager.
@@ -25,7 +29,8 @@ func New() *SessionInterestManager {
 		// Note that once the block is received the session no longer wants
 		// the block, but still wants to receive messages from peers who have
 		// the block as they may have other blocks the session is interested in.
-		wants: make(map[cid.Cid]map[uint64]bool),
+		wants:    make(map[cid.Cid]map[uint64]bool),
+		policies: make(map[cid.Cid]map[uint64]spacex.StoragePolicy),
 	}
 }
 
@@ -46,6 +51,30 @@ func (sim *SessionInterestManager) RecordSessionInterest(ses uint64, ks []cid.Ci
 	}
 }
 
+// Along with RecordSessionInterest(), the session calls RecordStoragePolicy()
+// with the storage policy the client asked for the cids.
+func (sim *SessionInterestManager) RecordStoragePolicy(ses uint64, ks []cid.Cid, p spacex.StoragePolicy) {
+	sim.lk.Lock()
+	defer sim.lk.Unlock()
+
+	for _, c := range ks {
+		if pols, ok := sim.policies[c]; ok {
+			pols[ses] = p
+		} else {
+			sim.policies[c] = map[uint64]spacex.StoragePolicy{ses: p}
+		}
+	}
+}
+
+func (sim *SessionInterestManager) removePolicy(ses uint64, c cid.Cid) {
+	if pols, ok := sim.policies[c]; ok {
+		delete(pols, ses)
+		if len(pols) == 0 {
+			delete(sim.policies, c)
+		}
+	}
+}
+
 // When the session shuts down it calls RemoveSessionInterest().
 // Returns the keys that no session is interested in any more.
 func (sim *SessionInterestManager) RemoveSession(ses uint64) []cid.Cid {
@@ -59,6 +88,7 @@ func (sim *SessionInterestManager) RemoveSession(ses uint64) []cid.Cid {
 	for c := range sim.wants {
 		// Remove the session from the list of sessions that want the key
 		delete(sim.wants[c], ses)
+		sim.removePolicy(ses, c)
 
 		// If there are no more sessions that want the key
 		if len(sim.wants[c]) == 0 {
@@ -84,6 +114,7 @@ func (sim *SessionInterestManager) RemoveSessionWants(ses uint64, ks []cid.Cid)
 			// Mark the block as unwanted
 			sim.wants[c][ses] = false
 		}
+		sim.removePolicy(ses, c)
 	}
 }
 
@@ -102,6 +133,7 @@ func (sim *SessionInterestManager) RemoveSessionInterested(ses uint64, ks []cid.
 		if _, ok := sim.wants[c]; ok {
 			// Remove the session from the list of sessions that want the key
 			delete(sim.wants[c], ses)
+			sim.removePolicy(ses, c)
 
 			// If there are no more sessions that want the key
 			if len(sim.wants[c]) == 0 {
@@ -173,6 +205,34 @@ func (sim *SessionInterestManager) SplitWantedUnwanted(blks []blocks.Block) ([]b
 	return wantedBlks, notWantedBlks
 }
 
+// When bitswap receives wanted blocks it calls StoragePolicies() to find out
+// how to keep them: the highest policy requested by the sessions which still
+// want each block, StoreDefault standing for dflt.
+func (sim *SessionInterestManager) StoragePolicies(blks []blocks.Block, dflt spacex.StoragePolicy) []spacex.StoragePolicy {
+	sim.lk.RLock()
+	defer sim.lk.RUnlock()
+
+	res := make([]spacex.StoragePolicy, len(blks))
+	for i, b := range blks {
+		c := b.Cid()
+		for ses, p := range sim.policies[c] {
+			if wanted := sim.wants[c][ses]; !wanted {
+				continue
+			}
+			if p == spacex.StoreDefault {
+				p = dflt
+			}
+			if p > res[i] {
+				res[i] = p
+			}
+		}
+		if res[i] == spacex.StoreDefault {
+			res[i] = dflt
+		}
+	}
+	return res
+}
+
 // When the SessionManager receives a message it calls InterestedSessions() to
 // find out which sessions are interested in the message.
 func (sim *SessionInterestManager) InterestedSessions(blks []cid.Cid, haves []cid.Cid, dontHaves []cid.Cid) []uint64 {
@@ -5,6 +5,7 @@ import (
 
 	"github.com/ipfs/go-bitswap/internal/testutil"
 	cid "github.com/ipfs/go-cid"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 )
 
 func TestEmpty(t *testing.T) {
@@ -216,3 +217,48 @@ func TestSplitWantedUnwanted(t *testing.T) {
 		t.Fatal("Expected 2 blocks")
 	}
 }
+
+func TestStoragePolicies(t *testing.T) {
+	blks := testutil.GenerateBlocksOfSize(3, 1024)
+	sim := New()
+	ses1 := uint64(1)
+	ses2 := uint64(2)
+
+	var cids []cid.Cid
+	for _, b := range blks {
+		cids = append(cids, b.Cid())
+	}
+
+	// ses1: 0 1 sealed
+	// ses2: 1 2 default
+	sim.RecordSessionInterest(ses1, cids[0:2])
+	sim.RecordStoragePolicy(ses1, cids[0:2], spacex.StoreSealed)
+	sim.RecordSessionInterest(ses2, cids[1:])
+	sim.RecordStoragePolicy(ses2, cids[1:], spacex.StoreDefault)
+
+	pols := sim.StoragePolicies(blks, spacex.StoreCached)
+	if pols[0] != spacex.StoreSealed {
+		t.Fatal("Expected block 0 to be sealed")
+	}
+	if pols[1] != spacex.StoreCached || pols[2] != spacex.StoreCached {
+		t.Fatal("Expected blocks 1 and 2 to be cached")
+	}
+
+	// ses1: 1 sealed
+	// ses2: <none>
+	sim.RemoveSession(ses2)
+	sim.RemoveSessionWants(ses1, cids[0:1])
+
+	pols = sim.StoragePolicies(blks, spacex.StorePlain)
+	if pols[0] != spacex.StorePlain || pols[2] != spacex.StorePlain {
+		t.Fatal("Expected unwanted blocks to get the default policy")
+	}
+	if pols[1] != spacex.StoreSealed {
+		t.Fatal("Expected block 1 to be sealed")
+	}
+
+	sim.RemoveSessionInterested(ses1, cids[1:2])
+	if len(sim.policies) != 0 {
+		t.Fatal("Expected no policy left")
+	}
+}
@@ -6,6 +6,7 @@ package blockservice
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"sync"
 
@@ -229,20 +230,8 @@ func getBlock(ctx context.Context, c cid.Cid, bs blockstore.Blockstore, fget fun
 	block, err := bs.Get(c)
 	if err == nil {
 		// Seal
-		if root, err := spacex.GetRootFromSealContext(ctx); err == nil {
-			bv := block.RawData()
-			needSeal, path, err := spacex.Worker.Seal(root, false, bv)
-			if err != nil {
-				return nil, err
-			}
-
-			if needSeal && !spacex.GetStoreFlag(root, block.Cid()) {
-				err = bs.Put(spacex.NewWarpedSealedBlock(path, len(bv), c))
-				if err != nil {
-					return nil, err
-				}
-				spacex.SetStoreFlag(root, block.Cid())
-			}
+		if err := sealBlock(ctx, bs, block); err != nil {
+			return nil, err
 		}
 
 		return block, nil
@@ -264,20 +253,8 @@ func getBlock(ctx context.Context, c cid.Cid, bs blockstore.Blockstore, fget fun
 		log.Event(ctx, "BlockService.BlockFetched", c)
 
 		// Seal
-		if root, err := spacex.GetRootFromSealContext(ctx); err == nil {
-			bv := blk.RawData()
-			needSeal, path, err := spacex.Worker.Seal(root, false, bv)
-			if err != nil {
-				return nil, err
-			}
-
-			if needSeal && !spacex.GetStoreFlag(root, blk.Cid()) {
-				err = bs.Put(spacex.NewWarpedSealedBlock(path, len(bv), c))
-				if err != nil {
-					return nil, err
-				}
-				spacex.SetStoreFlag(root, blk.Cid())
-			}
+		if err := sealBlock(ctx, bs, blk); err != nil {
+			return nil, err
 		}
 		return blk, nil
 	}
@@ -290,6 +267,26 @@ func getBlock(ctx context.Context, c cid.Cid, bs blockstore.Blockstore, fget fun
 	return nil, err
 }
 
+// sealBlock queues blk to be sealed under the seal session carried by ctx,
+// if any. Its sealed stub is stored once sealed, see SealSession.Submit.
+func sealBlock(ctx context.Context, bs blockstore.Blockstore, blk blocks.Block) error {
+	ss, err := spacex.GetSealSession(ctx)
+	if err != nil {
+		return nil
+	}
+
+	bv := blk.RawData()
+	if reason := ss.CheckBlock(blk.Cid(), len(bv)); reason != "" {
+		// Fetched blocks are left to the sealing path, store it.
+		return bs.Put(blk)
+	}
+	return ss.Submit(ctx, blk.Cid(), bv, func(wb *spacex.WarpedSealedBlock) error {
+		return bs.Put(wb)
+	}, func() error {
+		return bs.Put(blk)
+	})
+}
+
 // GetBlocks gets a list of blocks asynchronously and returns through
 // the returned channel.
 // NB: No guarantees are made about order.
@@ -308,6 +305,36 @@ func getBlocks(ctx context.Context, ks []cid.Cid, bs blockstore.Blockstore, fget
 	go func() {
 		defer close(out)
 
+		send := func(b blocks.Block) bool {
+			select {
+			case out <- b:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// Under a seal session, blocks are only let through once queued
+		// to be sealed. A block which can't be is left out, like a block
+		// which wasn't found, and fails the session.
+		if ss, err := spacex.GetSealSession(ctx); err == nil {
+			send = func(b blocks.Block) bool {
+				if err := sealBlock(ctx, bs, b); err != nil {
+					if ctx.Err() != nil {
+						return false
+					}
+					ss.Fail(fmt.Errorf("sealing %s: %w", b.Cid(), err))
+					return true
+				}
+				select {
+				case out <- b:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			}
+		}
+
 		allValid := true
 		for _, c := range ks {
 			if err := verifcid.ValidateCid(c); err != nil {
@@ -336,9 +363,7 @@ func getBlocks(ctx context.Context, ks []cid.Cid, bs blockstore.Blockstore, fget
 				misses = append(misses, c)
 				continue
 			}
-			select {
-			case out <- hit:
-			case <-ctx.Done():
+			if !send(hit) {
 				return
 			}
 		}
@@ -356,9 +381,7 @@ func getBlocks(ctx context.Context, ks []cid.Cid, bs blockstore.Blockstore, fget
 
 		for b := range rblocks {
 			log.Event(ctx, "BlockService.BlockFetched", b.Cid())
-			select {
-			case out <- b:
-			case <-ctx.Done():
+			if !send(b) {
 				return
 			}
 		}
@@ -2,15 +2,21 @@ package blockservice
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
 	dssync "github.com/ipfs/go-datastore/sync"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	butil "github.com/ipfs/go-ipfs-blocksutil"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
 	exchange "github.com/ipfs/go-ipfs-exchange-interface"
 	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 )
 
 func TestWriteThroughWorks(t *testing.T) {
@@ -43,6 +49,326 @@ func TestWriteThroughWorks(t *testing.T) {
 	}
 }
 
+func TestGetBlocksSeals(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	remote := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	bserv := New(bstore, offline.Exchange(remote))
+	bgen := butil.NewBlockGenerator()
+
+	// Half the blocks are local, the other half come from the exchange.
+	var ks []cid.Cid
+	for i := 0; i < 20; i++ {
+		blk := bgen.Next()
+		var err error
+		if i%2 == 0 {
+			err = bstore.Put(blk)
+		} else {
+			err = remote.Put(blk)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ks = append(ks, blk.Cid())
+	}
+
+	backend := spacex.NewLocalBackend()
+	root := ks[0]
+	if _, err := backend.StartSeal(root); err != nil {
+		t.Fatal(err)
+	}
+	sctx := spacex.GenSealContext(ctx, backend, root)
+	ss, err := spacex.GetSealSession(sctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Blocks asked for twice are sealed once.
+	for i := 0; i < 2; i++ {
+		n := 0
+		for range bserv.GetBlocks(sctx, ks) {
+			n++
+		}
+		if n != len(ks) {
+			t.Fatalf("got %d blocks, expected %d", n, len(ks))
+		}
+	}
+	if err := ss.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range ks {
+		if !ss.GetStoreFlag(k) {
+			t.Fatalf("%s wasn't sealed", k)
+		}
+	}
+	if backend.Replicas() != len(ks) {
+		t.Fatalf("sealed %d blocks, expected %d", backend.Replicas(), len(ks))
+	}
+
+	// Blocks which can't be queued are left out and fail the session.
+	fresh := bgen.Next()
+	if err := remote.Put(fresh); err != nil {
+		t.Fatal(err)
+	}
+	for range bserv.GetBlocks(sctx, []cid.Cid{fresh.Cid()}) {
+		t.Fatal("expected the block to be left out")
+	}
+	if err := ss.Close(); !errors.Is(err, spacex.ErrSessionClosed) {
+		t.Fatalf("expected the session to fail, got %v", err)
+	}
+
+	// Outside the seal session nothing is sealed.
+	if _, err := backend.EndSeal(root); err != nil {
+		t.Fatal(err)
+	}
+	for range bserv.GetBlocks(ctx, ks) {
+	}
+	if backend.Replicas() != len(ks) {
+		t.Fatalf("sealed %d blocks outside the session", backend.Replicas()-len(ks))
+	}
+}
+
+func TestScrubber(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := spacex.NewLocalBackend()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewSealingBlockstore(dstore, backend, nil, 0)
+	remote := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	scrubber := NewScrubber(dstore, New(bstore, offline.Exchange(remote)), backend, blockstore.NewGCLocker())
+	journal := spacex.NewSealJournal(dstore)
+
+	bgen := butil.NewBlockGenerator()
+	degraded, lost, orphan := bgen.Next(), bgen.Next(), bgen.Next()
+	if err := remote.Put(lost); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Put(orphan); err != nil {
+		t.Fatal(err)
+	}
+	// Each block is sealed as its own root.
+	seal := func(blk blocks.Block, replicas int) []string {
+		if _, err := backend.StartSeal(blk.Cid()); err != nil {
+			t.Fatal(err)
+		}
+		si := &spacex.SealedInfo{}
+		var paths []string
+		for i := 0; i < replicas; i++ {
+			_, path, err := backend.Seal(blk.Cid(), false, blk.RawData())
+			if err != nil {
+				t.Fatal(err)
+			}
+			si.Sbs = append(si.Sbs, spacex.SealedBlock{Path: path, Size: len(blk.RawData())})
+			paths = append(paths, path)
+			if err := journal.SetStoreFlag(blk.Cid(), blk.Cid(), path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := backend.EndSeal(blk.Cid()); err != nil {
+			t.Fatal(err)
+		}
+		if err := journal.Finish(blk.Cid()); err != nil {
+			t.Fatal(err)
+		}
+		if err := dstore.Put(blockstore.BlockPrefix.Child(dshelp.CidToDsKey(blk.Cid())), si.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		return paths
+	}
+
+	// The sWorker loses one replica of the first block and the only one of
+	// the second.
+	dead := seal(degraded, 2)[0]
+	if err := backend.Release(dead); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Release(seal(lost, 1)[0]); err != nil {
+		t.Fatal(err)
+	}
+	// The third one lost its only replica after its root was unpinned.
+	if err := backend.Release(seal(orphan, 1)[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.DropReplicas(orphan.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := scrubber.Scrub(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scrubber.Scrub(ctx, 0); err != ErrScrubRunning {
+		t.Fatalf("expected ErrScrubRunning, got %v", err)
+	}
+	found := make(map[cid.Cid]ScrubResult)
+	for res := range results {
+		if res.Err != nil && !res.Cid.Equals(orphan.Cid()) {
+			t.Fatalf("%s: %s", res.Cid, res.Err)
+		}
+		found[res.Cid] = res
+	}
+	if len(found) != 3 {
+		t.Fatalf("scrubbed %d blocks, expected 3", len(found))
+	}
+
+	res := found[degraded.Cid()]
+	if len(res.Pruned) != 1 || res.Pruned[0] != dead || res.Replicas != 1 || res.Resealed {
+		t.Fatalf("unexpected result for the degraded block: %+v", res)
+	}
+	si, err := blockstore.GetSealedInfo(dstore, degraded.Cid())
+	if err != nil || si == nil || len(si.Sbs) != 1 || si.Sbs[0].Path == dead {
+		t.Fatal("dead replica wasn't pruned")
+	}
+	paths, err := journal.Replicas(degraded.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || paths[0] == dead {
+		t.Fatalf("dead replica wasn't dropped from the journal: %v", paths)
+	}
+
+	// Without a root to seal it under, the orphan is left alone.
+	res = found[orphan.Cid()]
+	if res.Err != ErrNoOwner || res.Resealed || res.Replicas != 0 {
+		t.Fatalf("unexpected result for the orphan block: %+v", res)
+	}
+
+	res = found[lost.Cid()]
+	if !res.Resealed || res.Replicas != 1 {
+		t.Fatalf("unexpected result for the lost block: %+v", res)
+	}
+	if backend.Replicas() != 2 {
+		t.Fatalf("backend holds %d replicas, expected 2", backend.Replicas())
+	}
+	// The map datastore keeps the stub of the new replica as stored, the
+	// repo datastores merge it into the pruned SealedInfo.
+	value, err := dstore.Get(blockstore.BlockPrefix.Child(dshelp.CidToDsKey(lost.Cid())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, sb := spacex.TryGetSealedBlock(value)
+	if !ok {
+		t.Fatal("lost block wasn't sealed again")
+	}
+	data, err, _ := backend.Unseal(sb.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(lost.RawData()) {
+		t.Fatal("resealed block has the wrong data")
+	}
+}
+
+// countingLocker counts the pin locks held.
+type countingLocker struct {
+	blockstore.GCLocker
+	held int32
+}
+
+func (l *countingLocker) PinLock() blockstore.Unlocker {
+	unlocker := l.GCLocker.PinLock()
+	atomic.AddInt32(&l.held, 1)
+	return unlockFunc(func() {
+		atomic.AddInt32(&l.held, -1)
+		unlocker.Unlock()
+	})
+}
+
+type unlockFunc func()
+
+func (f unlockFunc) Unlock() { f() }
+
+// probeBackend counts the seal sessions started and the replicas probed
+// while the pin lock is held.
+type probeBackend struct {
+	*spacex.LocalBackend
+	locker  *countingLocker
+	locked  int32
+	started int32
+}
+
+func (pb *probeBackend) StartSeal(root cid.Cid) (bool, error) {
+	atomic.AddInt32(&pb.started, 1)
+	return pb.LocalBackend.StartSeal(root)
+}
+
+func (pb *probeBackend) Unseal(path string) ([]byte, error, int) {
+	if atomic.LoadInt32(&pb.locker.held) > 0 {
+		atomic.AddInt32(&pb.locked, 1)
+	}
+	return pb.LocalBackend.Unseal(path)
+}
+
+func TestScrubberReserves(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	locker := &countingLocker{GCLocker: blockstore.NewGCLocker()}
+	backend := &probeBackend{LocalBackend: spacex.NewLocalBackend(), locker: locker}
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewSealingBlockstore(dstore, backend, nil, 0)
+	remote := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	scrubber := NewScrubber(dstore, New(bstore, offline.Exchange(remote)), backend, locker)
+	journal := spacex.NewSealJournal(dstore)
+
+	lost := blocks.NewBlock([]byte("a block the sWorker lost"))
+	if err := remote.Put(lost); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.StartSeal(lost.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	_, path, err := backend.Seal(lost.Cid(), false, lost.RawData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.SetStoreFlag(lost.Cid(), lost.Cid(), path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.EndSeal(lost.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.Finish(lost.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	si := &spacex.SealedInfo{Sbs: []spacex.SealedBlock{{Path: path, Size: len(lost.RawData())}}}
+	if err := dstore.Put(blockstore.BlockPrefix.Child(dshelp.CidToDsKey(lost.Cid())), si.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Release(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// The backend has no room left for the block, it isn't sealed again.
+	backend.SetCapacity(uint64(len(lost.RawData()) - 1))
+	results, err := scrubber.Scrub(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []ScrubResult
+	for r := range results {
+		res = append(res, r)
+	}
+	if len(res) != 1 || res[0].Err == nil || !strings.Contains(res[0].Err.Error(), spacex.ErrNoCapacity.Error()) {
+		t.Fatalf("expected the reseal to lack capacity, got %+v", res)
+	}
+	if len(res[0].Pruned) != 1 || res[0].Resealed {
+		t.Fatalf("unexpected result for the lost block: %+v", res[0])
+	}
+	if n := atomic.LoadInt32(&backend.started); n != 1 {
+		t.Fatalf("expected no seal session to be started, got %d", n-1)
+	}
+	if backend.Reserved() != 0 || backend.Replicas() != 0 {
+		t.Fatalf("expected nothing reserved nor sealed, got %d bytes and %d replicas", backend.Reserved(), backend.Replicas())
+	}
+	if n := atomic.LoadInt32(&backend.locked); n != 0 {
+		t.Fatalf("%d replicas were probed under the pin lock", n)
+	}
+}
+
 func TestLazySessionInitialization(t *testing.T) {
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
@@ -0,0 +1,390 @@
+package blockservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
+)
+
+// ErrScrubRunning is returned when a scrub is asked for while another one is
+// running.
+var ErrScrubRunning = errors.New("a sealed replica scrub is already running")
+
+// ScrubBatchSize is the number of blocks a scrub checks before reading again
+// which roots own the sealed replicas, so that the seals ending meanwhile are
+// accounted for.
+var ScrubBatchSize = 256
+
+// ErrNoOwner is reported for blocks left without replicas none of which was
+// sealed under a pinned root, so that there is no seal session to seal them
+// again under.
+var ErrNoOwner = errors.New("no pinned root owns the lost replicas, pin the block again to seal it")
+
+// ScrubResult is what a scrub found out about one sealed block.
+type ScrubResult struct {
+	Cid cid.Cid
+
+	// Replicas is the number of readable replicas the block is left with.
+	Replicas int
+
+	// Pruned lists the replicas removed from the sealed stub because the
+	// backend doesn't have them (404), lost them (410) or unsealed them to
+	// corrupted data.
+	Pruned []string
+
+	// Resealed is set when the block had lost all its replicas and was
+	// fetched again and sealed.
+	Resealed bool
+
+	// Err is set when the block couldn't be checked or repaired.
+	Err error
+}
+
+// Scrubber walks the sealed stubs of a repo, probes every replica they point
+// to and prunes the dead ones, from the stubs and the seal journal. Blocks left
+// without replicas are fetched again through the block service, under a seal
+// session of the root they were sealed under, so that they are sealed again.
+type Scrubber struct {
+	dstore  ds.Datastore
+	bserv   BlockService
+	backend spacex.SealBackend
+	journal *spacex.SealJournal
+	locker  blockstore.GCLocker
+
+	running chan struct{}
+}
+
+// NewScrubber returns a scrubber of the blocks stored by a blockstore kept in
+// dstore. Dead replicas are pruned under the pin lock of locker.
+func NewScrubber(dstore ds.Datastore, bserv BlockService, backend spacex.SealBackend, locker blockstore.GCLocker) *Scrubber {
+	return &Scrubber{
+		dstore:  dstore,
+		bserv:   bserv,
+		backend: backend,
+		journal: spacex.NewSealJournal(dstore),
+		locker:  locker,
+		running: make(chan struct{}, 1),
+	}
+}
+
+// Scrub checks every sealed block, at most rate of them per second if rate
+// is positive, and returns what it found out about each one. It fails with
+// ErrScrubRunning if another scrub is running.
+func (s *Scrubber) Scrub(ctx context.Context, rate int) (<-chan ScrubResult, error) {
+	select {
+	case s.running <- struct{}{}:
+	default:
+		return nil, ErrScrubRunning
+	}
+
+	keys, err := s.sealedKeys()
+	if err != nil {
+		<-s.running
+		return nil, err
+	}
+	roots, err := s.journal.ReplicaRoots()
+	if err != nil {
+		<-s.running
+		return nil, err
+	}
+
+	out := make(chan ScrubResult)
+	go func() {
+		defer func() { <-s.running }()
+		defer close(out)
+
+		var tick <-chan time.Time
+		if rate > 0 {
+			ticker := time.NewTicker(time.Second / time.Duration(rate))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for i, c := range keys {
+			if i > 0 && i%ScrubBatchSize == 0 {
+				if fresh, err := s.journal.ReplicaRoots(); err != nil {
+					log.Warningf("scrub: reading the replica roots: %s", err)
+				} else {
+					roots = fresh
+				}
+			}
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			res, ok := s.scrubBlock(ctx, c, roots)
+			if !ok {
+				continue
+			}
+			select {
+			case out <- res:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// sealedKeys lists the blocks stored as sealed stubs.
+func (s *Scrubber) sealedKeys() ([]cid.Cid, error) {
+	res, err := s.dstore.Query(dsq.Query{Prefix: blockstore.BlockPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var keys []cid.Cid
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		if ok, _ := spacex.TryGetSealedInfo(e.Value); !ok {
+			continue
+		}
+		c, err := dshelp.DsKeyToCid(ds.NewKey(ds.RawKey(e.Key).Name()))
+		if err != nil {
+			log.Warningf("scrub: skipping %s: %s", e.Key, err)
+			continue
+		}
+		keys = append(keys, c)
+	}
+	return keys, nil
+}
+
+// scrubBlock checks the replicas of c. It returns false if c is no longer
+// sealed. The replicas are probed without the pin lock, which is only taken
+// to prune the dead ones.
+func (s *Scrubber) scrubBlock(ctx context.Context, c cid.Cid, roots map[string]cid.Cid) (ScrubResult, bool) {
+	res := ScrubResult{Cid: c}
+	si, err := blockstore.GetSealedInfo(s.dstore, c)
+	if err == blockstore.ErrNotFound || (err == nil && si == nil) {
+		// Removed or stored in plain since listed.
+		return res, false
+	}
+	if err != nil {
+		res.Err = err
+		return res, true
+	}
+
+	// The size of the block, for the reservation of a reseal.
+	var size uint64
+	dead := make(map[string]string)
+	for _, sb := range si.Sbs {
+		size = uint64(sb.Size)
+		data, err, code := spacex.UnsealContext(ctx, s.backend, sb.Path)
+		switch {
+		case err == nil:
+			if sum, err := c.Prefix().Sum(data); err != nil || !sum.Equals(c) {
+				dead[sb.Path] = spacex.PruneCorrupt
+			}
+		case code == 404:
+			dead[sb.Path] = spacex.PruneMissing
+		case code == 410:
+			dead[sb.Path] = spacex.PruneLost
+		default:
+			// The backend can't tell, leave the block alone.
+			res.Err = err
+			res.Replicas = len(si.Sbs)
+			return res, true
+		}
+	}
+
+	alive := si.Sbs
+	if len(dead) > 0 {
+		var ok bool
+		alive, ok, err = s.prune(c, dead, roots, &res)
+		if !ok {
+			return res, false
+		}
+		if err != nil {
+			res.Err = err
+			return res, true
+		}
+		for _, path := range res.Pruned {
+			spacex.ObservePrune(s.backend, dead[path])
+			if err := spacex.ReleaseContext(ctx, s.backend, path); err != nil {
+				log.Warningf("scrub: releasing %s: %s", path, err)
+			}
+		}
+	}
+	res.Replicas = len(alive)
+
+	if len(alive) == 0 {
+		// Also covers stubs left empty by a failed reseal.
+		root, ok := ownerRoot(roots, res.Pruned)
+		if !ok {
+			res.Err = ErrNoOwner
+			return res, true
+		}
+		if err := s.reseal(ctx, root, c, size); err != nil {
+			res.Err = fmt.Errorf("resealing under %s: %s", root, err)
+			if jerr := s.journal.SetFailure(root, res.Err); jerr != nil {
+				log.Warningf("scrub: recording the seal of %s: %s", root, jerr)
+			}
+			return res, true
+		}
+		res.Resealed = true
+		res.Replicas = 1
+		if si, err := blockstore.GetSealedInfo(s.dstore, c); err == nil && si != nil {
+			res.Replicas = len(si.Sbs)
+		}
+	}
+	return res, true
+}
+
+// prune removes the dead replicas of c from its sealed stub and from the seal
+// journal under the pin lock, adds them to res.Pruned and returns the
+// replicas left. The stub is read again, as it may have changed while its
+// replicas were probed; it returns false if c is no longer sealed.
+func (s *Scrubber) prune(c cid.Cid, dead map[string]string, roots map[string]cid.Cid, res *ScrubResult) ([]spacex.SealedBlock, bool, error) {
+	unlocker := s.locker.PinLock()
+	defer unlocker.Unlock()
+
+	si, err := blockstore.GetSealedInfo(s.dstore, c)
+	if err == blockstore.ErrNotFound || (err == nil && si == nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+
+	var alive []spacex.SealedBlock
+	var pruned []string
+	for _, sb := range si.Sbs {
+		if _, ok := dead[sb.Path]; ok {
+			pruned = append(pruned, sb.Path)
+		} else {
+			alive = append(alive, sb)
+		}
+	}
+	if len(pruned) == 0 {
+		return alive, true, nil
+	}
+
+	si.Sbs = alive
+	if err := s.dstore.Put(blockstore.BlockPrefix.Child(dshelp.CidToDsKey(c)), si.Bytes()); err != nil {
+		return nil, true, err
+	}
+	res.Pruned = pruned
+	for _, path := range pruned {
+		if owner, ok := roots[path]; ok {
+			if err := s.journal.DropReplica(owner, path); err != nil {
+				return nil, true, err
+			}
+		}
+	}
+	return alive, true, nil
+}
+
+// ownerRoot returns the root the first of paths owned by one was sealed
+// under.
+func ownerRoot(roots map[string]cid.Cid, paths []string) (cid.Cid, bool) {
+	for _, path := range paths {
+		if root, ok := roots[path]; ok {
+			return root, true
+		}
+	}
+	return cid.Undef, false
+}
+
+// reseal fetches c, of size bytes, again under a seal session of root.
+func (s *Scrubber) reseal(ctx context.Context, root, c cid.Cid, size uint64) error {
+	sealing, err := s.journal.Roots()
+	if err != nil {
+		return err
+	}
+	for _, r := range sealing {
+		if r.Equals(root) {
+			return errors.New("root is being sealed")
+		}
+	}
+
+	if err := spacex.Reserve(ctx, s.backend, root, size); err != nil {
+		return err
+	}
+	needSeal, err := s.backend.StartSeal(root)
+	if err != nil || !needSeal {
+		spacex.Unreserve(s.backend, root)
+	}
+	if err != nil {
+		return err
+	}
+	if !needSeal {
+		return errors.New("sealing is disabled")
+	}
+	defer func() {
+		if _, err := s.backend.EndSeal(root); err != nil {
+			log.Warningf("scrub: ending seal of %s: %s", root, err)
+		}
+		if err := s.journal.Finish(root); err != nil {
+			log.Warningf("scrub: finishing seal of %s: %s", root, err)
+		}
+	}()
+
+	sctx, err := s.journal.GenSessionContext(ctx, s.backend, root, spacex.Session{Kind: spacex.SessionReseal})
+	if err != nil {
+		return err
+	}
+	ss, _ := spacex.GetSealSession(sctx)
+	_, err = s.bserv.GetBlock(sctx, c)
+	if closeErr := ss.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Run scrubs the sealed blocks every interval, at most rate of them per
+// second, until ctx is done. Findings are logged.
+func (s *Scrubber) Run(ctx context.Context, interval time.Duration, rate int) {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if s.backend.Enabled() {
+			s.runOnce(ctx, rate)
+		}
+		timer.Reset(interval)
+	}
+}
+
+func (s *Scrubber) runOnce(ctx context.Context, rate int) {
+	results, err := s.Scrub(ctx, rate)
+	if err != nil {
+		log.Warningf("scrub: %s", err)
+		return
+	}
+
+	var checked, pruned, resealed, failed int
+	for res := range results {
+		checked++
+		pruned += len(res.Pruned)
+		if res.Resealed {
+			resealed++
+		}
+		if res.Err != nil {
+			failed++
+			log.Warningf("scrub: %s: %s", res.Cid, res.Err)
+		} else if len(res.Pruned) > 0 {
+			log.Warningf("scrub: %s: pruned %d dead replicas, %d left", res.Cid, len(res.Pruned), res.Replicas)
+		}
+	}
+	log.Infof("scrub: checked %d sealed blocks, pruned %d replicas, resealed %d blocks, %d failures", checked, pruned, resealed, failed)
+}
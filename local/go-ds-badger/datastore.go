@@ -3,13 +3,11 @@ package badger
 import (
 	"errors"
 	"fmt"
-	"math/rand"
 	"runtime"
 	"strings"
 	"sync"
 	"time"
 
-	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 	badger "github.com/dgraph-io/badger"
 	options "github.com/dgraph-io/badger/options"
 	ds "github.com/ipfs/go-datastore"
@@ -87,8 +85,6 @@ type Options struct {
 // DefaultOptions are the default options for the badger datastore.
 var DefaultOptions Options
 
-var localRand *rand.Rand
-
 func init() {
 	DefaultOptions = Options{
 		GcDiscardRatio: 0.2,
@@ -119,7 +115,6 @@ func init() {
 	// This does not appear to have a significant performance hit.
 	DefaultOptions.Options.MaxTableSize = 16 << 20
 
-	localRand = rand.New(rand.NewSource(time.Now().Unix()))
 }
 
 var _ ds.Datastore = (*Datastore)(nil)
@@ -323,19 +318,6 @@ func (d *Datastore) Get(key ds.Key) (value []byte, err error) {
 	return txn.get(key)
 }
 
-func (d *Datastore) GetRaw(key ds.Key) (value []byte, err error) {
-	d.closeLk.RLock()
-	defer d.closeLk.RUnlock()
-	if d.closed {
-		return nil, ErrClosed
-	}
-
-	txn := d.newImplicitTransaction(false)
-	defer txn.discard()
-
-	return txn.getRaw(key)
-}
-
 func (d *Datastore) Has(key ds.Key) (bool, error) {
 	d.closeLk.RLock()
 	defer d.closeLk.RUnlock()
@@ -538,25 +520,6 @@ func (t *txn) Put(key ds.Key, value []byte) error {
 }
 
 func (t *txn) put(key ds.Key, value []byte) error {
-	if ok, sb := spacex.TryGetSealedBlock(value); ok {
-		// fmt.Printf("Sb: {path: %s, size: %d}\n", sb.Path, sb.Size)
-		data, err := t.ds.GetRaw(key)
-		if err == ds.ErrNotFound {
-			return t.txn.Set(key.Bytes(), sb.ToSealedInfo().Bytes())
-		} else if err != nil {
-			return err
-		}
-
-		if ok, si := spacex.TryGetSealedInfo(data); !ok {
-			return t.txn.Set(key.Bytes(), sb.ToSealedInfo().Bytes())
-		} else {
-			// for i := 0; i < len(si.Sbs); i++ {
-			// fmt.Printf("Sbs[%d]: {path: %s, size: %d}\n", i, si.Sbs[i].Path, si.Sbs[i].Size)
-			// }
-			return t.txn.Set(key.Bytes(), si.AddSealedBlock(*sb).Bytes())
-		}
-	}
-
 	return t.txn.Set(key.Bytes(), value)
 }
 
@@ -634,18 +597,6 @@ func (t *txn) Get(key ds.Key) ([]byte, error) {
 	return t.get(key)
 }
 
-func (t *txn) getRaw(key ds.Key) ([]byte, error) {
-	item, err := t.txn.Get(key.Bytes())
-	if err == badger.ErrKeyNotFound {
-		err = ds.ErrNotFound
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	return item.ValueCopy(nil)
-}
-
 func (t *txn) get(key ds.Key) ([]byte, error) {
 	item, err := t.txn.Get(key.Bytes())
 	if err == badger.ErrKeyNotFound {
@@ -659,54 +610,6 @@ func (t *txn) get(key ds.Key) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if ok, si := spacex.TryGetSealedInfo(value); ok {
-		sbsLen := len(si.Sbs)
-		if sbsLen == 0 {
-			return nil, ds.ErrNotFound
-		}
-
-		rindex := localRand.Intn(sbsLen)
-
-		var ret []byte
-		var code int
-		for i := rindex; i < len(si.Sbs)+rindex; {
-			nowi := i % len(si.Sbs)
-			ret, err, code = spacex.Unseal(si.Sbs[nowi].Path)
-			if err != nil {
-				switch code {
-				case 200:
-					return ret, nil
-				// Can't find
-				case 404:
-					si.Sbs = append(si.Sbs[:nowi], si.Sbs[nowi+1:]...)
-					continue
-				// Lost
-				case 410:
-					i++
-					continue
-				default:
-					return nil, err
-				}
-			} else {
-				break
-			}
-		}
-
-		if sbsLen != len(si.Sbs) {
-			err = t.ds.Put(key, si.Bytes())
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		if ret == nil {
-			return nil, ds.ErrNotFound
-		} else {
-			return ret, nil
-		}
-
-	}
-
 	return value, nil
 }
 
@@ -746,11 +649,7 @@ func (t *txn) getSize(key ds.Key) (int, error) {
 	item, err := t.txn.Get(key.Bytes())
 	switch err {
 	case nil:
-		size, err := spacex.GetSize(item)
-		if err == fmt.Errorf("Sbs is empty, can't get block size") {
-			err = ds.ErrNotFound
-		}
-		return size, err
+		return int(item.ValueSize()), nil
 	case badger.ErrKeyNotFound:
 		return -1, ds.ErrNotFound
 	default:
@@ -1,7 +1,6 @@
 module github.com/ipfs/go-ds-badger
 
 require (
-	github.com/mannheim-network/go-ipfs-encryptor v0.0.0-20210419092531-3f22dbe7ffc7
 	github.com/dgraph-io/badger v1.6.2
 	github.com/ipfs/go-datastore v0.4.4
 	github.com/ipfs/go-log/v2 v2.0.5
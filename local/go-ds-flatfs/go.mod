@@ -1,7 +1,6 @@
 module github.com/ipfs/go-ds-flatfs
 
 require (
-	github.com/mannheim-network/go-ipfs-encryptor v0.0.0-20210419092531-3f22dbe7ffc7
 	github.com/alexbrainman/goissue34681 v0.0.0-20191006012335-3fc7a47baff5
 	github.com/ipfs/go-datastore v0.4.4
 	github.com/ipfs/go-log v1.0.3
@@ -1,14 +1,10 @@
 package leveldb
 
 import (
-	"math/rand"
 	"os"
 	"path/filepath"
 	"sync"
-	"time"
 
-	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
-	"github.com/ipfs/go-datastore"
 	ds "github.com/ipfs/go-datastore"
 	dsq "github.com/ipfs/go-datastore/query"
 	"github.com/syndtr/goleveldb/leveldb"
@@ -25,10 +21,6 @@ type Datastore struct {
 	path string
 }
 
-func init() {
-	rand.Seed(time.Now().UTC().UnixNano())
-}
-
 var _ ds.Datastore = (*Datastore)(nil)
 var _ ds.TxnDatastore = (*Datastore)(nil)
 
@@ -90,26 +82,6 @@ type accessor struct {
 func (a *accessor) Put(key ds.Key, value []byte) (err error) {
 	a.closeLk.RLock()
 	defer a.closeLk.RUnlock()
-
-	if ok, sb := spacex.TryGetSealedBlock(value); ok {
-		// fmt.Printf("Sb: {path: %s, size: %d}\n", sb.Path, sb.Size)
-		data, err := a.GetRaw(key)
-		if err == datastore.ErrNotFound {
-			return a.ldb.Put(key.Bytes(), sb.ToSealedInfo().Bytes(), &opt.WriteOptions{Sync: a.syncWrites})
-		} else if err != nil {
-			return err
-		}
-
-		if ok, si := spacex.TryGetSealedInfo(data); !ok {
-			return a.ldb.Put(key.Bytes(), sb.ToSealedInfo().Bytes(), &opt.WriteOptions{Sync: a.syncWrites})
-		} else {
-			// for i := 0; i < len(si.Sbs); i++ {
-			// fmt.Printf("Sbs[%d]: {path: %s, size: %d}\n", i, si.Sbs[i].Path, si.Sbs[i].Size)
-			// }
-			return a.ldb.Put(key.Bytes(), si.AddSealedBlock(*sb).Bytes(), &opt.WriteOptions{Sync: a.syncWrites})
-		}
-	}
-
 	return a.ldb.Put(key.Bytes(), value, &opt.WriteOptions{Sync: a.syncWrites})
 }
 
@@ -117,19 +89,6 @@ func (a *accessor) Sync(prefix ds.Key) error {
 	return nil
 }
 
-func (a *accessor) GetRaw(key ds.Key) (value []byte, err error) {
-	a.closeLk.RLock()
-	defer a.closeLk.RUnlock()
-	val, err := a.ldb.Get(key.Bytes(), nil)
-	if err != nil {
-		if err == leveldb.ErrNotFound {
-			return nil, ds.ErrNotFound
-		}
-		return nil, err
-	}
-	return val, nil
-}
-
 func (a *accessor) Get(key ds.Key) (value []byte, err error) {
 	a.closeLk.RLock()
 	defer a.closeLk.RUnlock()
@@ -141,53 +100,6 @@ func (a *accessor) Get(key ds.Key) (value []byte, err error) {
 		return nil, err
 	}
 
-	if ok, si := spacex.TryGetSealedInfo(val); ok {
-		sbsLen := len(si.Sbs)
-		if sbsLen == 0 {
-			return nil, ds.ErrNotFound
-		}
-
-		rindex := rand.Intn(sbsLen)
-
-		var ret []byte
-		var code int
-		for i := rindex; i < len(si.Sbs)+rindex; {
-			nowi := i % len(si.Sbs)
-			ret, err, code = spacex.Unseal(si.Sbs[nowi].Path)
-			if err != nil {
-				switch code {
-				case 200:
-					return ret, nil
-				// Can't find
-				case 404:
-					si.Sbs = append(si.Sbs[:nowi], si.Sbs[nowi+1:]...)
-					continue
-				// Lost
-				case 410:
-					i++
-					continue
-				default:
-					return nil, err
-				}
-			} else {
-				break
-			}
-		}
-
-		if sbsLen != len(si.Sbs) {
-			err = a.Put(key, si.Bytes())
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		if ret == nil {
-			return nil, ds.ErrNotFound
-		} else {
-			return ret, nil
-		}
-	}
-
 	return val, nil
 }
 
@@ -198,19 +110,7 @@ func (a *accessor) Has(key ds.Key) (exists bool, err error) {
 }
 
 func (a *accessor) GetSize(key ds.Key) (size int, err error) {
-	value, err := a.GetRaw(key)
-	if err != nil {
-		return -1, err
-	}
-
-	if ok, si := spacex.TryGetSealedInfo(value); ok {
-		if len(si.Sbs) == 0 {
-			return -1, ds.ErrNotFound
-		}
-		return si.Sbs[0].Size, nil
-	}
-
-	return len(value), nil
+	return ds.GetBackedSize(a, key)
 }
 
 func (a *accessor) Delete(key ds.Key) (err error) {
@@ -1,7 +1,6 @@
 module github.com/ipfs/go-ds-leveldb
 
 require (
-	github.com/mannheim-network/go-ipfs-encryptor v0.0.0-20210419092531-3f22dbe7ffc7
 	github.com/ipfs/go-datastore v0.4.1
 	github.com/syndtr/goleveldb v1.0.0
 )
@@ -97,16 +97,46 @@ type gcBlockstore struct {
 // NewBlockstore returns a default Blockstore implementation
 // using the provided datastore.Batching backend.
 func NewBlockstore(d ds.Batching) Blockstore {
+	return NewSealingBlockstore(d, spacex.NewNoopBackend(), nil, 0)
+}
+
+// NewSealingBlockstore returns a default Blockstore implementation which
+// reads sealed blocks back through the given seal backend. If cache is set,
+// unsealed data is kept in it. If maxReplicas is positive, sealed stubs keep
+// at most that many replicas, further ones are released.
+func NewSealingBlockstore(d ds.Batching, backend spacex.SealBackend, cache *spacex.UnsealCache, maxReplicas int) Blockstore {
 	var dsb ds.Batching
 	dd := dsns.Wrap(d, BlockPrefix)
 	dsb = dd
 	return &blockstore{
-		datastore: dsb,
+		datastore:   dsb,
+		backend:     backend,
+		cache:       cache,
+		maxReplicas: maxReplicas,
+	}
+}
+
+// GetSealedInfo returns the sealed stub stored for k by a blockstore kept in
+// d, or nil if k is stored in plain. It returns ErrNotFound if k isn't stored.
+func GetSealedInfo(d ds.Datastore, k cid.Cid) (*spacex.SealedInfo, error) {
+	bdata, err := d.Get(BlockPrefix.Child(dshelp.CidToDsKey(k)))
+	if err == ds.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ok, si := spacex.TryGetSealedInfo(bdata); ok {
+		return si, nil
 	}
+	return nil, nil
 }
 
 type blockstore struct {
-	datastore ds.Batching
+	datastore   ds.Batching
+	backend     spacex.SealBackend
+	cache       *spacex.UnsealCache
+	maxReplicas int
 
 	rehash bool
 }
@@ -121,13 +151,22 @@ func (bs *blockstore) Get(k cid.Cid) (blocks.Block, error) {
 		return nil, ErrNotFound
 	}
 
-	bdata, err := bs.datastore.Get(dshelp.CidToDsKey(k))
+	key := dshelp.CidToDsKey(k)
+	bdata, err := bs.datastore.Get(key)
 	if err == ds.ErrNotFound {
 		return nil, ErrNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
+	if ok, si := spacex.TryGetSealedInfo(bdata); ok {
+		bdata, err = bs.unseal(k, key, si)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		bdata = spacex.UnescapeRaw(bdata)
+	}
 	if bs.rehash {
 		rbcid, err := k.Prefix().Sum(bdata)
 		if err != nil {
@@ -143,6 +182,21 @@ func (bs *blockstore) Get(k cid.Cid) (blocks.Block, error) {
 	return blocks.NewBlockWithCid(bdata, k)
 }
 
+// unseal reads a sealed block back from the seal backend, writing back the
+// replica list if dead replicas were pruned on the way.
+func (bs *blockstore) unseal(k cid.Cid, key ds.Key, si *spacex.SealedInfo) ([]byte, error) {
+	bdata, pruned, err := spacex.UnsealInfo(bs.backend, bs.cache, k, si)
+	if pruned {
+		if perr := bs.datastore.Put(key, si.Bytes()); perr != nil {
+			return nil, perr
+		}
+	}
+	if err == spacex.ErrNoReplica {
+		return nil, ErrNotFound
+	}
+	return bdata, err
+}
+
 func (bs *blockstore) Put(block blocks.Block) error {
 	k := dshelp.CidToDsKey(block.Cid())
 
@@ -154,7 +208,46 @@ func (bs *blockstore) Put(block blocks.Block) error {
 		}
 	}
 
-	return bs.datastore.Put(k, block.RawData())
+	if spacex.IsWarpedSealedBlock(block) {
+		add, err := bs.addReplica(k, block)
+		if err != nil || !add {
+			return err
+		}
+		return bs.datastore.Put(k, block.RawData())
+	}
+	return bs.datastore.Put(k, spacex.EscapeRaw(block.RawData()))
+}
+
+// addReplica reports whether the replica carried by the warped sealed block
+// is to be added to the stub stored at k. Replicas the stub already points
+// to are skipped, replicas beyond maxReplicas are released and reported with
+// spacex.ErrReplicaDropped.
+func (bs *blockstore) addReplica(k ds.Key, block blocks.Block) (bool, error) {
+	_, sb := spacex.TryGetSealedBlock(block.RawData())
+	bdata, err := bs.datastore.Get(k)
+	if err == ds.ErrNotFound {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	ok, si := spacex.TryGetSealedInfo(bdata)
+	if !ok {
+		// The stub replaces the plain block.
+		return true, nil
+	}
+
+	if si.HasPath(sb.Path) {
+		return false, nil
+	}
+	if bs.maxReplicas > 0 && len(si.Sbs) >= bs.maxReplicas {
+		log.Debugf("%s already has %d replicas, releasing %s", block.Cid(), len(si.Sbs), sb.Path)
+		if err := bs.backend.Release(sb.Path); err != nil {
+			return false, err
+		}
+		return false, spacex.ErrReplicaDropped
+	}
+	return true, nil
 }
 
 func (bs *blockstore) PutMany(blocks []blocks.Block) error {
@@ -164,14 +257,22 @@ func (bs *blockstore) PutMany(blocks []blocks.Block) error {
 	}
 	for _, b := range blocks {
 		k := dshelp.CidToDsKey(b.Cid())
+		value := b.RawData()
 		if !spacex.IsWarpedSealedBlock(b) {
 			exists, err := bs.datastore.Has(k)
 			if err == nil && exists {
 				continue
 			}
+			value = spacex.EscapeRaw(value)
+		} else if add, err := bs.addReplica(k, b); err == spacex.ErrReplicaDropped {
+			continue
+		} else if err != nil {
+			return err
+		} else if !add {
+			continue
 		}
 
-		err = t.Put(k, b.RawData())
+		err = t.Put(k, value)
 		if err != nil {
 			return err
 		}
@@ -192,7 +293,30 @@ func (bs *blockstore) GetSize(k cid.Cid) (int, error) {
 }
 
 func (bs *blockstore) DeleteBlock(k cid.Cid) error {
-	return bs.datastore.Delete(dshelp.CidToDsKey(k))
+	key := dshelp.CidToDsKey(k)
+	if !bs.backend.Enabled() {
+		return bs.datastore.Delete(key)
+	}
+
+	// Sealed replicas are only referenced by the stub, release them with it.
+	bdata, err := bs.datastore.Get(key)
+	if err != nil && err != ds.ErrNotFound {
+		return err
+	}
+	if err := bs.datastore.Delete(key); err != nil {
+		return err
+	}
+	if ok, si := spacex.TryGetSealedInfo(bdata); ok {
+		for _, sb := range si.Sbs {
+			if bs.cache != nil {
+				bs.cache.Remove(sb.Path)
+			}
+			if err := bs.backend.Release(sb.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // AllKeysChan runs a query for keys from the blockstore.
@@ -11,7 +11,9 @@ import (
 	ds "github.com/ipfs/go-datastore"
 	dsq "github.com/ipfs/go-datastore/query"
 	ds_sync "github.com/ipfs/go-datastore/sync"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
 	u "github.com/ipfs/go-ipfs-util"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 )
 
 func TestGetWhenKeyNotPresent(t *testing.T) {
@@ -269,9 +271,243 @@ func (c *queryTestDS) Query(q dsq.Query) (dsq.Results, error) {
 	return c.ds.Query(q)
 }
 
+func (c *queryTestDS) Sync(prefix ds.Key) error {
+	return c.ds.Sync(prefix)
+}
+
 func (c *queryTestDS) Batch() (ds.Batch, error) {
 	return ds.NewBasicBatch(c), nil
 }
 func (c *queryTestDS) Close() error {
 	return nil
 }
+
+func TestStubLikeBlocks(t *testing.T) {
+	bs := NewBlockstore(ds_sync.MutexWrap(ds.NewMapDatastore()))
+
+	for _, data := range []string{
+		`{"b":[]}`,
+		`{"b":[{"p":"x","s":1}]}`,
+		`{"p":"x","s":1}`,
+		"\xffSPXSEAL\x01i\x00",
+	} {
+		blk := blocks.NewBlock([]byte(data))
+		if err := bs.Put(blk); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := bs.Get(blk.Cid())
+		if err != nil {
+			t.Fatalf("reading %q: %s", data, err)
+		}
+		if string(out.RawData()) != data {
+			t.Fatalf("read %q instead of %q", out.RawData(), data)
+		}
+	}
+}
+
+func TestBoundedReplicas(t *testing.T) {
+	backend := spacex.NewLocalBackend()
+	dstore := ds_sync.MutexWrap(ds.NewMapDatastore())
+	bstore := NewSealingBlockstore(dstore, backend, nil, 2)
+
+	blk := blocks.NewBlock([]byte("shared"))
+	key := BlockPrefix.Child(dshelp.CidToDsKey(blk.Cid()))
+	if _, err := backend.StartSeal(blk.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for i := 0; i < 3; i++ {
+		_, path, err := backend.Seal(blk.Cid(), false, blk.RawData())
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	size := len(blk.RawData())
+	stub := func(paths ...string) *spacex.SealedInfo {
+		si := &spacex.SealedInfo{}
+		for _, p := range paths {
+			si.Sbs = append(si.Sbs, spacex.SealedBlock{Path: p, Size: size})
+		}
+		return si
+	}
+	checkStub := func(paths ...string) {
+		t.Helper()
+		si, err := GetSealedInfo(dstore, blk.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if si == nil || len(si.Sbs) != len(paths) {
+			t.Fatalf("expected %d replicas, got %v", len(paths), si)
+		}
+		for i, p := range paths {
+			if si.Sbs[i].Path != p {
+				t.Fatalf("replica %d is %s, expected %s", i, si.Sbs[i].Path, p)
+			}
+		}
+	}
+
+	// A replica already in the stub isn't added again, one beyond the
+	// limit is released.
+	if err := dstore.Put(key, stub(paths[0], paths[1]).Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := bstore.Put(spacex.NewWarpedSealedBlock(paths[0], size, blk.Cid())); err != nil {
+		t.Fatal(err)
+	}
+	if err := bstore.Put(spacex.NewWarpedSealedBlock(paths[2], size, blk.Cid())); err != spacex.ErrReplicaDropped {
+		t.Fatalf("expected the excess replica to be dropped, got %v", err)
+	}
+	checkStub(paths[0], paths[1])
+	if backend.Replicas() != 2 {
+		t.Fatalf("excess replica wasn't released, backend holds %d", backend.Replicas())
+	}
+
+	// Compaction rewrites the stubs stored before the limit.
+	_, extra, err := backend.Seal(blk.Cid(), false, blk.RawData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dstore.Put(key, stub(paths[0], paths[0], paths[1], extra).Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := CompactSealedValues(dstore, backend, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (CompactStats{Stubs: 1, Rewritten: 1, Released: 1}) {
+		t.Fatalf("unexpected compaction: %+v", stats)
+	}
+	checkStub(paths[0], paths[1])
+	if backend.Replicas() != 2 {
+		t.Fatalf("excess replica wasn't released, backend holds %d", backend.Replicas())
+	}
+
+	stats, err = CompactSealedValues(dstore, backend, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Rewritten != 0 {
+		t.Fatalf("compacted stub rewritten again: %+v", stats)
+	}
+}
+
+type unsealCountingBackend struct {
+	*spacex.LocalBackend
+	unseals int
+}
+
+func (b *unsealCountingBackend) Unseal(path string) ([]byte, error, int) {
+	b.unseals++
+	return b.LocalBackend.Unseal(path)
+}
+
+func TestUnsealCache(t *testing.T) {
+	ctx := context.Background()
+	backend := &unsealCountingBackend{LocalBackend: spacex.NewLocalBackend()}
+	cache := spacex.NewUnsealCache(ctx, 1<<20)
+	dstore := ds_sync.MutexWrap(ds.NewMapDatastore())
+	bstore := NewSealingBlockstore(dstore, backend, cache, 0)
+
+	blk := blocks.NewBlock([]byte("sealed block"))
+	root := blk.Cid()
+	if _, err := backend.StartSeal(root); err != nil {
+		t.Fatal(err)
+	}
+	_, path, err := backend.Seal(root, false, blk.RawData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	si := &spacex.SealedInfo{Sbs: []spacex.SealedBlock{{Path: path, Size: len(blk.RawData())}}}
+	if err := dstore.Put(BlockPrefix.Child(dshelp.CidToDsKey(root)), si.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		got, err := bstore.Get(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Cid().Equals(root) {
+			t.Fatal("unsealed the wrong block")
+		}
+	}
+	if backend.unseals != 1 {
+		t.Fatalf("unsealed %d times, expected once", backend.unseals)
+	}
+	if cache.Size() != len(blk.RawData()) {
+		t.Fatalf("cache holds %d bytes, expected %d", cache.Size(), len(blk.RawData()))
+	}
+
+	// Releasing the replica drops its cached data.
+	if err := bstore.DeleteBlock(root); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Size() != 0 {
+		t.Fatalf("cache still holds %d bytes", cache.Size())
+	}
+}
+
+func TestCorruptReplica(t *testing.T) {
+	backend := spacex.NewLocalBackend()
+	stats := spacex.NewSealStats()
+	dstore := ds_sync.MutexWrap(ds.NewMapDatastore())
+	bstore := NewSealingBlockstore(dstore, spacex.NewMeteredBackend(backend, stats), nil, 0)
+
+	blk := blocks.NewBlock([]byte("sealed block"))
+	root := blk.Cid()
+	key := BlockPrefix.Child(dshelp.CidToDsKey(root))
+	if _, err := backend.StartSeal(root); err != nil {
+		t.Fatal(err)
+	}
+	_, good, err := backend.Seal(root, false, blk.RawData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, bad, err := backend.Seal(root, false, []byte("corrupted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reads fail over to the good replica.
+	size := len(blk.RawData())
+	si := &spacex.SealedInfo{Sbs: []spacex.SealedBlock{{Path: bad, Size: size}, {Path: good, Size: size}}}
+	if err := dstore.Put(key, si.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	got, err := bstore.Get(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.RawData()) != string(blk.RawData()) {
+		t.Fatal("got corrupted data")
+	}
+
+	// A corrupted replica is dropped from the stub and released.
+	_, bad, err = backend.Seal(root, false, []byte("corrupted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pruned := stats.Prunings()[spacex.PruneCorrupt]
+	si = &spacex.SealedInfo{Sbs: []spacex.SealedBlock{{Path: bad, Size: size}}}
+	if err := dstore.Put(key, si.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bstore.Get(root); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	value, err := dstore.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, si := spacex.TryGetSealedInfo(value); !ok || len(si.Sbs) != 0 {
+		t.Fatal("corrupted replica wasn't dropped")
+	}
+	if _, err, code := backend.Unseal(bad); err == nil || code != 404 {
+		t.Fatal("corrupted replica wasn't released")
+	}
+	if stats.Prunings()[spacex.PruneCorrupt] != pruned+1 {
+		t.Fatal("corrupted replica wasn't counted")
+	}
+}
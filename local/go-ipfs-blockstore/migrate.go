@@ -0,0 +1,131 @@
+package blockstore
+
+import (
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
+)
+
+// SealFormatKey records the format version of the sealed values stored by the
+// blockstore.
+var SealFormatKey = ds.NewKey("/spacex/format")
+
+// MigrateSealedValues brings the blocks stored by a blockstore kept in d to
+// the current sealed value format: JSON sealed stubs are rewritten to the
+// binary envelope and block content starting with the envelope magic is
+// escaped. Values are told apart from blocks by hashing them against their
+// key. Nothing is done once d was migrated. It returns the number of values
+// rewritten.
+func MigrateSealedValues(d ds.Datastore) (int, error) {
+	has, err := d.Has(SealFormatKey)
+	if err != nil || has {
+		return 0, err
+	}
+
+	res, err := d.Query(dsq.Query{Prefix: BlockPrefix.String()})
+	if err != nil {
+		return 0, err
+	}
+
+	rewrites := make(map[ds.Key][]byte)
+	for e := range res.Next() {
+		if e.Error != nil {
+			res.Close()
+			return 0, e.Error
+		}
+
+		key := ds.RawKey(e.Key)
+		c, err := dshelp.DsKeyToCid(ds.NewKey(key.Name()))
+		if err != nil {
+			log.Warningf("skipping sealed value migration of %s: %s", key, err)
+			continue
+		}
+
+		legacy, si := spacex.TryGetLegacySealedInfo(e.Value)
+		if !legacy && !spacex.HasSealMagic(e.Value) {
+			continue
+		}
+		if sum, err := c.Prefix().Sum(e.Value); err == nil && sum.Equals(c) {
+			if spacex.HasSealMagic(e.Value) {
+				rewrites[key] = spacex.EscapeRaw(e.Value)
+			}
+			continue
+		}
+		if legacy {
+			rewrites[key] = si.Bytes()
+		}
+	}
+	if err := res.Close(); err != nil {
+		return 0, err
+	}
+
+	for key, value := range rewrites {
+		if err := d.Put(key, value); err != nil {
+			return 0, err
+		}
+	}
+	return len(rewrites), d.Put(SealFormatKey, []byte{spacex.SealFormatVersion})
+}
+
+// CompactStats sums up a CompactSealedValues pass.
+type CompactStats struct {
+	// Stubs is the number of sealed stubs checked.
+	Stubs int
+
+	// Rewritten is the number of stubs which had duplicate or excess
+	// replicas.
+	Rewritten int
+
+	// Released is the number of excess replicas released.
+	Released int
+}
+
+// CompactSealedValues rewrites the sealed stubs of the blockstore kept in d
+// which point to a replica more than once, or to more than maxReplicas
+// replicas if maxReplicas is positive. The excess replicas are released
+// through backend.
+func CompactSealedValues(d ds.Datastore, backend spacex.SealBackend, maxReplicas int) (CompactStats, error) {
+	var stats CompactStats
+	res, err := d.Query(dsq.Query{Prefix: BlockPrefix.String()})
+	if err != nil {
+		return stats, err
+	}
+
+	rewrites := make(map[ds.Key]*spacex.SealedInfo)
+	var dropped []string
+	for e := range res.Next() {
+		if e.Error != nil {
+			res.Close()
+			return stats, e.Error
+		}
+
+		ok, si := spacex.TryGetSealedInfo(e.Value)
+		if !ok {
+			continue
+		}
+		stats.Stubs++
+		excess, changed := si.Compact(maxReplicas)
+		if changed {
+			rewrites[ds.RawKey(e.Key)] = si
+			dropped = append(dropped, excess...)
+		}
+	}
+	if err := res.Close(); err != nil {
+		return stats, err
+	}
+
+	for key, si := range rewrites {
+		if err := d.Put(key, si.Bytes()); err != nil {
+			return stats, err
+		}
+		stats.Rewritten++
+	}
+	for _, path := range dropped {
+		if err := backend.Release(path); err != nil {
+			return stats, err
+		}
+		stats.Released++
+	}
+	return stats, nil
+}
@@ -0,0 +1,67 @@
+package blockstore
+
+import (
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	ds "github.com/ipfs/go-datastore"
+	ds_sync "github.com/ipfs/go-datastore/sync"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
+)
+
+func TestMigrateSealedValues(t *testing.T) {
+	dstore := ds_sync.MutexWrap(ds.NewMapDatastore())
+	blockKey := func(blk blocks.Block) ds.Key {
+		return BlockPrefix.Child(dshelp.CidToDsKey(blk.Cid()))
+	}
+
+	// A block whose content parses as a JSON stub, stored in plain.
+	plain := blocks.NewBlock([]byte(`{"b":[{"p":"x","s":1}]}`))
+	if err := dstore.Put(blockKey(plain), plain.RawData()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A block stored as a JSON stub.
+	sealed := blocks.NewBlock([]byte("sealed"))
+	legacy := []byte(`{"b":[{"p":"root/a","s":6},{"p":"root/b","s":6}]}`)
+	if err := dstore.Put(blockKey(sealed), legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := MigrateSealedValues(dstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 migrated value, got %d", n)
+	}
+
+	si, err := GetSealedInfo(dstore, plain.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if si != nil {
+		t.Fatal("plain block migrated as a stub")
+	}
+
+	si, err = GetSealedInfo(dstore, sealed.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if si == nil || len(si.Sbs) != 2 || si.Sbs[1].Path != "root/b" || si.Sbs[1].Size != 6 {
+		t.Fatalf("stub not migrated: %v", si)
+	}
+
+	// Migrated stubs are left alone on the next run.
+	n, err = MigrateSealedValues(dstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("expected no migrated value, got %d", n)
+	}
+	if ok, _ := spacex.TryGetSealedInfo(si.Bytes()); !ok {
+		t.Fatal("stub can't be read back")
+	}
+}
@@ -0,0 +1,85 @@
+package blockstore
+
+import (
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
+)
+
+// Usage splits the size of the blocks stored by a blockstore between the
+// blocks kept in plain and the sealed ones.
+type Usage struct {
+	// Blocks is the number of blocks stored in plain and LocalSize their
+	// size in bytes.
+	Blocks    uint64
+	LocalSize uint64
+
+	// Sealed is the number of sealed blocks and SealedSize their size in
+	// bytes as read back, which isn't held locally. SealedStubSize is the
+	// size of their stubs, which is.
+	Sealed         uint64
+	SealedSize     uint64
+	SealedStubSize uint64
+}
+
+// stubSizeLimit bounds the size of the entries BlockUsage reads to tell
+// sealed stubs apart: larger entries are counted as plain blocks from their
+// size alone.
+const stubSizeLimit = 64 << 10
+
+// BlockUsage sums up the size of the blocks stored by a blockstore kept in d.
+// Unlike GetSize, it counts sealed blocks by the size of their stub. Sizes
+// come from the query, so only the entries small enough to be stubs are read.
+func BlockUsage(d ds.Datastore) (Usage, error) {
+	var u Usage
+	res, err := d.Query(dsq.Query{
+		Prefix:       BlockPrefix.String(),
+		KeysOnly:     true,
+		ReturnsSizes: true,
+	})
+	if err != nil {
+		return u, err
+	}
+	defer res.Close()
+
+	for e := range res.Next() {
+		if e.Error != nil {
+			return u, e.Error
+		}
+
+		if e.Size > stubSizeLimit {
+			u.Blocks++
+			u.LocalSize += uint64(e.Size)
+			continue
+		}
+
+		value, err := d.Get(ds.RawKey(e.Key))
+		switch err {
+		case nil:
+		case ds.ErrNotFound:
+			// deleted since the query ran
+			continue
+		default:
+			return u, err
+		}
+
+		ok, si := spacex.TryGetSealedInfo(value)
+		if !ok {
+			u.Blocks++
+			u.LocalSize += uint64(len(value))
+			continue
+		}
+		u.Sealed++
+		u.SealedStubSize += uint64(len(value))
+		if len(si.Sbs) > 0 {
+			u.SealedSize += uint64(si.Sbs[0].Size)
+		}
+	}
+	return u, nil
+}
+
+// Local is the number of bytes the blocks take locally: the plain blocks and
+// the stubs of the sealed ones.
+func (u Usage) Local() uint64 {
+	return u.LocalSize + u.SealedStubSize
+}
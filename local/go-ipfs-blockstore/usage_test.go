@@ -0,0 +1,46 @@
+package blockstore
+
+import (
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	ds "github.com/ipfs/go-datastore"
+	ds_sync "github.com/ipfs/go-datastore/sync"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
+)
+
+func TestBlockUsage(t *testing.T) {
+	dstore := ds_sync.MutexWrap(ds.NewMapDatastore())
+	bstore := NewBlockstore(dstore)
+
+	plain := blocks.NewBlock([]byte("kept in plain"))
+	large := blocks.NewBlock(make([]byte, 1<<20))
+	if err := bstore.PutMany([]blocks.Block{plain, large}); err != nil {
+		t.Fatal(err)
+	}
+	sealed := blocks.NewBlock(make([]byte, 1000))
+	si := &spacex.SealedInfo{Sbs: []spacex.SealedBlock{{Path: "p1", Size: 1000}, {Path: "p2", Size: 1000}}}
+	stub := si.Bytes()
+	if err := dstore.Put(BlockPrefix.Child(dshelp.CidToDsKey(sealed.Cid())), stub); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := BlockUsage(dstore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Usage{
+		Blocks:         2,
+		LocalSize:      uint64(len(plain.RawData()) + len(large.RawData())),
+		Sealed:         1,
+		SealedSize:     1000,
+		SealedStubSize: uint64(len(stub)),
+	}
+	if u != expected {
+		t.Fatalf("expected %+v, got %+v", expected, u)
+	}
+	if local := expected.LocalSize + expected.SealedStubSize; u.Local() != local {
+		t.Fatalf("expected %d bytes held locally, got %d", local, u.Local())
+	}
+}
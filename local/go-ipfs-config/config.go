@@ -34,6 +34,7 @@ type Config struct {
 	Experimental Experiments
 	Plugins      Plugins
 	Pinning      Pinning
+	Spacex       Spacex
 }
 
 const (
@@ -134,13 +134,16 @@ func DefaultDatastoreConfig() Datastore {
 
 func badgerSpec() map[string]interface{} {
 	return map[string]interface{}{
-		"type":   "measure",
-		"prefix": "badger.datastore",
+		"type": "sealed",
 		"child": map[string]interface{}{
-			"type":       "badgerds",
-			"path":       "badgerds",
-			"syncWrites": false,
-			"truncate":   true,
+			"type":   "measure",
+			"prefix": "badger.datastore",
+			"child": map[string]interface{}{
+				"type":       "badgerds",
+				"path":       "badgerds",
+				"syncWrites": false,
+				"truncate":   true,
+			},
 		},
 		"spacex": "http://127.0.0.1:12222/api/v0",
 	}
@@ -152,12 +155,15 @@ func leveldbSpec() map[string]interface{} {
 		"mounts": []interface{}{
 			map[string]interface{}{
 				"mountpoint": "/",
-				"type":       "measure",
-				"prefix":     "leveldb.datastore",
+				"type":       "sealed",
 				"child": map[string]interface{}{
-					"type":        "levelds",
-					"path":        "datastore",
-					"compression": "none",
+					"type":   "measure",
+					"prefix": "leveldb.datastore",
+					"child": map[string]interface{}{
+						"type":        "levelds",
+						"path":        "datastore",
+						"compression": "none",
+					},
 				},
 			},
 		},
@@ -171,13 +177,16 @@ func flatfsSpec() map[string]interface{} {
 		"mounts": []interface{}{
 			map[string]interface{}{
 				"mountpoint": "/blocks",
-				"type":       "measure",
-				"prefix":     "flatfs.datastore",
+				"type":       "sealed",
 				"child": map[string]interface{}{
-					"type":      "flatfs",
-					"path":      "blocks",
-					"sync":      true,
-					"shardFunc": "/repo/flatfs/shard/v1/next-to-last/2",
+					"type":   "measure",
+					"prefix": "flatfs.datastore",
+					"child": map[string]interface{}{
+						"type":      "flatfs",
+						"path":      "blocks",
+						"sync":      true,
+						"shardFunc": "/repo/flatfs/shard/v1/next-to-last/2",
+					},
 				},
 			},
 			map[string]interface{}{
@@ -0,0 +1,129 @@
+package config
+
+// Spacex configures sealing of pinned content.
+type Spacex struct {
+	// ResumeInterruptedSeals makes the daemon re-run seal sessions that
+	// were left open by a crash on start. If disabled, they are ended and
+	// dropped instead.
+	ResumeInterruptedSeals Flag `json:",omitempty"`
+
+	// FetchCacheSize bounds an in-memory cache which blocks fetched from the
+	// network outside of a pin are kept in instead of the repo, e.g. "256MB".
+	// When unset, they are written to the repo.
+	FetchCacheSize string `json:",omitempty"`
+
+	// UnsealCacheSize bounds the in-memory cache of unsealed block data,
+	// e.g. "64MB". "0" disables the cache.
+	UnsealCacheSize string `json:",omitempty"`
+
+	// Endpoints lists sWorkers blocks are sealed to, in addition to the one
+	// set in the "spacex" entry of the datastore spec.
+	Endpoints []SpacexEndpoint `json:",omitempty"`
+
+	// Replicas is the number of replicas of each block placed on distinct
+	// sWorkers. Defaults to 1.
+	Replicas int `json:",omitempty"`
+
+	// MaxReplicas bounds the number of replicas kept per block, replicas
+	// sealed beyond it, e.g. by pins of other roots sharing the block, are
+	// released. It is never below Replicas. Defaults to 4, -1 removes the
+	// limit.
+	MaxReplicas int `json:",omitempty"`
+
+	// HealthCheckInterval is the time between two health checks of the
+	// sWorkers. Defaults to 30s.
+	HealthCheckInterval Duration `json:",omitempty"`
+
+	// RequestTimeout is the deadline of a single sWorker request. Defaults
+	// to 2m.
+	RequestTimeout Duration `json:",omitempty"`
+
+	// Retries is the number of times an sWorker request failing with a
+	// transient error is retried. Defaults to 3, -1 disables retries.
+	Retries int `json:",omitempty"`
+
+	// CapacityWait is how long a pin the sWorkers have no room for waits
+	// for other seals to end before it is rejected. Defaults to 0, such
+	// pins are rejected right away.
+	CapacityWait Duration `json:",omitempty"`
+
+	// Transport configures how the sWorker set in the datastore spec is
+	// reached.
+	Transport SpacexTransport `json:",omitempty"`
+
+	// Scrub makes the daemon check the sealed replicas in the background,
+	// pruning the ones the sWorkers lost and re-fetching and sealing again
+	// the blocks left without any. Defaults to true.
+	Scrub Flag `json:",omitempty"`
+
+	// ScrubInterval is the time between two scrubs. Defaults to 24h.
+	ScrubInterval Duration `json:",omitempty"`
+
+	// ScrubRate is the number of sealed blocks checked per second, -1
+	// removes the limit. Defaults to 10.
+	ScrubRate int `json:",omitempty"`
+
+	// Policy tells which pins are sealed.
+	Policy SpacexPolicy `json:",omitempty"`
+}
+
+// SpacexPolicy tells which pins are sealed and which blocks of a sealed DAG
+// are kept in plain. It is reloaded with 'ipfs spacex policy reload'.
+type SpacexPolicy struct {
+	// AllowFile is a file listing the only roots which are sealed, one cid
+	// per line. Relative paths are relative to the repo.
+	AllowFile string `json:",omitempty"`
+
+	// DenyFile is a file listing roots which are not sealed, one cid per
+	// line. Relative paths are relative to the repo.
+	DenyFile string `json:",omitempty"`
+
+	// MaxDAGSize is the size above which a DAG isn't sealed, e.g. "10GB".
+	MaxDAGSize string `json:",omitempty"`
+
+	// MaxBlockSize is the size above which a block is kept in plain, e.g.
+	// "2MB".
+	MaxBlockSize string `json:",omitempty"`
+
+	// Codecs are the only codecs of the blocks sealed, e.g. "dag-pb".
+	Codecs []string `json:",omitempty"`
+
+	// Priorities maps roots to their priority. Interrupted seals are
+	// resumed by decreasing priority, roots with a negative one aren't
+	// sealed.
+	Priorities map[string]int `json:",omitempty"`
+}
+
+// SpacexTransport configures how an sWorker is reached.
+type SpacexTransport struct {
+	// AuthToken is sent as a bearer token with every request.
+	AuthToken string `json:",omitempty"`
+
+	// CertFile and KeyFile are the client certificate presented to an
+	// https sWorker.
+	CertFile string `json:",omitempty"`
+	KeyFile  string `json:",omitempty"`
+
+	// CAFile is the certificate authority the sWorker certificate is
+	// checked against instead of the system ones.
+	CAFile string `json:",omitempty"`
+
+	// UnixSocket is the path of a unix socket requests are sent over.
+	UnixSocket string `json:",omitempty"`
+}
+
+// SpacexEndpoint is an sWorker blocks are sealed to.
+type SpacexEndpoint struct {
+	// Name identifies the sWorker in the replica paths kept in the repo, it
+	// must not change once blocks were sealed to it.
+	Name string
+
+	// URL is the sWorker API url, e.g. "http://127.0.0.1:12222/api/v0".
+	URL string
+
+	// Weight is the share of the replicas placed on this sWorker, relative
+	// to the other ones. Defaults to 1.
+	Weight int `json:",omitempty"`
+
+	SpacexTransport
+}
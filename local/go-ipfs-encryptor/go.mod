@@ -3,6 +3,9 @@ module github.com/mannheim-network/go-ipfs-encryptor
 go 1.15
 
 require (
-	github.com/dgraph-io/badger v1.6.2
 	github.com/ipfs/go-cid v0.0.7
+	github.com/ipfs/go-datastore v0.4.5
+	github.com/ipfs/go-log v1.0.4
+	github.com/ipfs/go-metrics-interface v0.0.1
+	github.com/multiformats/go-multihash v0.0.13
 )
@@ -0,0 +1,226 @@
+package spacex
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+)
+
+// SealBackend is the storage sealed blocks are written to and read back
+// from. A backend is built once per node and handed to the components that
+// seal (pinner, blockservice, bitswap) and unseal (blockstore).
+type SealBackend interface {
+	// Enabled reports whether the backend actually seals. Callers use it
+	// to decide whether blocks have to be stored in plaintext.
+	Enabled() bool
+
+	// StartSeal opens a seal session for root. It returns false if the
+	// root should not be sealed.
+	StartSeal(root cid.Cid) (bool, error)
+
+	// Seal stores value under the seal session of root and returns the
+	// path of the sealed replica.
+	Seal(root cid.Cid, newBlock bool, value []byte) (bool, string, error)
+
+	// EndSeal closes the seal session of root.
+	EndSeal(root cid.Cid) (bool, error)
+
+	// Unseal reads back the replica stored at path. The returned int is
+	// the status code of the read: 404 means the replica is unknown to the
+	// backend, 410 that it is known but lost.
+	Unseal(path string) ([]byte, error, int)
+
+	// Release tells the backend the replica stored at path is no longer
+	// referenced and may be deleted.
+	Release(path string) error
+}
+
+// ReplicaSealer is implemented by backends placing several replicas of each
+// block.
+type ReplicaSealer interface {
+	// SealReplicas stores value under the seal session of root and
+	// returns the paths of all the replicas placed.
+	SealReplicas(root cid.Cid, newBlock bool, value []byte) (bool, []string, error)
+}
+
+// SealReplicas seals value with backend and returns the paths of the
+// replicas placed, several of them if backend is a ReplicaSealer.
+func SealReplicas(backend SealBackend, root cid.Cid, newBlock bool, value []byte) (bool, []string, error) {
+	if rs, ok := backend.(ReplicaSealer); ok {
+		return rs.SealReplicas(root, newBlock, value)
+	}
+	needSeal, path, err := backend.Seal(root, newBlock, value)
+	if err != nil || !needSeal {
+		return needSeal, nil, err
+	}
+	return true, []string{path}, nil
+}
+
+// BatchSealer is implemented by backends sealing several blocks in one call.
+type BatchSealer interface {
+	// SealBatch stores values under the seal session of root and returns
+	// the paths of the replicas placed for each of them. On error, the
+	// paths returned are those of the values sealed before it failed.
+	SealBatch(root cid.Cid, newBlock bool, values [][]byte) (bool, [][]string, error)
+}
+
+// SealBatch seals values with backend in one call if it is a BatchSealer, or
+// one by one otherwise. On error, the paths returned are those of the values
+// sealed before it failed.
+func SealBatch(backend SealBackend, root cid.Cid, newBlock bool, values [][]byte) (bool, [][]string, error) {
+	if bs, ok := backend.(BatchSealer); ok {
+		return bs.SealBatch(root, newBlock, values)
+	}
+	paths := make([][]string, 0, len(values))
+	for _, value := range values {
+		needSeal, p, err := SealReplicas(backend, root, newBlock, value)
+		if err != nil {
+			return len(paths) > 0, paths, err
+		}
+		if !needSeal {
+			return false, nil, nil
+		}
+		paths = append(paths, p)
+	}
+	return true, paths, nil
+}
+
+// ContextBackend is implemented by backends whose calls can be given up
+// before they complete, e.g. once the caller went away.
+type ContextBackend interface {
+	// SealContext is Seal, given up once ctx is done.
+	SealContext(ctx context.Context, root cid.Cid, newBlock bool, value []byte) (bool, string, error)
+
+	// UnsealContext is Unseal, given up once ctx is done.
+	UnsealContext(ctx context.Context, path string) ([]byte, error, int)
+
+	// ReleaseContext is Release, given up once ctx is done.
+	ReleaseContext(ctx context.Context, path string) error
+}
+
+// SealContext seals value with backend, under ctx if it is a ContextBackend.
+func SealContext(ctx context.Context, backend SealBackend, root cid.Cid, newBlock bool, value []byte) (bool, string, error) {
+	if cb, ok := backend.(ContextBackend); ok {
+		return cb.SealContext(ctx, root, newBlock, value)
+	}
+	return backend.Seal(root, newBlock, value)
+}
+
+// UnsealContext unseals the replica at path with backend, under ctx if it is
+// a ContextBackend.
+func UnsealContext(ctx context.Context, backend SealBackend, path string) ([]byte, error, int) {
+	if cb, ok := backend.(ContextBackend); ok {
+		return cb.UnsealContext(ctx, path)
+	}
+	return backend.Unseal(path)
+}
+
+// ReleaseContext releases the replica at path with backend, under ctx if it
+// is a ContextBackend.
+func ReleaseContext(ctx context.Context, backend SealBackend, path string) error {
+	if cb, ok := backend.(ContextBackend); ok {
+		return cb.ReleaseContext(ctx, path)
+	}
+	return backend.Release(path)
+}
+
+// ReplicaChecker is implemented by backends which can tell ahead that a
+// replica is kept somewhere currently unreachable.
+type ReplicaChecker interface {
+	// ReplicaHealthy reports whether the replica at path is expected to
+	// be readable.
+	ReplicaHealthy(path string) bool
+}
+
+// ReplicaHealthy reports whether the replica at path is expected to be
+// readable from backend, true if it isn't a ReplicaChecker.
+func ReplicaHealthy(backend SealBackend, path string) bool {
+	if rc, ok := backend.(ReplicaChecker); ok {
+		return rc.ReplicaHealthy(path)
+	}
+	return true
+}
+
+// The reasons replicas are pruned from sealed stubs for.
+const (
+	// PruneMissing is for replicas the backend can't find (404).
+	PruneMissing = "missing"
+
+	// PruneLost is for replicas the backend lost (410), only pruned by
+	// scrubs.
+	PruneLost = "lost"
+
+	// PruneCorrupt is for replicas which unsealed to data not matching
+	// their block.
+	PruneCorrupt = "corrupt"
+)
+
+// PruneObserver is implemented by backends recording the replicas pruned
+// from sealed stubs.
+type PruneObserver interface {
+	// ObservePrune records a replica pruned for reason.
+	ObservePrune(reason string)
+}
+
+// ObservePrune records a replica pruned for reason on backend, if it is a
+// PruneObserver.
+func ObservePrune(backend SealBackend, reason string) {
+	if po, ok := backend.(PruneObserver); ok {
+		po.ObservePrune(reason)
+	}
+}
+
+// RetryCounter is implemented by backends retrying failed calls.
+type RetryCounter interface {
+	// Retries returns the number of calls retried so far.
+	Retries() uint64
+}
+
+// Retries returns the number of calls backend retried so far, 0 if it isn't
+// a RetryCounter.
+func Retries(backend SealBackend) uint64 {
+	if rc, ok := backend.(RetryCounter); ok {
+		return rc.Retries()
+	}
+	return 0
+}
+
+var (
+	_ ContextBackend = (*SWorker)(nil)
+	_ SealBackend    = (*SWorker)(nil)
+	_ SealBackend    = (*noopBackend)(nil)
+	_ SealBackend    = (*LocalBackend)(nil)
+)
+
+// noopBackend never seals, it is used when no sealing is configured.
+type noopBackend struct{}
+
+// NewNoopBackend returns a backend which doesn't seal anything.
+func NewNoopBackend() SealBackend {
+	return noopBackend{}
+}
+
+func (noopBackend) Enabled() bool {
+	return false
+}
+
+func (noopBackend) StartSeal(root cid.Cid) (bool, error) {
+	return false, nil
+}
+
+func (noopBackend) Seal(root cid.Cid, newBlock bool, value []byte) (bool, string, error) {
+	return false, "", nil
+}
+
+func (noopBackend) EndSeal(root cid.Cid) (bool, error) {
+	return false, nil
+}
+
+func (noopBackend) Unseal(path string) ([]byte, error, int) {
+	return nil, fmt.Errorf("Unseal missing spacex config"), 0
+}
+
+func (noopBackend) Release(path string) error {
+	return nil
+}
@@ -0,0 +1,156 @@
+package spacex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrNoCapacity is wrapped by the errors of reservations a backend has no
+// room for.
+var ErrNoCapacity = errors.New("not enough sealing capacity")
+
+// CapacityPollInterval is the time between two capacity queries of a
+// reservation waiting for room.
+var CapacityPollInterval = 10 * time.Second
+
+// CapacityReserver is implemented by backends which can tell whether they
+// have room for a DAG before its seal session is opened.
+type CapacityReserver interface {
+	// Reserve sets size bytes aside for the seal session of root, to be
+	// opened next. It fails with an error wrapping ErrNoCapacity if the
+	// backend has no room for them. The bytes are given back by EndSeal or
+	// Unreserve.
+	Reserve(ctx context.Context, root cid.Cid, size uint64) error
+
+	// Unreserve gives back the bytes set aside for root, e.g. when its
+	// seal session couldn't be opened.
+	Unreserve(root cid.Cid)
+}
+
+// Reserve sets size bytes aside for root on backend, if it is a
+// CapacityReserver. A size of 0 stands for an unknown one and is always
+// granted.
+func Reserve(ctx context.Context, backend SealBackend, root cid.Cid, size uint64) error {
+	if cr, ok := backend.(CapacityReserver); ok {
+		return cr.Reserve(ctx, root, size)
+	}
+	return nil
+}
+
+// Unreserve gives back the bytes set aside for root on backend, if it is a
+// CapacityReserver.
+func Unreserve(backend SealBackend, root cid.Cid) {
+	if cr, ok := backend.(CapacityReserver); ok {
+		cr.Unreserve(root)
+	}
+}
+
+// capacityLedger keeps the bytes set aside for the seal sessions of a
+// backend. The bytes sealed under a root are taken off its reservation, as
+// they are from then on missing from the room the backend reports.
+type capacityLedger struct {
+	lock     sync.Mutex
+	reserved map[cid.Cid]uint64
+	total    uint64
+
+	// freed is closed, and replaced, whenever a reservation is given back.
+	freed chan struct{}
+}
+
+func newCapacityLedger() *capacityLedger {
+	return &capacityLedger{
+		reserved: make(map[cid.Cid]uint64),
+		freed:    make(chan struct{}),
+	}
+}
+
+// reserve sets size bytes aside for root once free, which returns the room
+// left on the backend or false if it can't tell, leaves enough of it besides
+// the other reservations. It waits up to wait for room before failing with
+// ErrNoCapacity.
+func (l *capacityLedger) reserve(ctx context.Context, root cid.Cid, size uint64, wait time.Duration, free func() (uint64, bool, error)) error {
+	if size == 0 {
+		return nil
+	}
+
+	var deadline <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		room, known, err := free()
+		if err != nil {
+			return err
+		}
+
+		l.lock.Lock()
+		if !known || (room >= l.total && room-l.total >= size) {
+			l.reserved[root] += size
+			l.total += size
+			l.lock.Unlock()
+			return nil
+		}
+		noCapacity := fmt.Errorf("%w: %s needs %d bytes, %d are left of which %d are reserved", ErrNoCapacity, root, size, room, l.total)
+		freed := l.freed
+		l.lock.Unlock()
+
+		if wait <= 0 {
+			return noCapacity
+		}
+		poll := time.NewTimer(CapacityPollInterval)
+		select {
+		case <-freed:
+		case <-poll.C:
+		case <-deadline:
+			poll.Stop()
+			return noCapacity
+		case <-ctx.Done():
+			poll.Stop()
+			return ctx.Err()
+		}
+		poll.Stop()
+	}
+}
+
+// consume takes n sealed bytes off the reservation of root.
+func (l *capacityLedger) consume(root cid.Cid, n uint64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	r, ok := l.reserved[root]
+	if !ok {
+		return
+	}
+	if n > r {
+		n = r
+	}
+	l.reserved[root] = r - n
+	l.total -= n
+}
+
+// unreserve gives back the reservation of root.
+func (l *capacityLedger) unreserve(root cid.Cid) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	r, ok := l.reserved[root]
+	if !ok {
+		return
+	}
+	delete(l.reserved, root)
+	l.total -= r
+	close(l.freed)
+	l.freed = make(chan struct{})
+}
+
+// reservedBytes returns the number of bytes currently set aside.
+func (l *capacityLedger) reservedBytes() uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.total
+}
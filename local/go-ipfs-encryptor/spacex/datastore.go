@@ -0,0 +1,227 @@
+package spacex
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+)
+
+// ErrTxnUnsupported is returned by SealedDatastore.NewTransaction when its
+// child doesn't support transactions.
+var ErrTxnUnsupported = errors.New("datastore doesn't support transactions")
+
+// SealedDatastore is a datastore decorator keeping the stubs of sealed blocks
+// in its child. A sealed block put to it, as written by a sealing blockstore,
+// adds its replica to the stub already stored under the key, or replaces the
+// plain block; GetSize returns the size of the block a stub stands for. Any
+// other value is stored as is.
+type SealedDatastore struct {
+	child ds.Datastore
+
+	// mu serializes the read-modify-write of stubs.
+	mu sync.Mutex
+}
+
+var _ ds.Batching = (*SealedDatastore)(nil)
+var _ ds.PersistentDatastore = (*SealedDatastore)(nil)
+
+// NewSealedDatastore wraps child so that it can store sealed blocks.
+func NewSealedDatastore(child ds.Datastore) *SealedDatastore {
+	return &SealedDatastore{child: child}
+}
+
+// Child returns the datastore wrapped by d.
+func (d *SealedDatastore) Child() ds.Datastore {
+	return d.child
+}
+
+// mergeSealed returns the value to store under key for value, read from get
+// what is stored there.
+func mergeSealed(get func(ds.Key) ([]byte, error), key ds.Key, value []byte) ([]byte, error) {
+	ok, sb := TryGetSealedBlock(value)
+	if !ok {
+		return value, nil
+	}
+	data, err := get(key)
+	if err == ds.ErrNotFound {
+		return sb.ToSealedInfo().Bytes(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ok, si := TryGetSealedInfo(data); ok {
+		return si.AddSealedBlock(*sb).Bytes(), nil
+	}
+	return sb.ToSealedInfo().Bytes(), nil
+}
+
+func sealedSize(get func(ds.Key) ([]byte, error), key ds.Key) (int, error) {
+	value, err := get(key)
+	if err != nil {
+		return -1, err
+	}
+	size, err := ValueSize(value)
+	if err == ErrNoReplica {
+		return -1, ds.ErrNotFound
+	}
+	return size, err
+}
+
+func (d *SealedDatastore) Put(key ds.Key, value []byte) error {
+	if !HasSealMagic(value) {
+		return d.child.Put(key, value)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	value, err := mergeSealed(d.child.Get, key, value)
+	if err != nil {
+		return err
+	}
+	return d.child.Put(key, value)
+}
+
+func (d *SealedDatastore) Sync(prefix ds.Key) error {
+	return d.child.Sync(prefix)
+}
+
+func (d *SealedDatastore) Get(key ds.Key) ([]byte, error) {
+	return d.child.Get(key)
+}
+
+func (d *SealedDatastore) Has(key ds.Key) (bool, error) {
+	return d.child.Has(key)
+}
+
+func (d *SealedDatastore) GetSize(key ds.Key) (int, error) {
+	return sealedSize(d.child.Get, key)
+}
+
+func (d *SealedDatastore) Delete(key ds.Key) error {
+	return d.child.Delete(key)
+}
+
+func (d *SealedDatastore) Query(q dsq.Query) (dsq.Results, error) {
+	return d.child.Query(q)
+}
+
+func (d *SealedDatastore) Check() error {
+	if c, ok := d.child.(ds.CheckedDatastore); ok {
+		return c.Check()
+	}
+	return nil
+}
+
+func (d *SealedDatastore) Scrub() error {
+	if c, ok := d.child.(ds.ScrubbedDatastore); ok {
+		return c.Scrub()
+	}
+	return nil
+}
+
+func (d *SealedDatastore) CollectGarbage() error {
+	if c, ok := d.child.(ds.GCDatastore); ok {
+		return c.CollectGarbage()
+	}
+	return nil
+}
+
+func (d *SealedDatastore) DiskUsage() (uint64, error) {
+	return ds.DiskUsage(d.child)
+}
+
+func (d *SealedDatastore) Close() error {
+	if c, ok := d.child.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// sealedBatch merges the sealed blocks put to it with the stubs stored in
+// the datastore or put earlier in the batch.
+type sealedBatch struct {
+	b ds.Batch
+	d *SealedDatastore
+
+	pending map[ds.Key][]byte
+}
+
+func (d *SealedDatastore) Batch() (ds.Batch, error) {
+	bds, ok := d.child.(ds.Batching)
+	if !ok {
+		return nil, ds.ErrBatchUnsupported
+	}
+	b, err := bds.Batch()
+	if err != nil {
+		return nil, err
+	}
+	return &sealedBatch{b: b, d: d, pending: make(map[ds.Key][]byte)}, nil
+}
+
+func (b *sealedBatch) get(key ds.Key) ([]byte, error) {
+	if value, ok := b.pending[key]; ok {
+		if value == nil {
+			return nil, ds.ErrNotFound
+		}
+		return value, nil
+	}
+	return b.d.child.Get(key)
+}
+
+func (b *sealedBatch) Put(key ds.Key, value []byte) error {
+	if HasSealMagic(value) {
+		var err error
+		b.d.mu.Lock()
+		value, err = mergeSealed(b.get, key, value)
+		b.d.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	b.pending[key] = value
+	return b.b.Put(key, value)
+}
+
+func (b *sealedBatch) Delete(key ds.Key) error {
+	b.pending[key] = nil
+	return b.b.Delete(key)
+}
+
+func (b *sealedBatch) Commit() error {
+	return b.b.Commit()
+}
+
+// sealedTxn merges the sealed blocks put to it with the stubs read through
+// the transaction.
+type sealedTxn struct {
+	ds.Txn
+}
+
+// NewTransaction opens a transaction of the child, which must be a
+// ds.TxnDatastore.
+func (d *SealedDatastore) NewTransaction(readOnly bool) (ds.Txn, error) {
+	tds, ok := d.child.(ds.TxnDatastore)
+	if !ok {
+		return nil, ErrTxnUnsupported
+	}
+	txn, err := tds.NewTransaction(readOnly)
+	if err != nil {
+		return nil, err
+	}
+	return &sealedTxn{txn}, nil
+}
+
+func (t *sealedTxn) Put(key ds.Key, value []byte) error {
+	value, err := mergeSealed(t.Txn.Get, key, value)
+	if err != nil {
+		return err
+	}
+	return t.Txn.Put(key, value)
+}
+
+func (t *sealedTxn) GetSize(key ds.Key) (int, error) {
+	return sealedSize(t.Txn.Get, key)
+}
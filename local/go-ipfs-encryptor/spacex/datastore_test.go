@@ -0,0 +1,86 @@
+package spacex
+
+import (
+	"bytes"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+func TestSealedDatastore(t *testing.T) {
+	d := NewSealedDatastore(dssync.MutexWrap(ds.NewMapDatastore()))
+	key := ds.NewKey("/blocks/a")
+
+	if err := d.Put(key, []byte("plain")); err != nil {
+		t.Fatal(err)
+	}
+	if size, err := d.GetSize(key); err != nil || size != 5 {
+		t.Fatalf("expected the plain size, got %d, %v", size, err)
+	}
+
+	// The stub replaces the plain block, then collects the replicas.
+	for _, path := range []string{"p1", "p2", "p1"} {
+		if err := d.Put(key, (&SealedBlock{Path: path, Size: 5}).Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	value, err := d.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, si := TryGetSealedInfo(value)
+	if !ok || len(si.Sbs) != 2 || si.Sbs[0].Path != "p1" || si.Sbs[1].Path != "p2" {
+		t.Fatalf("expected a stub with p1 and p2, got %v", si)
+	}
+	if size, err := d.GetSize(key); err != nil || size != 5 {
+		t.Fatalf("expected the sealed size, got %d, %v", size, err)
+	}
+
+	// A batch sees the stubs it holds.
+	other := ds.NewKey("/blocks/b")
+	b, err := d.Batch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"p3", "p4"} {
+		if err := b.Put(other, (&SealedBlock{Path: path, Size: 7}).Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Put(key, (&SealedBlock{Path: "p5", Size: 5}).Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	for k, n := range map[ds.Key]int{key: 3, other: 2} {
+		value, err := d.Get(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, si := TryGetSealedInfo(value); !ok || len(si.Sbs) != n {
+			t.Fatalf("expected %d replicas for %s, got %v", n, k, si)
+		}
+	}
+
+	// A stub left without replicas is missing.
+	if err := d.Put(key, (&SealedInfo{}).Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.GetSize(key); err != ds.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	raw := EscapeRaw(append(append([]byte{}, sealMagic...), "data"...))
+	if err := d.Put(key, raw); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := d.Get(key); !bytes.Equal(value, raw) {
+		t.Fatal("escaped block content was changed")
+	}
+
+	if _, err := d.NewTransaction(false); err != ErrTxnUnsupported {
+		t.Fatalf("expected ErrTxnUnsupported, got %v", err)
+	}
+}
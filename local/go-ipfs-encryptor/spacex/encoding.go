@@ -0,0 +1,110 @@
+package spacex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// Sealed values are stored in a binary envelope: the magic prefix, the format
+// version and the kind of value, followed by the payload. Block content which
+// starts with the magic prefix is stored in a raw envelope, see EscapeRaw, so
+// that no block can be read back as a sealed value.
+var sealMagic = []byte("\xffSPXSEAL")
+
+// SealFormatVersion is the version of the envelope written by this package.
+const SealFormatVersion = 1
+
+const (
+	kindSealedBlock = 'b'
+	kindSealedInfo  = 'i'
+	kindRaw         = 'r'
+)
+
+var errShortValue = errors.New("sealed value is truncated")
+
+func envelope(kind byte) []byte {
+	buf := make([]byte, 0, len(sealMagic)+2+32)
+	buf = append(buf, sealMagic...)
+	return append(buf, SealFormatVersion, kind)
+}
+
+func openEnvelope(value []byte) (byte, []byte, bool) {
+	if !bytes.HasPrefix(value, sealMagic) || len(value) < len(sealMagic)+2 {
+		return 0, nil, false
+	}
+	if value[len(sealMagic)] != SealFormatVersion {
+		return 0, nil, false
+	}
+	return value[len(sealMagic)+1], value[len(sealMagic)+2:], true
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func readUvarint(buf []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return 0, nil, errShortValue
+	}
+	return v, buf[n:], nil
+}
+
+func encodeSealedBlock(buf []byte, sb *SealedBlock) []byte {
+	buf = appendUvarint(buf, uint64(sb.Size))
+	buf = appendUvarint(buf, uint64(len(sb.Path)))
+	return append(buf, sb.Path...)
+}
+
+func decodeSealedBlock(buf []byte) (*SealedBlock, []byte, error) {
+	size, buf, err := readUvarint(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	n, buf, err := readUvarint(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n > uint64(len(buf)) {
+		return nil, nil, errShortValue
+	}
+	return &SealedBlock{Path: string(buf[:n]), Size: int(size)}, buf[n:], nil
+}
+
+// HasSealMagic reports whether value starts with the envelope magic prefix.
+func HasSealMagic(value []byte) bool {
+	return bytes.HasPrefix(value, sealMagic)
+}
+
+// EscapeRaw returns how block content is to be stored: as is, or in a raw
+// envelope if it starts with the magic prefix.
+func EscapeRaw(data []byte) []byte {
+	if !HasSealMagic(data) {
+		return data
+	}
+	return append(envelope(kindRaw), data...)
+}
+
+// UnescapeRaw returns the block content of a stored value which isn't a
+// sealed stub, undoing EscapeRaw.
+func UnescapeRaw(value []byte) []byte {
+	if kind, payload, ok := openEnvelope(value); ok && kind == kindRaw {
+		return payload
+	}
+	return value
+}
+
+// ValueSize returns the size of the block stored as value. It returns
+// ErrNoReplica for a sealed stub left without replicas.
+func ValueSize(value []byte) (int, error) {
+	if ok, si := TryGetSealedInfo(value); ok {
+		if len(si.Sbs) == 0 {
+			return -1, ErrNoReplica
+		}
+		return si.Sbs[0].Size, nil
+	}
+	return len(UnescapeRaw(value)), nil
+}
@@ -0,0 +1,447 @@
+package spacex
+
+import (
+	"context"
+	"encoding/base32"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+var (
+	// JournalPrefix namespaces the seal journal in the repo datastore
+	JournalPrefix = ds.NewKey("/spacex/journal")
+
+	// ReplicasPrefix namespaces the replicas sealed per root
+	ReplicasPrefix = ds.NewKey("/spacex/replicas")
+
+	// FailuresPrefix namespaces the last seal error per root
+	FailuresPrefix = ds.NewKey("/spacex/failures")
+
+	// UnpinnedPrefix namespaces the replicas sealed under roots since
+	// unpinned
+	UnpinnedPrefix = ds.NewKey("/spacex/unpinned")
+)
+
+// SealJournal persists open seal sessions, so that sessions interrupted by a
+// crash can be resumed or ended on the next start, and the replicas sealed
+// under each root, so that the seal state of a pin can be told. Replicas are
+// not released with their root, but with the stub pointing to them.
+//
+// Every open root is stored under /spacex/journal/<root>, the blocks already
+// stored as sealed stubs under /spacex/journal/<root>/<block>. Replicas are
+// kept under /spacex/replicas/<root>/<path>, after the session ended too.
+// The error the last seal of a root failed with is kept under
+// /spacex/failures/<root> until the root is sealed again. Once a root is
+// unpinned, its replicas are moved to /spacex/unpinned/<root>/<path>, where
+// they are kept until released on demand.
+type SealJournal struct {
+	dstore ds.Datastore
+}
+
+// NewSealJournal returns the seal journal kept in dstore.
+func NewSealJournal(dstore ds.Datastore) *SealJournal {
+	return &SealJournal{dstore: dstore}
+}
+
+func rootKey(root cid.Cid) ds.Key {
+	return JournalPrefix.ChildString(root.String())
+}
+
+// SessionKind tells what opened a seal session, so that an interrupted one
+// can be resumed the way it was opened.
+type SessionKind byte
+
+const (
+	// SessionPin is opened by pinning its root.
+	SessionPin SessionKind = iota
+	// SessionUpdate is opened by updating a pin to its root.
+	SessionUpdate
+	// SessionSealRoot is opened by sealing the DAG of a root already pinned.
+	SessionSealRoot
+	// SessionReseal is opened by the scrubber to seal lost blocks again.
+	SessionReseal
+)
+
+// Session is what the journal keeps of an open seal session.
+type Session struct {
+	Kind SessionKind
+
+	// From is the pin updated from, and Unpin whether it is unpinned by
+	// the update, for SessionUpdate.
+	From  cid.Cid
+	Unpin bool
+}
+
+func (s Session) bytes() []byte {
+	b := []byte{byte(s.Kind)}
+	if s.Kind != SessionUpdate {
+		return b
+	}
+	if s.Unpin {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	return append(b, s.From.Bytes()...)
+}
+
+func parseSession(b []byte) (Session, error) {
+	// Journals written before sessions had kinds only held pins.
+	if len(b) == 0 {
+		return Session{Kind: SessionPin}, nil
+	}
+	s := Session{Kind: SessionKind(b[0])}
+	if s.Kind != SessionUpdate {
+		return s, nil
+	}
+	if len(b) < 2 {
+		return s, errors.New("truncated seal session")
+	}
+	s.Unpin = b[1] == 1
+	from, err := cid.Cast(b[2:])
+	if err != nil {
+		return s, fmt.Errorf("seal session: %s", err)
+	}
+	s.From = from
+	return s, nil
+}
+
+// GenSealContext records root as open by a pin and attaches a journaled seal
+// session to ctx. If root is already in the journal, the session picks up the
+// store flags recorded so far.
+func (j *SealJournal) GenSealContext(ctx context.Context, backend SealBackend, root cid.Cid) (context.Context, error) {
+	return j.GenSessionContext(ctx, backend, root, Session{Kind: SessionPin})
+}
+
+// GenSessionContext is GenSealContext for a session opened as s.
+func (j *SealJournal) GenSessionContext(ctx context.Context, backend SealBackend, root cid.Cid, s Session) (context.Context, error) {
+	if err := j.dstore.Put(rootKey(root), s.bytes()); err != nil {
+		return nil, err
+	}
+
+	ss := newSealSession(backend, root)
+	ss.journal = j
+	ss.Progress = GetSealProgress(ctx)
+	flags, err := j.StoreFlags(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range flags {
+		ss.stored[c] = true
+	}
+
+	return context.WithValue(ctx, sealContextKey{}, ss), nil
+}
+
+// pathEncoding turns replica paths into single datastore key components.
+var pathEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func replicaKey(root cid.Cid, path string) ds.Key {
+	return ReplicasPrefix.ChildString(root.String()).ChildString(pathEncoding.EncodeToString([]byte(path)))
+}
+
+// SetStoreFlag records that the sealed stub of blockCid, pointing to the
+// replica at path, was stored while sealing root.
+func (j *SealJournal) SetStoreFlag(root cid.Cid, blockCid cid.Cid, path string) error {
+	if err := j.dstore.Put(replicaKey(root, path), []byte(path)); err != nil {
+		return err
+	}
+	return j.dstore.Put(rootKey(root).ChildString(blockCid.String()), []byte{})
+}
+
+// Replicas returns the paths of the replicas sealed under root.
+func (j *SealJournal) Replicas(root cid.Cid) ([]string, error) {
+	return j.paths(ReplicasPrefix.ChildString(root.String()))
+}
+
+func (j *SealJournal) paths(prefix ds.Key) ([]string, error) {
+	keys, err := j.keys(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(keys))
+	for _, k := range keys {
+		path, err := pathEncoding.DecodeString(k.Name())
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, string(path))
+	}
+	return paths, nil
+}
+
+// DropReplicas forgets the replicas sealed under root.
+func (j *SealJournal) DropReplicas(root cid.Cid) error {
+	return j.drop(ReplicasPrefix.ChildString(root.String()))
+}
+
+func (j *SealJournal) drop(prefix ds.Key) error {
+	keys, err := j.keys(prefix)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := j.dstore.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropReplica forgets the replica at path sealed under root.
+func (j *SealJournal) DropReplica(root cid.Cid, path string) error {
+	return j.dstore.Delete(replicaKey(root, path))
+}
+
+// MoveReplicas records the replicas sealed under from as sealed under to,
+// so that they count as sealed under to instead.
+func (j *SealJournal) MoveReplicas(from, to cid.Cid) error {
+	paths, err := j.Replicas(from)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := j.dstore.Put(replicaKey(to, path), []byte(path)); err != nil {
+			return err
+		}
+		if err := j.dstore.Delete(replicaKey(from, path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unpinnedKey(root cid.Cid, path string) ds.Key {
+	return UnpinnedPrefix.ChildString(root.String()).ChildString(pathEncoding.EncodeToString([]byte(path)))
+}
+
+// Unpin moves the replicas sealed under root aside, once root is unpinned:
+// they no longer count as sealed under root, but are kept so that they can be
+// released on demand.
+func (j *SealJournal) Unpin(root cid.Cid) error {
+	paths, err := j.Replicas(root)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := j.UnpinReplica(root, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnpinReplica moves the replica at path sealed under root aside, as Unpin
+// does for all of them.
+func (j *SealJournal) UnpinReplica(root cid.Cid, path string) error {
+	if err := j.dstore.Put(unpinnedKey(root, path), []byte(path)); err != nil {
+		return err
+	}
+	return j.dstore.Delete(replicaKey(root, path))
+}
+
+// Unpinned returns the paths of the replicas moved aside by the unpin of
+// root.
+func (j *SealJournal) Unpinned(root cid.Cid) ([]string, error) {
+	return j.paths(UnpinnedPrefix.ChildString(root.String()))
+}
+
+// DropUnpinned forgets the replicas moved aside by the unpin of root.
+func (j *SealJournal) DropUnpinned(root cid.Cid) error {
+	return j.drop(UnpinnedPrefix.ChildString(root.String()))
+}
+
+func failureKey(root cid.Cid) ds.Key {
+	return FailuresPrefix.ChildString(root.String())
+}
+
+// SetFailure records that sealing root failed with err.
+func (j *SealJournal) SetFailure(root cid.Cid, err error) error {
+	return j.dstore.Put(failureKey(root), []byte(err.Error()))
+}
+
+// ClearFailure forgets the seal error recorded for root, if any.
+func (j *SealJournal) ClearFailure(root cid.Cid) error {
+	return j.dstore.Delete(failureKey(root))
+}
+
+// Failure returns the error the last seal of root failed with, or "" if
+// none is recorded.
+func (j *SealJournal) Failure(root cid.Cid) (string, error) {
+	v, err := j.dstore.Get(failureKey(root))
+	if err == ds.ErrNotFound {
+		return "", nil
+	}
+	return string(v), err
+}
+
+// Sealed reports whether the last seal of root completed: its session isn't
+// open anymore, it didn't fail and replicas are recorded for it.
+func (j *SealJournal) Sealed(root cid.Cid) (bool, error) {
+	open, err := j.dstore.Has(rootKey(root))
+	if err != nil || open {
+		return false, err
+	}
+	failure, err := j.Failure(root)
+	if err != nil || failure != "" {
+		return false, err
+	}
+	replicas, err := childKeys(j.dstore, ReplicasPrefix.ChildString(root.String()), 1)
+	if err != nil {
+		return false, err
+	}
+	return len(replicas) > 0, nil
+}
+
+// StoreFlags returns the blocks recorded as stored while sealing root.
+func (j *SealJournal) StoreFlags(root cid.Cid) ([]cid.Cid, error) {
+	keys, err := j.keys(rootKey(root))
+	if err != nil {
+		return nil, err
+	}
+
+	flags := make([]cid.Cid, 0, len(keys))
+	for _, k := range keys {
+		c, err := cid.Decode(k.Name())
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, c)
+	}
+	return flags, nil
+}
+
+// Finish drops root and its store flags from the journal.
+func (j *SealJournal) Finish(root cid.Cid) error {
+	keys, err := j.keys(rootKey(root))
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := j.dstore.Delete(k); err != nil {
+			return err
+		}
+	}
+	return j.dstore.Delete(rootKey(root))
+}
+
+// Session returns what opened the seal session of root, which must be open.
+func (j *SealJournal) Session(root cid.Cid) (Session, error) {
+	b, err := j.dstore.Get(rootKey(root))
+	if err != nil {
+		return Session{}, err
+	}
+	return parseSession(b)
+}
+
+// Roots returns the roots of all seal sessions still open in the journal.
+func (j *SealJournal) Roots() ([]cid.Cid, error) {
+	keys, err := j.keys(JournalPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []cid.Cid
+	for _, k := range keys {
+		c, err := cid.Decode(k.Name())
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, c)
+	}
+	return roots, nil
+}
+
+// SealedRoots returns the roots which have replicas recorded, with the
+// number of replicas of each.
+func (j *SealJournal) SealedRoots() (map[cid.Cid]int, error) {
+	results, err := j.dstore.Query(query.Query{
+		Prefix:   ReplicasPrefix.String(),
+		KeysOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	roots := make(map[cid.Cid]int)
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		parent := ds.RawKey(r.Key).Parent()
+		if !parent.Parent().Equal(ReplicasPrefix) {
+			continue
+		}
+		c, err := cid.Decode(parent.Name())
+		if err != nil {
+			return nil, err
+		}
+		roots[c]++
+	}
+	return roots, nil
+}
+
+// ReplicaRoots maps the paths of the replicas recorded in the journal to the
+// root they were sealed under.
+func (j *SealJournal) ReplicaRoots() (map[string]cid.Cid, error) {
+	results, err := j.dstore.Query(query.Query{Prefix: ReplicasPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	roots := make(map[string]cid.Cid)
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		parent := ds.RawKey(r.Key).Parent()
+		if !parent.Parent().Equal(ReplicasPrefix) {
+			continue
+		}
+		c, err := cid.Decode(parent.Name())
+		if err != nil {
+			return nil, err
+		}
+		roots[string(r.Value)] = c
+	}
+	return roots, nil
+}
+
+func (j *SealJournal) keys(prefix ds.Key) ([]ds.Key, error) {
+	return childKeys(j.dstore, prefix, 0)
+}
+
+// childKeys lists up to limit direct children of prefix, all of them if limit
+// is 0.
+func childKeys(dstore ds.Datastore, prefix ds.Key, limit int) ([]ds.Key, error) {
+	results, err := dstore.Query(query.Query{
+		Prefix:   prefix.String(),
+		KeysOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var keys []ds.Key
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		k := ds.RawKey(r.Key)
+		if k.Parent().Equal(prefix) {
+			keys = append(keys, k)
+		}
+		if limit > 0 && len(keys) >= limit {
+			break
+		}
+	}
+	return keys, nil
+}
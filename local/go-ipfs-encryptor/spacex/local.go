@@ -0,0 +1,136 @@
+package spacex
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/mannheim-network/go-ipfs-encryptor/utils"
+)
+
+// LocalBackend is an in-process backend keeping sealed replicas in memory.
+// It is meant for tests and for running several nodes in one process.
+type LocalBackend struct {
+	lock     sync.Mutex
+	sessions map[cid.Cid]bool
+	replicas map[string][]byte
+	used     uint64
+	size     uint64
+
+	capacity *capacityLedger
+}
+
+var _ CapacityReserver = (*LocalBackend)(nil)
+
+// NewLocalBackend returns an empty in-memory backend.
+func NewLocalBackend() *LocalBackend {
+	return &LocalBackend{
+		sessions: make(map[cid.Cid]bool),
+		replicas: make(map[string][]byte),
+		capacity: newCapacityLedger(),
+	}
+}
+
+// SetCapacity bounds the number of bytes the backend holds, 0 removes the
+// bound.
+func (lb *LocalBackend) SetCapacity(size uint64) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	lb.size = size
+}
+
+func (lb *LocalBackend) free() (uint64, bool, error) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	if lb.size == 0 {
+		return 0, false, nil
+	}
+	if lb.used >= lb.size {
+		return 0, true, nil
+	}
+	return lb.size - lb.used, true, nil
+}
+
+// Reserve sets size bytes aside for the seal session of root if the backend
+// has room for them besides the other reservations.
+func (lb *LocalBackend) Reserve(ctx context.Context, root cid.Cid, size uint64) error {
+	return lb.capacity.reserve(ctx, root, size, 0, lb.free)
+}
+
+// Unreserve gives back the bytes set aside for root.
+func (lb *LocalBackend) Unreserve(root cid.Cid) {
+	lb.capacity.unreserve(root)
+}
+
+// Reserved returns the number of bytes currently set aside.
+func (lb *LocalBackend) Reserved() uint64 {
+	return lb.capacity.reservedBytes()
+}
+
+func (lb *LocalBackend) Enabled() bool {
+	return true
+}
+
+func (lb *LocalBackend) StartSeal(root cid.Cid) (bool, error) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	lb.sessions[root] = true
+	return true, nil
+}
+
+func (lb *LocalBackend) Seal(root cid.Cid, newBlock bool, value []byte) (bool, string, error) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	if !lb.sessions[root] {
+		return false, "", fmt.Errorf("Seal: no seal session for %s", root)
+	}
+	if lb.size != 0 && lb.used+uint64(len(value)) > lb.size {
+		return false, "", fmt.Errorf("Seal: %w", ErrNoCapacity)
+	}
+
+	path := fmt.Sprintf("%s/%s", root, utils.RandStringRunes(32))
+	lb.replicas[path] = append([]byte(nil), value...)
+	lb.used += uint64(len(value))
+	lb.capacity.consume(root, uint64(len(value)))
+	return true, path, nil
+}
+
+func (lb *LocalBackend) EndSeal(root cid.Cid) (bool, error) {
+	lb.capacity.unreserve(root)
+
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	if !lb.sessions[root] {
+		return false, nil
+	}
+	delete(lb.sessions, root)
+	return true, nil
+}
+
+func (lb *LocalBackend) Unseal(path string) ([]byte, error, int) {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	data, ok := lb.replicas[path]
+	if !ok {
+		return nil, fmt.Errorf("Unseal error code is: %d", 404), 404
+	}
+	return append([]byte(nil), data...), nil, 200
+}
+
+func (lb *LocalBackend) Release(path string) error {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	if data, ok := lb.replicas[path]; ok {
+		lb.used -= uint64(len(data))
+		delete(lb.replicas, path)
+	}
+	return nil
+}
+
+// Replicas returns the number of replicas currently held by the backend.
+func (lb *LocalBackend) Replicas() int {
+	lb.lock.Lock()
+	defer lb.lock.Unlock()
+	return len(lb.replicas)
+}
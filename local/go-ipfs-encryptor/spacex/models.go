@@ -1,13 +1,19 @@
 package spacex
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"sync"
 
 	"github.com/ipfs/go-cid"
 )
 
+// ErrReplicaDropped is returned by blockstores putting a warped sealed block
+// whose replica they released instead of adding it to the stub, e.g. since
+// the stub has all the replicas it may keep. Its path must be forgotten.
+var ErrReplicaDropped = errors.New("sealed replica was released instead of stored")
+
 // Warp sealed block for putting, realize block.Block interface
 type WarpedSealedBlock struct {
 	cid  cid.Cid
@@ -15,8 +21,8 @@ type WarpedSealedBlock struct {
 }
 
 func NewWarpedSealedBlock(path string, size int, c cid.Cid) *WarpedSealedBlock {
-	bv, _ := json.Marshal(SealedBlock{Path: path, Size: size})
-	return &WarpedSealedBlock{data: bv, cid: c}
+	sb := SealedBlock{Path: path, Size: size}
+	return &WarpedSealedBlock{data: sb.Bytes(), cid: c}
 }
 
 func (b *WarpedSealedBlock) RawData() []byte {
@@ -53,19 +59,23 @@ type SealedBlock struct {
 }
 
 func TryGetSealedBlock(value []byte) (bool, *SealedBlock) {
-	sb := &SealedBlock{}
-	err := json.Unmarshal(value, sb)
-	if err != nil {
+	kind, payload, ok := openEnvelope(value)
+	if !ok || kind != kindSealedBlock {
 		return false, nil
 	}
 
-	if sb.Path == "" {
+	sb, rest, err := decodeSealedBlock(payload)
+	if err != nil || len(rest) != 0 || sb.Path == "" {
 		return false, nil
 	}
 
 	return true, sb
 }
 
+func (sb *SealedBlock) Bytes() []byte {
+	return encodeSealedBlock(envelope(kindSealedBlock), sb)
+}
+
 func (sb *SealedBlock) ToSealedInfo() *SealedInfo {
 	return &SealedInfo{Sbs: []SealedBlock{*sb}}
 }
@@ -76,73 +86,117 @@ type SealedInfo struct {
 }
 
 func (si *SealedInfo) Bytes() []byte {
-	bs, _ := json.Marshal(si)
-	return bs
+	buf := envelope(kindSealedInfo)
+	buf = appendUvarint(buf, uint64(len(si.Sbs)))
+	for i := range si.Sbs {
+		buf = encodeSealedBlock(buf, &si.Sbs[i])
+	}
+	return buf
 }
 
+// AddSealedBlock appends sb to the replicas of si, unless si already points
+// to the same path.
 func (si *SealedInfo) AddSealedBlock(sb SealedBlock) *SealedInfo {
+	if si.HasPath(sb.Path) {
+		return si
+	}
 	si.Sbs = append(si.Sbs, sb)
 	return si
 }
 
-func TryGetSealedInfo(value []byte) (bool, *SealedInfo) {
-	si := &SealedInfo{}
-	err := json.Unmarshal(value, si)
-	if err != nil {
-		return false, nil
+// HasPath reports whether si points to the replica at path.
+func (si *SealedInfo) HasPath(path string) bool {
+	for _, sb := range si.Sbs {
+		if sb.Path == path {
+			return true
+		}
 	}
-
-	return true, si
+	return false
 }
 
-func MergeSealedInfo(a *SealedInfo, b *SealedInfo) *SealedInfo {
-	si := &SealedInfo{}
-	si.Sbs = append(a.Sbs, b.Sbs...)
-	return si
-}
+// Compact removes the duplicate paths of si, then drops the replicas beyond
+// the first max ones if max is positive. It returns the paths of the dropped
+// replicas, which aren't referenced anymore, and whether si changed.
+func (si *SealedInfo) Compact(max int) ([]string, bool) {
+	kept := make([]SealedBlock, 0, len(si.Sbs))
+	seen := make(map[string]bool, len(si.Sbs))
+	for _, sb := range si.Sbs {
+		if seen[sb.Path] {
+			continue
+		}
+		seen[sb.Path] = true
+		kept = append(kept, sb)
+	}
 
-type safeSealedMap struct {
-	sync.RWMutex
-	_map map[cid.Cid]map[cid.Cid]bool
-}
+	var dropped []string
+	if max > 0 && len(kept) > max {
+		for _, sb := range kept[max:] {
+			dropped = append(dropped, sb.Path)
+		}
+		kept = kept[:max]
+	}
 
-func newSafeSealedMap() *safeSealedMap {
-	sm := new(safeSealedMap)
-	sm._map = make(map[cid.Cid]map[cid.Cid]bool)
-	return sm
+	changed := len(kept) != len(si.Sbs)
+	si.Sbs = kept
+	return dropped, changed
 }
 
-func (sm *safeSealedMap) blockExist(root cid.Cid, blockCid cid.Cid) bool {
-	sm.RLock()
-	if bs, ok := sm._map[root]; ok {
-		if _, ok = bs[blockCid]; ok {
-			sm.RUnlock()
-			return true
+func TryGetSealedInfo(value []byte) (bool, *SealedInfo) {
+	kind, payload, ok := openEnvelope(value)
+	if !ok || kind != kindSealedInfo {
+		return false, nil
+	}
+
+	n, payload, err := readUvarint(payload)
+	if err != nil || n > uint64(len(payload)) {
+		return false, nil
+	}
+	si := &SealedInfo{Sbs: make([]SealedBlock, 0, n)}
+	for i := uint64(0); i < n; i++ {
+		var sb *SealedBlock
+		sb, payload, err = decodeSealedBlock(payload)
+		if err != nil {
+			return false, nil
 		}
+		si.Sbs = append(si.Sbs, *sb)
+	}
+	if len(payload) != 0 {
+		return false, nil
 	}
-	sm.RUnlock()
-	return false
-}
 
-func (sm *safeSealedMap) addRoot(root cid.Cid) {
-	sm.Lock()
-	sm._map[root] = make(map[cid.Cid]bool)
-	sm.Unlock()
+	return true, si
 }
 
-func (sm *safeSealedMap) addBlock(root cid.Cid, blockCid cid.Cid) {
-	sm.Lock()
-	if _, ok := sm._map[root]; ok {
-		sm._map[root][blockCid] = true
-	} else {
-		sm._map[root] = make(map[cid.Cid]bool)
-		sm._map[root][blockCid] = true
+// TryGetLegacySealedInfo parses a sealed stub stored in the JSON format used
+// before the binary envelope. Plain blocks may parse as well, callers have to
+// tell them apart, e.g. by hashing the value.
+func TryGetLegacySealedInfo(value []byte) (bool, *SealedInfo) {
+	var legacy struct {
+		Sbs *[]SealedBlock `json:"b"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&legacy); err != nil || legacy.Sbs == nil || dec.More() {
+		return false, nil
 	}
-	sm.Unlock()
+	for _, sb := range *legacy.Sbs {
+		if sb.Path == "" {
+			return false, nil
+		}
+	}
+
+	return true, &SealedInfo{Sbs: *legacy.Sbs}
 }
 
-func (sm *safeSealedMap) removeRoot(root cid.Cid) {
-	sm.Lock()
-	delete(sm._map, root)
-	sm.Unlock()
+// MergeSealedInfo returns the replicas of a followed by the ones of b which
+// a doesn't point to.
+func MergeSealedInfo(a *SealedInfo, b *SealedInfo) *SealedInfo {
+	si := &SealedInfo{}
+	for _, sb := range a.Sbs {
+		si.AddSealedBlock(sb)
+	}
+	for _, sb := range b.Sbs {
+		si.AddSealedBlock(sb)
+	}
+	return si
 }
@@ -0,0 +1,217 @@
+package spacex
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ipfs/go-cid"
+)
+
+var (
+	// SealQueueSize is the number of blocks waiting to be sealed per seal
+	// session, Submit blocks once it is reached.
+	SealQueueSize = 256
+
+	// SealBatchSize is the most blocks sealed in one backend call.
+	SealBatchSize = 32
+
+	// SealWorkers is the number of batches a seal session seals at once.
+	SealWorkers = 4
+)
+
+// ErrSessionClosed is returned by Submit once the seal session is closed.
+var ErrSessionClosed = errors.New("seal session is closed")
+
+type sealItem struct {
+	cid   cid.Cid
+	value []byte
+	put   func(*WarpedSealedBlock) error
+	plain func() error
+}
+
+// Submit queues the block c holding value to be sealed under the session,
+// put then stores its sealed stub, or plain stores the block as is if the
+// backend stopped sealing meanwhile. Blocks already sealed or queued in the
+// session are skipped, so that a block shared by several parts of the DAG is
+// sealed once. Submit blocks while the queue is full, and fails once a
+// queued block failed to seal with the error it failed with.
+func (ss *SealSession) Submit(ctx context.Context, c cid.Cid, value []byte, put func(*WarpedSealedBlock) error, plain func() error) error {
+	ss.lock.Lock()
+	if ss.err != nil {
+		err := ss.err
+		ss.lock.Unlock()
+		return err
+	}
+	if ss.closed {
+		ss.lock.Unlock()
+		return ErrSessionClosed
+	}
+	if ss.stored[c] || ss.queued[c] {
+		ss.lock.Unlock()
+		return nil
+	}
+	ss.queued[c] = true
+	ss.pending.Add(1)
+	ss.lock.Unlock()
+
+	ss.startOnce.Do(func() {
+		for i := 0; i < SealWorkers; i++ {
+			ss.workers.Add(1)
+			go ss.work()
+		}
+	})
+
+	select {
+	case ss.queue <- sealItem{cid: c, value: value, put: put, plain: plain}:
+		return nil
+	case <-ctx.Done():
+		ss.lock.Lock()
+		delete(ss.queued, c)
+		ss.lock.Unlock()
+		ss.pending.Done()
+		return ctx.Err()
+	}
+}
+
+// Close waits for the queued blocks to be sealed and stops the workers of
+// the session. It returns the error the first block failing to seal failed
+// with. Nothing can be submitted to the session once closed; it must be
+// closed before its seal is ended.
+func (ss *SealSession) Close() error {
+	ss.lock.Lock()
+	closed := ss.closed
+	ss.closed = true
+	ss.lock.Unlock()
+
+	if !closed {
+		ss.pending.Wait()
+		close(ss.quit)
+		ss.workers.Wait()
+	}
+
+	ss.lock.RLock()
+	defer ss.lock.RUnlock()
+	return ss.err
+}
+
+// work seals the queued blocks in batches of what is queued, up to
+// SealBatchSize, until the session is closed.
+func (ss *SealSession) work() {
+	defer ss.workers.Done()
+	for {
+		var batch []sealItem
+		select {
+		case it := <-ss.queue:
+			batch = append(batch, it)
+		case <-ss.quit:
+			return
+		}
+	fill:
+		for len(batch) < SealBatchSize {
+			select {
+			case it := <-ss.queue:
+				batch = append(batch, it)
+			default:
+				break fill
+			}
+		}
+
+		ss.sealBatch(batch)
+		ss.pending.Add(-len(batch))
+	}
+}
+
+func (ss *SealSession) sealBatch(batch []sealItem) {
+	defer func() {
+		ss.lock.Lock()
+		for _, it := range batch {
+			delete(ss.queued, it.cid)
+		}
+		ss.lock.Unlock()
+	}()
+
+	ss.lock.RLock()
+	failed := ss.err != nil
+	ss.lock.RUnlock()
+	if failed {
+		// The seal fails anyway, don't seal any more.
+		return
+	}
+
+	values := make([][]byte, len(batch))
+	for i, it := range batch {
+		values[i] = it.value
+	}
+	needSeal, paths, err := SealBatch(ss.Backend, ss.Root, false, values)
+	if !needSeal && err == nil {
+		// Sealing was turned off since the session started, the blocks
+		// are stored in plain rather than lost.
+		for _, it := range batch {
+			if perr := it.plain(); perr != nil {
+				ss.fail(perr)
+				return
+			}
+			ss.Progress.AddPlain()
+		}
+		return
+	}
+	if !needSeal {
+		paths = nil
+	}
+	for i, p := range paths {
+		c := batch[i].cid
+		for j, path := range p {
+			perr := batch[i].put(NewWarpedSealedBlock(path, len(values[i]), c))
+			if perr == ErrReplicaDropped {
+				// The stub is full, the replica is already released.
+				continue
+			}
+			if perr != nil {
+				ss.releaseFrom(paths, i, j)
+				ss.fail(perr)
+				return
+			}
+			if perr := ss.SetStoreFlag(c, path); perr != nil {
+				// The stub points to the replica, GC releases it.
+				ss.releaseFrom(paths, i, j+1)
+				ss.fail(perr)
+				return
+			}
+		}
+		ss.Progress.AddSealed(len(values[i]))
+	}
+	if err != nil {
+		ss.Progress.AddError(err)
+		ss.fail(err)
+	}
+}
+
+// releaseFrom releases the replicas of a batch no stub points to, from the
+// j-th one of the i-th block on, once storing the batch failed.
+func (ss *SealSession) releaseFrom(paths [][]string, i, j int) {
+	for ; i < len(paths); i++ {
+		for ; j < len(paths[i]); j++ {
+			if err := ss.Backend.Release(paths[i][j]); err != nil {
+				log.Warnf("releasing %s sealed under %s: %s", paths[i][j], ss.Root, err)
+			}
+		}
+		j = 0
+	}
+}
+
+// Fail fails the session with err, for a block of the DAG which couldn't be
+// submitted. Close then returns the error the session first failed with.
+func (ss *SealSession) Fail(err error) {
+	ss.fail(err)
+}
+
+// fail records err as the error the session failed with, unless it already
+// failed.
+func (ss *SealSession) fail(err error) {
+	log.Errorf("sealing under %s: %s", ss.Root, err)
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	if ss.err == nil {
+		ss.err = err
+	}
+}
@@ -0,0 +1,192 @@
+package spacex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// batchBackend counts the batches sealed, holding them until gate is closed,
+// failing them while fail is set and not sealing them while off is set.
+type batchBackend struct {
+	*LocalBackend
+	gate    chan struct{}
+	batches int32
+	fail    int32
+	off     int32
+}
+
+func (bb *batchBackend) SealBatch(root cid.Cid, newBlock bool, values [][]byte) (bool, [][]string, error) {
+	<-bb.gate
+	atomic.AddInt32(&bb.batches, 1)
+	if atomic.LoadInt32(&bb.fail) == 1 {
+		return false, nil, errors.New("sWorker is full")
+	}
+	if atomic.LoadInt32(&bb.off) == 1 {
+		return false, nil, nil
+	}
+	return SealBatch(bb.LocalBackend, root, newBlock, values)
+}
+
+func TestSealSessionQueue(t *testing.T) {
+	ctx := context.Background()
+	backend := &batchBackend{LocalBackend: NewLocalBackend(), gate: make(chan struct{})}
+	root := testRoot(t)
+	if _, err := backend.StartSeal(root); err != nil {
+		t.Fatal(err)
+	}
+	ss := newSealSession(backend, root)
+
+	var lk sync.Mutex
+	stubs := make(map[cid.Cid]int)
+	put := func(wb *WarpedSealedBlock) error {
+		lk.Lock()
+		defer lk.Unlock()
+		stubs[wb.Cid()]++
+		return nil
+	}
+	plain := func() error {
+		t.Error("expected the block to be sealed")
+		return nil
+	}
+
+	// Every block is submitted twice, concurrently, while the first
+	// batches are held.
+	const n = 200
+	var wg sync.WaitGroup
+	for i := 0; i < 2*n; i++ {
+		value := []byte(fmt.Sprintf("block %d", i%n))
+		h, err := mh.Sum(value, mh.SHA2_256, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(c cid.Cid) {
+			defer wg.Done()
+			if err := ss.Submit(ctx, c, value, put, plain); err != nil {
+				t.Error(err)
+			}
+		}(cid.NewCidV1(cid.Raw, h))
+	}
+	wg.Wait()
+	close(backend.gate)
+	if err := ss.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stubs) != n || backend.Replicas() != n {
+		t.Fatalf("expected %d blocks sealed once, got %d stubs and %d replicas", n, len(stubs), backend.Replicas())
+	}
+	for c, count := range stubs {
+		if count != 1 || !ss.GetStoreFlag(c) {
+			t.Fatalf("%s stored %d times", c, count)
+		}
+	}
+	if backend.batches == 0 || backend.batches >= n {
+		t.Fatalf("expected blocks to be sealed in batches, got %d calls", backend.batches)
+	}
+	if err := ss.Submit(ctx, root, nil, put, plain); err != ErrSessionClosed {
+		t.Fatalf("expected ErrSessionClosed, got %v", err)
+	}
+
+	// A replica the blockstore dropped isn't flagged as stored.
+	ss = newSealSession(backend, root)
+	drop := func(wb *WarpedSealedBlock) error { return ErrReplicaDropped }
+	if err := ss.Submit(ctx, root, []byte("root"), drop, plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := ss.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ss.GetStoreFlag(root) {
+		t.Fatal("expected the dropped replica not to be flagged")
+	}
+
+	// A block failing to be stored fails the session, the replicas which
+	// weren't stored are released.
+	before := backend.Replicas()
+	ss = newSealSession(backend, root)
+	values := make(map[cid.Cid][]byte)
+	var cids []cid.Cid
+	for i := 0; i < 10; i++ {
+		value := []byte(fmt.Sprintf("stored %d", i))
+		h, err := mh.Sum(value, mh.SHA2_256, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := cid.NewCidV1(cid.Raw, h)
+		values[c] = value
+		cids = append(cids, c)
+	}
+	stored := 0
+	storeBut := func(wb *WarpedSealedBlock) error {
+		lk.Lock()
+		defer lk.Unlock()
+		if wb.Cid().Equals(cids[3]) {
+			return errors.New("datastore is full")
+		}
+		stored++
+		return nil
+	}
+	for _, c := range cids {
+		if err := ss.Submit(ctx, c, values[c], storeBut, plain); err != nil {
+			break
+		}
+	}
+	if err := ss.Close(); err == nil {
+		t.Fatal("expected the session to fail")
+	}
+	if held := backend.Replicas() - before; held != stored {
+		t.Fatalf("backend holds %d new replicas for %d stored stubs", held, stored)
+	}
+
+	// A failed batch fails the session.
+	atomic.StoreInt32(&backend.fail, 1)
+	ss = newSealSession(backend, root)
+	if err := ss.Submit(ctx, root, []byte("root"), put, plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := ss.Close(); err == nil {
+		t.Fatal("expected the session to fail")
+	}
+	if ss.GetStoreFlag(root) {
+		t.Fatal("expected nothing to be stored")
+	}
+
+	// Blocks the backend stopped sealing are stored in plain.
+	atomic.StoreInt32(&backend.fail, 0)
+	atomic.StoreInt32(&backend.off, 1)
+	ss = newSealSession(backend, root)
+	stored = 0
+	storePlain := func() error {
+		lk.Lock()
+		defer lk.Unlock()
+		stored++
+		return nil
+	}
+	if err := ss.Submit(ctx, root, []byte("root"), put, storePlain); err != nil {
+		t.Fatal(err)
+	}
+	if err := ss.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if stored != 1 || ss.GetStoreFlag(root) {
+		t.Fatalf("expected the block stored in plain, got %d", stored)
+	}
+
+	// A block failing to be stored in plain fails the session.
+	ss = newSealSession(backend, root)
+	failPlain := func() error { return errors.New("datastore is full") }
+	if err := ss.Submit(ctx, root, []byte("root"), put, failPlain); err != nil {
+		t.Fatal(err)
+	}
+	if err := ss.Close(); err == nil {
+		t.Fatal("expected the session to fail")
+	}
+}
@@ -0,0 +1,43 @@
+package spacex
+
+import "context"
+
+// StoragePolicy tells how a block fetched from the network is kept once
+// received. Policies are ordered: when several requests want the same block,
+// the highest one applies.
+type StoragePolicy int
+
+const (
+	// StoreDefault leaves the choice to the node configuration.
+	StoreDefault StoragePolicy = iota
+
+	// StoreSealed leaves the block to the sealing path, which stores a
+	// sealed stub for it.
+	StoreSealed
+
+	// StoreCached keeps the block in memory only, in a size-bounded cache.
+	StoreCached
+
+	// StorePlain writes the block to the blockstore.
+	StorePlain
+)
+
+type storagePolicyKey struct{}
+
+// WithStoragePolicy asks for the blocks fetched under ctx to be kept
+// according to p.
+func WithStoragePolicy(ctx context.Context, p StoragePolicy) context.Context {
+	return context.WithValue(ctx, storagePolicyKey{}, p)
+}
+
+// StoragePolicyFromContext returns the storage policy requested by ctx.
+// Blocks fetched under a seal session are StoreSealed unless asked otherwise.
+func StoragePolicyFromContext(ctx context.Context) StoragePolicy {
+	if p, ok := ctx.Value(storagePolicyKey{}).(StoragePolicy); ok {
+		return p
+	}
+	if _, err := GetSealSession(ctx); err == nil {
+		return StoreSealed
+	}
+	return StoreDefault
+}
@@ -0,0 +1,451 @@
+package spacex
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// endpointSeparator separates the endpoint name from the backend path in the
+// replica paths handed out by a Pool.
+const endpointSeparator = "|"
+
+// Endpoint is a backend taking part in a Pool.
+type Endpoint struct {
+	// Name identifies the endpoint in the paths of the replicas it holds,
+	// it must not change once blocks were sealed to it. The paths of the
+	// endpoint with the empty name are kept as the backend returns them,
+	// so that replicas sealed before the pool existed stay readable.
+	Name string
+
+	Backend SealBackend
+
+	// Weight is the share of the replicas placed on this endpoint, relative
+	// to the other endpoints. Defaults to 1.
+	Weight int
+}
+
+// Pinger is implemented by backends which can tell whether they're reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type poolEndpoint struct {
+	Endpoint
+	down int32
+}
+
+func (pe *poolEndpoint) healthy() bool {
+	return atomic.LoadInt32(&pe.down) == 0
+}
+
+func (pe *poolEndpoint) setHealthy(healthy bool) {
+	var down int32
+	if !healthy {
+		down = 1
+	}
+	if atomic.SwapInt32(&pe.down, down) != down {
+		if healthy {
+			log.Infof("sealing endpoint %q is back up", pe.Name)
+		} else {
+			log.Warnf("sealing endpoint %q is down", pe.Name)
+		}
+	}
+}
+
+// Pool is a SealBackend spreading the replicas of each block over several
+// endpoints. Seal sessions are opened on all the healthy endpoints and each
+// block is sealed to Replicas distinct ones, picked at random by weight.
+// Unseal and Release go to the endpoint owning the replica path. Endpoints
+// failing a request are skipped until a health check finds them back.
+type Pool struct {
+	endpoints []*poolEndpoint
+	byName    map[string]*poolEndpoint
+	replicas  int
+
+	lock     sync.Mutex
+	sessions map[cid.Cid][]*poolEndpoint
+	reserved map[cid.Cid][]*poolEndpoint
+}
+
+var _ SealBackend = (*Pool)(nil)
+var _ ReplicaSealer = (*Pool)(nil)
+var _ CapacityReserver = (*Pool)(nil)
+
+// NewPool returns a pool placing up to replicas replicas of each block on
+// the given endpoints.
+func NewPool(endpoints []Endpoint, replicas int) (*Pool, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no sealing endpoint")
+	}
+	if replicas < 1 {
+		replicas = 1
+	}
+
+	p := &Pool{
+		byName:   make(map[string]*poolEndpoint),
+		replicas: replicas,
+		sessions: make(map[cid.Cid][]*poolEndpoint),
+		reserved: make(map[cid.Cid][]*poolEndpoint),
+	}
+	for _, e := range endpoints {
+		if strings.Contains(e.Name, endpointSeparator) {
+			return nil, fmt.Errorf("sealing endpoint name %q contains %q", e.Name, endpointSeparator)
+		}
+		if _, ok := p.byName[e.Name]; ok {
+			return nil, fmt.Errorf("duplicate sealing endpoint %q", e.Name)
+		}
+		if e.Weight <= 0 {
+			e.Weight = 1
+		}
+		pe := &poolEndpoint{Endpoint: e}
+		p.endpoints = append(p.endpoints, pe)
+		p.byName[e.Name] = pe
+	}
+	return p, nil
+}
+
+// Endpoints returns the endpoints of the pool.
+func (p *Pool) Endpoints() []Endpoint {
+	out := make([]Endpoint, len(p.endpoints))
+	for i, pe := range p.endpoints {
+		out[i] = pe.Endpoint
+	}
+	return out
+}
+
+// Endpoint returns the backend of the endpoint called name, or nil.
+func (p *Pool) Endpoint(name string) SealBackend {
+	if pe, ok := p.byName[name]; ok {
+		return pe.Backend
+	}
+	return nil
+}
+
+// Healthy reports whether the endpoint called name passed its last check.
+func (p *Pool) Healthy(name string) bool {
+	pe, ok := p.byName[name]
+	return ok && pe.healthy()
+}
+
+// CheckHealth pings the endpoints which support it and updates their health.
+func (p *Pool) CheckHealth(ctx context.Context) {
+	for _, pe := range p.endpoints {
+		pinger, ok := pe.Backend.(Pinger)
+		if !ok || !pe.Backend.Enabled() {
+			continue
+		}
+		err := pinger.Ping(ctx)
+		if err != nil {
+			log.Debugf("health check of sealing endpoint %q: %s", pe.Name, err)
+		}
+		pe.setHealthy(err == nil)
+	}
+}
+
+// Run checks the health of the endpoints every interval until ctx is done.
+func (p *Pool) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.CheckHealth(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Pool) Enabled() bool {
+	for _, pe := range p.endpoints {
+		if pe.Backend.Enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// Reserve sets size bytes aside on every healthy endpoint, as any of them may
+// end up holding all the blocks of root. The seal session of root is then
+// only opened on the endpoints which had room, it fails if none had.
+func (p *Pool) Reserve(ctx context.Context, root cid.Cid, size uint64) error {
+	var candidates []*poolEndpoint
+	for _, pe := range p.endpoints {
+		if pe.Backend.Enabled() && pe.healthy() {
+			candidates = append(candidates, pe)
+		}
+	}
+
+	// Endpoints may wait for room, have them wait together.
+	errs := make([]error, len(candidates))
+	var wg sync.WaitGroup
+	for i, pe := range candidates {
+		wg.Add(1)
+		go func(i int, pe *poolEndpoint) {
+			defer wg.Done()
+			errs[i] = Reserve(ctx, pe.Backend, root, size)
+		}(i, pe)
+	}
+	wg.Wait()
+
+	var reserved []*poolEndpoint
+	var firstErr error
+	for i, pe := range candidates {
+		if errs[i] != nil {
+			log.Debugf("reserving room for %s on endpoint %q: %s", root, pe.Name, errs[i])
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		reserved = append(reserved, pe)
+	}
+	if len(reserved) == 0 {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%w: no healthy sealing endpoint", ErrNoCapacity)
+		}
+		return firstErr
+	}
+	if len(reserved) < p.replicas {
+		log.Warnf("%d endpoints have room for %s, %d replicas are wanted", len(reserved), root, p.replicas)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.reserved[root] = reserved
+	return nil
+}
+
+// Unreserve gives back the bytes set aside for root on the endpoints.
+func (p *Pool) Unreserve(root cid.Cid) {
+	p.lock.Lock()
+	reserved := p.reserved[root]
+	delete(p.reserved, root)
+	p.lock.Unlock()
+
+	for _, pe := range reserved {
+		Unreserve(pe.Backend, root)
+	}
+}
+
+func (p *Pool) StartSeal(root cid.Cid) (bool, error) {
+	endpoints := p.endpoints
+	p.lock.Lock()
+	if reserved, ok := p.reserved[root]; ok {
+		endpoints = reserved
+	}
+	p.lock.Unlock()
+
+	var opened []*poolEndpoint
+	var firstErr error
+	for _, pe := range endpoints {
+		if !pe.Backend.Enabled() || !pe.healthy() {
+			continue
+		}
+		ok, err := pe.Backend.StartSeal(root)
+		if err != nil {
+			log.Warnf("starting seal of %s on endpoint %q: %s", root, pe.Name, err)
+			pe.setHealthy(false)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if ok {
+			opened = append(opened, pe)
+		}
+	}
+	if len(opened) == 0 {
+		return false, firstErr
+	}
+	if len(opened) < p.replicas {
+		log.Warnf("sealing %s to %d endpoints, %d replicas are wanted", root, len(opened), p.replicas)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.sessions[root] = opened
+	return true, nil
+}
+
+// pick removes an endpoint chosen at random by weight from candidates and
+// returns it.
+func pick(candidates []*poolEndpoint) (*poolEndpoint, []*poolEndpoint) {
+	total := 0
+	for _, pe := range candidates {
+		total += pe.Weight
+	}
+	n := rand.Intn(total)
+	for i, pe := range candidates {
+		n -= pe.Weight
+		if n < 0 {
+			return pe, append(candidates[:i:i], candidates[i+1:]...)
+		}
+	}
+	panic("unreachable")
+}
+
+func (p *Pool) Seal(root cid.Cid, newBlock bool, value []byte) (bool, string, error) {
+	return p.SealContext(context.Background(), root, newBlock, value)
+}
+
+// SealContext is Seal, given up once ctx is done.
+func (p *Pool) SealContext(ctx context.Context, root cid.Cid, newBlock bool, value []byte) (bool, string, error) {
+	needSeal, paths, err := p.seal(ctx, root, newBlock, value, 1)
+	if err != nil || !needSeal {
+		return needSeal, "", err
+	}
+	return true, paths[0], nil
+}
+
+// SealReplicas seals value to Replicas distinct endpoints.
+func (p *Pool) SealReplicas(root cid.Cid, newBlock bool, value []byte) (bool, []string, error) {
+	return p.seal(context.Background(), root, newBlock, value, p.replicas)
+}
+
+func (p *Pool) seal(ctx context.Context, root cid.Cid, newBlock bool, value []byte, replicas int) (bool, []string, error) {
+	p.lock.Lock()
+	opened := p.sessions[root]
+	p.lock.Unlock()
+	if len(opened) == 0 {
+		return false, nil, fmt.Errorf("Seal: no seal session for %s", root)
+	}
+
+	// Prefer the healthy endpoints, fall back to all of them in case the
+	// checks are behind.
+	var candidates []*poolEndpoint
+	for _, pe := range opened {
+		if pe.healthy() {
+			candidates = append(candidates, pe)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = append(candidates, opened...)
+	}
+
+	var paths []string
+	var lastErr error
+	for len(paths) < replicas && len(candidates) > 0 {
+		var pe *poolEndpoint
+		pe, candidates = pick(candidates)
+		needSeal, path, err := SealContext(ctx, pe.Backend, root, newBlock, value)
+		if err != nil {
+			log.Warnf("sealing to endpoint %q: %s", pe.Name, err)
+			pe.setHealthy(false)
+			lastErr = err
+			continue
+		}
+		if needSeal {
+			paths = append(paths, joinPath(pe.Name, path))
+		}
+	}
+	if len(paths) == 0 {
+		return false, nil, lastErr
+	}
+	if len(paths) < replicas {
+		log.Warnf("sealed %d replicas of a block of %s, %d are wanted", len(paths), root, replicas)
+	}
+	return true, paths, nil
+}
+
+func (p *Pool) EndSeal(root cid.Cid) (bool, error) {
+	// Endpoints which didn't open the session keep their reservation
+	// otherwise.
+	defer p.Unreserve(root)
+
+	p.lock.Lock()
+	opened := p.sessions[root]
+	delete(p.sessions, root)
+	p.lock.Unlock()
+
+	ended := false
+	var firstErr error
+	for _, pe := range opened {
+		ok, err := pe.Backend.EndSeal(root)
+		if err != nil {
+			log.Warnf("ending seal of %s on endpoint %q: %s", root, pe.Name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		ended = ended || ok
+	}
+	if !ended {
+		return false, firstErr
+	}
+	return true, nil
+}
+
+func (p *Pool) Unseal(path string) ([]byte, error, int) {
+	return p.UnsealContext(context.Background(), path)
+}
+
+// UnsealContext is Unseal, given up once ctx is done.
+func (p *Pool) UnsealContext(ctx context.Context, path string) ([]byte, error, int) {
+	name, bpath := splitPath(path)
+	pe, ok := p.byName[name]
+	if !ok {
+		// The endpoint may only be missing from the configuration, don't
+		// have the replica pruned.
+		return nil, fmt.Errorf("Unseal: unknown sealing endpoint %q", name), 410
+	}
+	data, err, code := UnsealContext(ctx, pe.Backend, bpath)
+	if err != nil && code == 0 {
+		pe.setHealthy(false)
+	}
+	return data, err, code
+}
+
+// ReplicaHealthy reports whether the endpoint keeping the replica at path
+// passed its last check. Replicas of unknown endpoints are left to Unseal.
+func (p *Pool) ReplicaHealthy(path string) bool {
+	name, _ := splitPath(path)
+	pe, ok := p.byName[name]
+	return !ok || pe.healthy()
+}
+
+// Retries returns the number of calls retried so far by all endpoints.
+func (p *Pool) Retries() uint64 {
+	var n uint64
+	for _, pe := range p.endpoints {
+		n += Retries(pe.Backend)
+	}
+	return n
+}
+
+func (p *Pool) Release(path string) error {
+	return p.ReleaseContext(context.Background(), path)
+}
+
+// ReleaseContext is Release, given up once ctx is done.
+func (p *Pool) ReleaseContext(ctx context.Context, path string) error {
+	name, bpath := splitPath(path)
+	pe, ok := p.byName[name]
+	if !ok {
+		return fmt.Errorf("Release: unknown sealing endpoint %q", name)
+	}
+	return ReleaseContext(ctx, pe.Backend, bpath)
+}
+
+func joinPath(name, path string) string {
+	if name == "" {
+		return path
+	}
+	return name + endpointSeparator + path
+}
+
+func splitPath(path string) (string, string) {
+	i := strings.Index(path, endpointSeparator)
+	if i < 0 {
+		return "", path
+	}
+	return path[:i], path[i+1:]
+}
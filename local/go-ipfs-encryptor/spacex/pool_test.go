@@ -0,0 +1,77 @@
+package spacex
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+)
+
+type failingBackend struct {
+	*LocalBackend
+}
+
+func (failingBackend) Seal(root cid.Cid, newBlock bool, value []byte) (bool, string, error) {
+	return false, "", errors.New("sWorker unreachable")
+}
+
+func TestPoolReplicas(t *testing.T) {
+	backends := []*LocalBackend{NewLocalBackend(), NewLocalBackend(), NewLocalBackend()}
+	pool, err := NewPool([]Endpoint{
+		{Backend: backends[0]},
+		{Name: "a", Backend: backends[1]},
+		{Name: "b", Backend: backends[2], Weight: 2},
+		{Name: "broken", Backend: failingBackend{NewLocalBackend()}},
+	}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := testRoot(t)
+	if ok, err := pool.StartSeal(root); !ok || err != nil {
+		t.Fatalf("seal not started: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		value := []byte(fmt.Sprintf("block %d", i))
+		ok, paths, err := SealReplicas(pool, root, false, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || len(paths) != 2 {
+			t.Fatalf("expected 2 replicas, got %v", paths)
+		}
+		for _, path := range paths {
+			data, err, _ := pool.Unseal(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != string(value) {
+				t.Fatal("unsealed the wrong data")
+			}
+		}
+	}
+	if pool.Healthy("broken") {
+		t.Fatal("failing endpoint still healthy")
+	}
+
+	// Every block has its replicas on distinct endpoints.
+	total := 0
+	for _, b := range backends {
+		if b.Replicas() > 10 {
+			t.Fatalf("endpoint holds %d replicas of 10 blocks", b.Replicas())
+		}
+		total += b.Replicas()
+	}
+	if total != 20 {
+		t.Fatalf("placed %d replicas, expected 20", total)
+	}
+
+	if _, err, code := pool.Unseal("gone|path"); err == nil || code != 410 {
+		t.Fatalf("replica of unknown endpoint: code %d, err %v", code, err)
+	}
+	if ok, err := pool.EndSeal(root); !ok || err != nil {
+		t.Fatalf("seal not ended: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package spacex
+
+import (
+	"context"
+	"sync"
+)
+
+type sealProgressKey struct{}
+
+// SealCounts tells how much the seal sessions reporting to a SealProgress
+// sealed.
+type SealCounts struct {
+	// Blocks and Bytes count the blocks sealed and their size.
+	Blocks uint64
+	Bytes  uint64
+
+	// Plain counts the blocks kept in plain by the seal policy.
+	Plain uint64
+
+	// Errors counts the blocks which failed to seal, LastError tells why
+	// the last one did.
+	Errors    uint64
+	LastError string `json:",omitempty"`
+
+	// Retries counts the calls the seal backend retried meanwhile, for any
+	// seal session.
+	Retries uint64
+}
+
+// SealProgress counts what the seal sessions opened under a context it is
+// attached to seal, for progress reports.
+type SealProgress struct {
+	backend SealBackend
+	retries uint64
+
+	lock   sync.Mutex
+	counts SealCounts
+}
+
+// NewSealProgress returns a progress of seals made with backend.
+func NewSealProgress(backend SealBackend) *SealProgress {
+	return &SealProgress{
+		backend: backend,
+		retries: Retries(backend),
+	}
+}
+
+// WithSealProgress attaches sp to ctx, the seal sessions opened under ctx
+// report to it.
+func WithSealProgress(ctx context.Context, sp *SealProgress) context.Context {
+	return context.WithValue(ctx, sealProgressKey{}, sp)
+}
+
+// GetSealProgress returns the seal progress attached to ctx, or nil.
+func GetSealProgress(ctx context.Context) *SealProgress {
+	sp, _ := ctx.Value(sealProgressKey{}).(*SealProgress)
+	return sp
+}
+
+// AddSealed counts a block of size bytes as sealed. sp may be nil.
+func (sp *SealProgress) AddSealed(size int) {
+	if sp == nil {
+		return
+	}
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	sp.counts.Blocks++
+	sp.counts.Bytes += uint64(size)
+}
+
+// AddPlain counts a block as kept in plain. sp may be nil.
+func (sp *SealProgress) AddPlain() {
+	if sp == nil {
+		return
+	}
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	sp.counts.Plain++
+}
+
+// AddError counts a block as failed to seal with err. sp may be nil.
+func (sp *SealProgress) AddError(err error) {
+	if sp == nil {
+		return
+	}
+	sp.lock.Lock()
+	defer sp.lock.Unlock()
+	sp.counts.Errors++
+	sp.counts.LastError = err.Error()
+}
+
+// Value returns the counts so far.
+func (sp *SealProgress) Value() SealCounts {
+	sp.lock.Lock()
+	counts := sp.counts
+	sp.lock.Unlock()
+
+	counts.Retries = Retries(sp.backend) - sp.retries
+	return counts
+}
@@ -0,0 +1,342 @@
+package spacex
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+var (
+	// ReleasePrefix namespaces the pending release queue in the repo
+	// datastore
+	ReleasePrefix = ds.NewKey("/spacex/release")
+
+	// ParkedReleasePrefix namespaces the paths the queue gave up releasing
+	ParkedReleasePrefix = ds.NewKey("/spacex/release-parked")
+)
+
+var (
+	// ReleaseBatchSize is the number of queued paths released per round
+	ReleaseBatchSize = 64
+
+	// ReleaseInterval is the time between rounds when the queue is idle
+	ReleaseInterval = time.Minute
+
+	// ReleaseMaxBackoff caps the delay between rounds after failures, and
+	// between two attempts to release a path
+	ReleaseMaxBackoff = 30 * time.Minute
+
+	// ReleaseMaxAttempts is the number of failed attempts after which a
+	// path is parked
+	ReleaseMaxAttempts = 10
+)
+
+// ReleaseQueue is a SealBackend which defers Release to a persistent queue
+// kept under /spacex/release, so that a replica is never forgotten because
+// the backend was unreachable. Run releases the queued paths in batches and
+// retries failed ones with backoff, each path on its own, so that paths which
+// keep failing don't hold the others up. Paths still failing after
+// ReleaseMaxAttempts are parked under /spacex/release-parked.
+type ReleaseQueue struct {
+	SealBackend
+
+	dstore ds.Datastore
+	wake   chan struct{}
+}
+
+// NewReleaseQueue wraps backend with the release queue kept in dstore.
+func NewReleaseQueue(backend SealBackend, dstore ds.Datastore) *ReleaseQueue {
+	return &ReleaseQueue{
+		SealBackend: backend,
+		dstore:      dstore,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+func releaseKey(path string) ds.Key {
+	return ReleasePrefix.ChildString(pathEncoding.EncodeToString([]byte(path)))
+}
+
+func parkedKey(path string) ds.Key {
+	return ParkedReleasePrefix.ChildString(pathEncoding.EncodeToString([]byte(path)))
+}
+
+// releaseEntry is a queued path, with the number of failed attempts to
+// release it and the time it is due again after the last one.
+type releaseEntry struct {
+	path     string
+	attempts uint64
+	next     time.Time
+}
+
+func (e *releaseEntry) bytes() []byte {
+	var next uint64
+	if !e.next.IsZero() {
+		next = uint64(e.next.Unix())
+	}
+	buf := appendUvarint([]byte{0}, e.attempts)
+	return appendUvarint(buf, next)
+}
+
+func parseReleaseEntry(path string, value []byte) *releaseEntry {
+	e := &releaseEntry{path: path}
+	// Paths queued before attempts were counted are stored as such, they
+	// are due right away.
+	if len(value) == 0 || value[0] != 0 {
+		return e
+	}
+	attempts, rest, err := readUvarint(value[1:])
+	if err != nil {
+		return e
+	}
+	next, _, err := readUvarint(rest)
+	if err != nil {
+		return e
+	}
+	e.attempts = attempts
+	if next != 0 {
+		e.next = time.Unix(int64(next), 0)
+	}
+	return e
+}
+
+// releaseBackoff returns the delay before a path is tried again after
+// attempts failures.
+func releaseBackoff(attempts uint64) time.Duration {
+	if attempts > 30 {
+		return ReleaseMaxBackoff
+	}
+	d := time.Second << (attempts - 1)
+	if d > ReleaseMaxBackoff {
+		d = ReleaseMaxBackoff
+	}
+	return d
+}
+
+// SealReplicas seals value with the wrapped backend.
+func (rq *ReleaseQueue) SealReplicas(root cid.Cid, newBlock bool, value []byte) (bool, []string, error) {
+	return SealReplicas(rq.SealBackend, root, newBlock, value)
+}
+
+// SealBatch seals values with the wrapped backend.
+func (rq *ReleaseQueue) SealBatch(root cid.Cid, newBlock bool, values [][]byte) (bool, [][]string, error) {
+	return SealBatch(rq.SealBackend, root, newBlock, values)
+}
+
+// SealContext seals value with the wrapped backend, under ctx.
+func (rq *ReleaseQueue) SealContext(ctx context.Context, root cid.Cid, newBlock bool, value []byte) (bool, string, error) {
+	return SealContext(ctx, rq.SealBackend, root, newBlock, value)
+}
+
+// UnsealContext unseals the replica at path with the wrapped backend, under
+// ctx.
+func (rq *ReleaseQueue) UnsealContext(ctx context.Context, path string) ([]byte, error, int) {
+	return UnsealContext(ctx, rq.SealBackend, path)
+}
+
+// ReleaseContext queues the replica at path for release, as Release.
+func (rq *ReleaseQueue) ReleaseContext(ctx context.Context, path string) error {
+	return rq.Release(path)
+}
+
+// Retries returns the number of calls the wrapped backend retried.
+func (rq *ReleaseQueue) Retries() uint64 {
+	return Retries(rq.SealBackend)
+}
+
+// ObservePrune records a replica pruned for reason on the wrapped backend.
+func (rq *ReleaseQueue) ObservePrune(reason string) {
+	ObservePrune(rq.SealBackend, reason)
+}
+
+// ReplicaHealthy reports whether the replica at path is expected to be
+// readable from the wrapped backend.
+func (rq *ReleaseQueue) ReplicaHealthy(path string) bool {
+	return ReplicaHealthy(rq.SealBackend, path)
+}
+
+// Reserve sets size bytes aside for root on the wrapped backend.
+func (rq *ReleaseQueue) Reserve(ctx context.Context, root cid.Cid, size uint64) error {
+	return Reserve(ctx, rq.SealBackend, root, size)
+}
+
+// Unreserve gives back the bytes set aside for root on the wrapped backend.
+func (rq *ReleaseQueue) Unreserve(root cid.Cid) {
+	Unreserve(rq.SealBackend, root)
+}
+
+// Release queues the replica at path for release.
+func (rq *ReleaseQueue) Release(path string) error {
+	e := &releaseEntry{path: path}
+	if err := rq.dstore.Put(releaseKey(path), e.bytes()); err != nil {
+		return err
+	}
+
+	select {
+	case rq.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pending returns the paths waiting to be released.
+func (rq *ReleaseQueue) Pending() ([]string, error) {
+	return rq.paths(ReleasePrefix)
+}
+
+// Parked returns the paths the queue gave up releasing.
+func (rq *ReleaseQueue) Parked() ([]string, error) {
+	return rq.paths(ParkedReleasePrefix)
+}
+
+func (rq *ReleaseQueue) paths(prefix ds.Key) ([]string, error) {
+	keys, err := childKeys(rq.dstore, prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(keys))
+	for _, k := range keys {
+		path, err := pathEncoding.DecodeString(k.Name())
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, string(path))
+	}
+	return paths, nil
+}
+
+// due returns up to limit queued paths due at now.
+func (rq *ReleaseQueue) due(now time.Time, limit int) ([]*releaseEntry, error) {
+	results, err := rq.dstore.Query(query.Query{Prefix: ReleasePrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close()
+
+	var entries []*releaseEntry
+	for r := range results.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		k := ds.RawKey(r.Key)
+		if !k.Parent().Equal(ReleasePrefix) {
+			continue
+		}
+		path, err := pathEncoding.DecodeString(k.Name())
+		if err != nil {
+			return nil, err
+		}
+		e := parseReleaseEntry(string(path), r.Value)
+		if e.next.After(now) {
+			continue
+		}
+		entries = append(entries, e)
+		if len(entries) >= limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// failed records a failed attempt to release e, parking it once it failed
+// ReleaseMaxAttempts times.
+func (rq *ReleaseQueue) failed(e *releaseEntry, cause error) error {
+	e.attempts++
+	if e.attempts < uint64(ReleaseMaxAttempts) {
+		e.next = time.Now().Add(releaseBackoff(e.attempts))
+		return rq.dstore.Put(releaseKey(e.path), e.bytes())
+	}
+
+	log.Warnf("giving up releasing %s after %d attempts: %s", e.path, e.attempts, cause)
+	if err := rq.dstore.Put(parkedKey(e.path), []byte(e.path)); err != nil {
+		return err
+	}
+	return rq.dstore.Delete(releaseKey(e.path))
+}
+
+// Flush releases one batch of the queued paths which are due on the backend.
+// It returns the number of paths released and the first error met; paths
+// which failed stay queued until their backoff expires, or are parked.
+func (rq *ReleaseQueue) Flush() (int, error) {
+	released, _, err := rq.flush(context.Background())
+	return released, err
+}
+
+func (rq *ReleaseQueue) flush(ctx context.Context) (int, int, error) {
+	entries, err := rq.due(time.Now(), ReleaseBatchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	released := 0
+	var firstErr error
+	for _, e := range entries {
+		if err := ReleaseContext(ctx, rq.SealBackend, e.path); err != nil {
+			if ctx.Err() != nil {
+				// Not the path's fault, it is tried again next time.
+				return released, len(entries), ctx.Err()
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			if err := rq.failed(e, err); err != nil {
+				return released, len(entries), err
+			}
+			continue
+		}
+		if err := rq.dstore.Delete(releaseKey(e.path)); err != nil {
+			return released, len(entries), err
+		}
+		released++
+	}
+	return released, len(entries), firstErr
+}
+
+// Run flushes the queue until ctx is done.
+func (rq *ReleaseQueue) Run(ctx context.Context) {
+	backoff := time.Duration(0)
+	for {
+		released, tried, err := rq.flush(ctx)
+
+		var delay time.Duration
+		switch {
+		case tried == ReleaseBatchSize:
+			// More may be due, go on right away.
+			backoff = 0
+			delay = 0
+		case err != nil && released == 0:
+			// The backend may be down.
+			if backoff == 0 {
+				backoff = time.Second
+			} else if backoff *= 2; backoff > ReleaseMaxBackoff {
+				backoff = ReleaseMaxBackoff
+			}
+			delay = backoff
+		default:
+			backoff = 0
+			delay = ReleaseInterval
+		}
+
+		timer := time.NewTimer(delay)
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-rq.wake:
+				// Don't hammer a failing backend on every new release.
+				if err == nil {
+					timer.Stop()
+					break wait
+				}
+			case <-timer.C:
+				break wait
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package spacex
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+// releaseBackend fails to release the paths starting with "bad".
+type releaseBackend struct {
+	*LocalBackend
+	released []string
+	calls    map[string]int
+}
+
+func (rb *releaseBackend) Release(path string) error {
+	rb.calls[path]++
+	if strings.HasPrefix(path, "bad") {
+		return errors.New("unknown sealing endpoint")
+	}
+	rb.released = append(rb.released, path)
+	return nil
+}
+
+func TestReleaseQueueRotates(t *testing.T) {
+	defer func(size, attempts int) {
+		ReleaseBatchSize, ReleaseMaxAttempts = size, attempts
+	}(ReleaseBatchSize, ReleaseMaxAttempts)
+	ReleaseBatchSize = 2
+	ReleaseMaxAttempts = 2
+
+	backend := &releaseBackend{LocalBackend: NewLocalBackend(), calls: make(map[string]int)}
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	rq := NewReleaseQueue(backend, dstore)
+	for _, path := range []string{"bad1", "bad2", "good1", "good2"} {
+		if err := rq.Release(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A path queued before attempts were counted is due right away.
+	if err := dstore.Put(releaseKey("good3"), []byte("good3")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The failed paths back off, the others are released past them.
+	for i := 0; i < 3; i++ {
+		rq.Flush()
+	}
+	if len(backend.released) != 3 {
+		t.Fatalf("expected the 3 good paths released, got %v", backend.released)
+	}
+	if backend.calls["bad1"] != 1 || backend.calls["bad2"] != 1 {
+		t.Fatalf("expected the bad paths tried once, got %v", backend.calls)
+	}
+	pending, err := rq.Pending()
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("expected the bad paths to stay queued, got %v %v", pending, err)
+	}
+
+	// Once due again, they fail for the last time and are parked.
+	for _, path := range pending {
+		e := &releaseEntry{path: path, attempts: 1}
+		if err := dstore.Put(releaseKey(path), e.bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := rq.Flush(); err == nil {
+		t.Fatal("expected flush to fail")
+	}
+	if pending, err = rq.Pending(); err != nil || len(pending) != 0 {
+		t.Fatalf("expected the queue to be empty, got %v %v", pending, err)
+	}
+	if parked, err := rq.Parked(); err != nil || len(parked) != 2 {
+		t.Fatalf("expected the bad paths to be parked, got %v %v", parked, err)
+	}
+}
@@ -3,31 +3,146 @@ package spacex
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/ipfs/go-cid"
 )
 
-const sealContextKey string = "sckey"
+type sealContextKey struct{}
 
-func GenSealContext(ctx context.Context, root cid.Cid) context.Context {
-	return context.WithValue(ctx, sealContextKey, root.String())
+// SealSession is an open seal of a root. It is carried through the fetch
+// path by the seal context and records which blocks were already stored as
+// sealed stubs.
+type SealSession struct {
+	Root    cid.Cid
+	Backend SealBackend
+
+	// Policy, if set, tells which blocks of the DAG are kept in plain.
+	Policy *SealPolicy
+
+	// Progress, if set, counts the blocks sealed. It is picked up from the
+	// context the session is opened under.
+	Progress *SealProgress
+
+	lock    sync.RWMutex
+	stored  map[cid.Cid]bool
+	journal *SealJournal
+
+	checked map[cid.Cid]string
+	dagSize uint64
+
+	// The seal queue, see Submit.
+	queue     chan sealItem
+	queued    map[cid.Cid]bool
+	pending   sync.WaitGroup
+	startOnce sync.Once
+	workers   sync.WaitGroup
+	quit      chan struct{}
+	closed    bool
+	err       error
 }
 
-func GetRootFromSealContext(ctx context.Context) (cid.Cid, error) {
-	v := ctx.Value(sealContextKey)
-	if v == nil {
-		return cid.Undef, fmt.Errorf("Can't find root cid from context")
+func newSealSession(backend SealBackend, root cid.Cid) *SealSession {
+	return &SealSession{
+		Root:    root,
+		Backend: backend,
+		stored:  make(map[cid.Cid]bool),
+		checked: make(map[cid.Cid]string),
+		queue:   make(chan sealItem, SealQueueSize),
+		queued:  make(map[cid.Cid]bool),
+		quit:    make(chan struct{}),
+	}
+}
+
+// CheckBlock returns why the block blockCid of size bytes is kept in plain
+// under the session policy, or "" if it is sealed.
+func (ss *SealSession) CheckBlock(blockCid cid.Cid, size int) string {
+	if ss.Policy == nil {
+		return ""
+	}
+
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	if reason, ok := ss.checked[blockCid]; ok {
+		return reason
+	}
+	reason := ss.Policy.CheckBlock(blockCid, size, ss.dagSize)
+	if reason == "" {
+		ss.dagSize += uint64(size)
+	} else {
+		ss.Progress.AddPlain()
+	}
+	ss.checked[blockCid] = reason
+	return reason
+}
+
+// PlainBlocks counts the blocks kept in plain so far, by reason.
+func (ss *SealSession) PlainBlocks() map[string]int {
+	ss.lock.RLock()
+	defer ss.lock.RUnlock()
+
+	var plain map[string]int
+	for _, reason := range ss.checked {
+		if reason == "" {
+			continue
+		}
+		if plain == nil {
+			plain = make(map[string]int)
+		}
+		plain[reason]++
 	}
-	if buf, ok := v.(string); ok {
-		return cid.Parse(buf)
+	return plain
+}
+
+// GetStoreFlag reports whether the sealed stub of blockCid was stored during
+// this session.
+func (ss *SealSession) GetStoreFlag(blockCid cid.Cid) bool {
+	ss.lock.RLock()
+	defer ss.lock.RUnlock()
+	return ss.stored[blockCid]
+}
+
+// SetStoreFlag marks the sealed stub of blockCid, pointing to the replica at
+// path, as stored, recording it in the seal journal if the session has one.
+func (ss *SealSession) SetStoreFlag(blockCid cid.Cid, path string) error {
+	if ss.journal != nil {
+		if err := ss.journal.SetStoreFlag(ss.Root, blockCid, path); err != nil {
+			return err
+		}
 	}
-	return cid.Undef, fmt.Errorf("Can't find root cid from context")
+
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	ss.stored[blockCid] = true
+	return nil
 }
 
-func GetStoreFlag(root cid.Cid, blockCid cid.Cid) bool {
-	return sealedMap.blockExist(root, blockCid)
+// GenSealContext opens a seal session of root on backend and attaches it to
+// ctx.
+func GenSealContext(ctx context.Context, backend SealBackend, root cid.Cid) context.Context {
+	ss := newSealSession(backend, root)
+	ss.Progress = GetSealProgress(ctx)
+	return context.WithValue(ctx, sealContextKey{}, ss)
 }
 
-func SetStoreFlag(root cid.Cid, blockCid cid.Cid) {
-	sealedMap.addBlock(root, blockCid)
+// WithSealSession attaches the seal session ss to ctx, so that ss goes on
+// under another context.
+func WithSealSession(ctx context.Context, ss *SealSession) context.Context {
+	return context.WithValue(ctx, sealContextKey{}, ss)
+}
+
+// GetSealSession returns the seal session attached to ctx.
+func GetSealSession(ctx context.Context) (*SealSession, error) {
+	if ss, ok := ctx.Value(sealContextKey{}).(*SealSession); ok {
+		return ss, nil
+	}
+	return nil, fmt.Errorf("Can't find seal session from context")
+}
+
+func GetRootFromSealContext(ctx context.Context) (cid.Cid, error) {
+	ss, err := GetSealSession(ctx)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("Can't find root cid from context")
+	}
+	return ss.Root, nil
 }
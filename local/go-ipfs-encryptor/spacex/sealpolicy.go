@@ -0,0 +1,260 @@
+package spacex
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// builtinDenyList holds roots which are never sealed, whatever the policy,
+// such as the init docs and the empty unixfs directory every repo holds.
+var builtinDenyList = []string{
+	"QmQPeNsJPyVWPFDVHb77w8G42Fvo15z4bG2X8D2GhfbSXc",
+	"QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn",
+	"QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n",
+}
+
+var builtinDenySet map[string]bool
+
+func init() {
+	builtinDenySet = make(map[string]bool)
+	for _, v := range builtinDenyList {
+		builtinDenySet[v] = true
+	}
+}
+
+// SealDecisionHistory is the number of decisions kept by a SealPolicy.
+var SealDecisionHistory = 1024
+
+// codecAliases are the codec names accepted in addition to the go-cid ones.
+var codecAliases = map[string]uint64{
+	"dag-pb":   cid.DagProtobuf,
+	"dag-cbor": cid.DagCBOR,
+}
+
+// CodecByName returns the multicodec called name.
+func CodecByName(name string) (uint64, error) {
+	if c, ok := codecAliases[name]; ok {
+		return c, nil
+	}
+	if c, ok := cid.Codecs[name]; ok {
+		return c, nil
+	}
+	return 0, fmt.Errorf("unknown codec %q", name)
+}
+
+// SealRules are the rules a SealPolicy applies. Roots are matched by
+// multihash, so that the CIDv0 and CIDv1 of a root are the same.
+type SealRules struct {
+	// Allow, if not nil, holds the only roots which are sealed.
+	Allow map[string]bool
+
+	// Deny holds roots which are not sealed.
+	Deny map[string]bool
+
+	// MaxDAGSize, if positive, is the size in bytes above which a DAG isn't
+	// sealed. Blocks past it are kept in plain when the root didn't tell.
+	MaxDAGSize uint64
+
+	// MaxBlockSize, if positive, is the size in bytes above which a block
+	// is kept in plain.
+	MaxBlockSize uint64
+
+	// Codecs, if not nil, holds the only codecs of the blocks sealed.
+	Codecs map[uint64]bool
+
+	// Priorities are the priorities of roots, 0 when not listed. Roots with
+	// a negative priority aren't sealed.
+	Priorities map[string]int
+}
+
+// ReadCidList reads a list of cids, one per line, as a set of multihashes
+// fit for SealRules.Allow and SealRules.Deny. Empty lines and lines starting
+// with '#' are skipped.
+func ReadCidList(r io.Reader) (map[string]bool, error) {
+	set := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		c, err := cid.Decode(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		set[string(c.Hash())] = true
+	}
+	return set, scanner.Err()
+}
+
+// ReadCidListFile reads the list of cids stored in the file at path, see
+// ReadCidList.
+func ReadCidListFile(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	set, err := ReadCidList(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return set, nil
+}
+
+// SealDecision tells whether, and why, the pin of a root was sealed.
+type SealDecision struct {
+	Root     cid.Cid
+	Sealed   bool
+	Reason   string
+	Priority int
+
+	// PlainBlocks counts the blocks of a sealed DAG which were kept in
+	// plain, by reason.
+	PlainBlocks map[string]int `json:",omitempty"`
+
+	Time time.Time
+}
+
+// SealPolicy decides which pins are sealed and which blocks of a sealed DAG
+// are kept in plain. Its rules can be replaced at any time with Update, the
+// seals already running keep the rules they started with for the roots but
+// pick up the new ones for the blocks they fetch next.
+type SealPolicy struct {
+	lock  sync.RWMutex
+	rules SealRules
+
+	decisions map[cid.Cid]SealDecision
+	order     []cid.Cid
+}
+
+// NewSealPolicy returns a policy applying rules.
+func NewSealPolicy(rules SealRules) *SealPolicy {
+	return &SealPolicy{
+		rules:     rules,
+		decisions: make(map[cid.Cid]SealDecision),
+	}
+}
+
+// DefaultSealPolicy returns a policy sealing everything but the built-in
+// deny list.
+func DefaultSealPolicy() *SealPolicy {
+	return NewSealPolicy(SealRules{})
+}
+
+// Update replaces the rules of the policy.
+func (p *SealPolicy) Update(rules SealRules) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.rules = rules
+}
+
+// Rules returns the rules of the policy.
+func (p *SealPolicy) Rules() SealRules {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.rules
+}
+
+// Priority returns the priority of root.
+func (p *SealPolicy) Priority(root cid.Cid) int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.rules.Priorities[string(root.Hash())]
+}
+
+// CheckRoot decides whether the pin of root is sealed. size is the size of
+// the DAG as told by the root node, e.g. the cumulative size of a unixfs
+// node, and blockSize the size of the root block.
+func (p *SealPolicy) CheckRoot(root cid.Cid, size uint64, blockSize int) SealDecision {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	d := SealDecision{
+		Root:     root,
+		Priority: p.rules.Priorities[string(root.Hash())],
+		Time:     time.Now(),
+	}
+	mh := string(root.Hash())
+	switch {
+	case builtinDenySet[root.Hash().B58String()]:
+		d.Reason = "root is in the built-in deny list"
+	case p.rules.Deny[mh]:
+		d.Reason = "root is in the deny list"
+	case p.rules.Allow != nil && !p.rules.Allow[mh]:
+		d.Reason = "root isn't in the allow list"
+	case d.Priority < 0:
+		d.Reason = fmt.Sprintf("root has a negative priority (%d)", d.Priority)
+	case p.rules.MaxDAGSize > 0 && size > p.rules.MaxDAGSize:
+		d.Reason = fmt.Sprintf("DAG size %d exceeds the maximum of %d", size, p.rules.MaxDAGSize)
+	default:
+		if reason := p.checkBlock(root, blockSize); reason != "" {
+			d.Reason = "root " + reason
+			break
+		}
+		d.Sealed = true
+		d.Reason = "allowed by the policy"
+	}
+	return d
+}
+
+// checkBlock returns why the block c of size bytes is kept in plain, or "".
+func (p *SealPolicy) checkBlock(c cid.Cid, size int) string {
+	if p.rules.Codecs != nil && !p.rules.Codecs[c.Type()] {
+		name, ok := cid.CodecToStr[c.Type()]
+		if !ok {
+			name = fmt.Sprintf("0x%x", c.Type())
+		}
+		return fmt.Sprintf("codec %s isn't allowed", name)
+	}
+	if p.rules.MaxBlockSize > 0 && uint64(size) > p.rules.MaxBlockSize {
+		return fmt.Sprintf("block size exceeds the maximum of %d", p.rules.MaxBlockSize)
+	}
+	return ""
+}
+
+// CheckBlock returns why the block c of size bytes, in a DAG of which
+// dagSize bytes were sealed so far, is kept in plain, or "" if it is sealed.
+func (p *SealPolicy) CheckBlock(c cid.Cid, size int, dagSize uint64) string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if reason := p.checkBlock(c, size); reason != "" {
+		return reason
+	}
+	if p.rules.MaxDAGSize > 0 && dagSize+uint64(size) > p.rules.MaxDAGSize {
+		return fmt.Sprintf("DAG size exceeds the maximum of %d", p.rules.MaxDAGSize)
+	}
+	return ""
+}
+
+// Record keeps d as the last decision made for its root.
+func (p *SealPolicy) Record(d SealDecision) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.decisions[d.Root]; !ok {
+		p.order = append(p.order, d.Root)
+		if len(p.order) > SealDecisionHistory {
+			delete(p.decisions, p.order[0])
+			p.order = p.order[1:]
+		}
+	}
+	p.decisions[d.Root] = d
+}
+
+// Decision returns the last decision recorded for root.
+func (p *SealPolicy) Decision(root cid.Cid) (SealDecision, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	d, ok := p.decisions[root]
+	return d, ok
+}
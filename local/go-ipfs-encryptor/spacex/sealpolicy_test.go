@@ -0,0 +1,50 @@
+package spacex
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+)
+
+func TestSealPolicy(t *testing.T) {
+	root := testRoot(t)
+	builtin, err := cid.Decode(builtinDenyList[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allow, err := ReadCidList(strings.NewReader("# sealed roots\n\n" + cid.NewCidV1(cid.DagProtobuf, root.Hash()).String() + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := NewSealPolicy(SealRules{
+		Allow:      allow,
+		MaxDAGSize: 100,
+		Priorities: map[string]int{string(root.Hash()): 5},
+	})
+
+	// The allow list matches the CIDv0 of a root listed as CIDv1.
+	if d := policy.CheckRoot(root, 100, 10); !d.Sealed || d.Priority != 5 {
+		t.Fatalf("unexpected decision %+v", d)
+	}
+	if d := policy.CheckRoot(root, 101, 10); d.Sealed {
+		t.Fatal("DAG larger than MaxDAGSize sealed")
+	}
+	if d := policy.CheckRoot(builtin, 0, 0); d.Sealed || d.Reason != "root is in the built-in deny list" {
+		t.Fatalf("unexpected decision %+v", d)
+	}
+	if policy.CheckBlock(root, 60, 50) == "" {
+		t.Fatal("block past MaxDAGSize sealed")
+	}
+
+	// Reloaded rules apply right away.
+	policy.Update(SealRules{})
+	if d := policy.CheckRoot(root, 1000, 10); !d.Sealed || d.Priority != 0 {
+		t.Fatalf("unexpected decision %+v", d)
+	}
+
+	if _, err := ReadCidList(strings.NewReader("not a cid\n")); err == nil {
+		t.Fatal("expected an error for an invalid cid")
+	}
+}
@@ -0,0 +1,283 @@
+package spacex
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// LatencyBuckets are the upper bounds, in seconds, of the latency histograms
+// kept by SealStats.
+var LatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// CallKey identifies the calls of one seal backend method ending with one
+// status code: the HTTP code of the response, or "error" when there was none.
+type CallKey struct {
+	Op   string
+	Code string
+}
+
+// CallStats are the count and latency histogram of calls.
+type CallStats struct {
+	Count uint64
+
+	// Sum is the total latency in seconds.
+	Sum float64
+
+	// Buckets holds the number of calls which took at most the matching
+	// LatencyBuckets bound, it is not cumulative.
+	Buckets []uint64
+}
+
+// SealStats accumulates the activity of a seal backend, see MeteredBackend.
+type SealStats struct {
+	lock        sync.Mutex
+	calls       map[CallKey]*CallStats
+	sealing     map[cid.Cid]uint64
+	sealedBytes uint64
+	prunings    map[string]uint64
+}
+
+// NewSealStats returns empty stats.
+func NewSealStats() *SealStats {
+	return &SealStats{
+		calls:    make(map[CallKey]*CallStats),
+		sealing:  make(map[cid.Cid]uint64),
+		prunings: make(map[string]uint64),
+	}
+}
+
+func (st *SealStats) observe(op string, code string, d time.Duration) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	key := CallKey{Op: op, Code: code}
+	cs, ok := st.calls[key]
+	if !ok {
+		cs = &CallStats{Buckets: make([]uint64, len(LatencyBuckets))}
+		st.calls[key] = cs
+	}
+	cs.Count++
+	cs.Sum += d.Seconds()
+	i := sort.SearchFloat64s(LatencyBuckets, d.Seconds())
+	if i < len(cs.Buckets) {
+		cs.Buckets[i]++
+	}
+}
+
+// Calls returns the stats of the calls made so far.
+func (st *SealStats) Calls() map[CallKey]CallStats {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	out := make(map[CallKey]CallStats, len(st.calls))
+	for k, cs := range st.calls {
+		c := *cs
+		c.Buckets = append([]uint64(nil), cs.Buckets...)
+		out[k] = c
+	}
+	return out
+}
+
+// Sealing returns the bytes sealed so far by the open seal sessions, by root.
+func (st *SealStats) Sealing() map[cid.Cid]uint64 {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	out := make(map[cid.Cid]uint64, len(st.sealing))
+	for root, n := range st.sealing {
+		out[root] = n
+	}
+	return out
+}
+
+// SealedBytes returns the total number of bytes sealed.
+func (st *SealStats) SealedBytes() uint64 {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	return st.sealedBytes
+}
+
+// Prunings returns the number of replicas pruned from sealed stubs so far,
+// by reason.
+func (st *SealStats) Prunings() map[string]uint64 {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	out := make(map[string]uint64, len(st.prunings))
+	for reason, n := range st.prunings {
+		out[reason] = n
+	}
+	return out
+}
+
+// callCode returns the status code label of a call which returned err.
+func callCode(err error) string {
+	if err == nil {
+		return strconv.Itoa(http.StatusOK)
+	}
+	var e *Error
+	if errors.As(err, &e) && e.Code != 0 {
+		return strconv.Itoa(e.Code)
+	}
+	return "error"
+}
+
+// MeteredBackend is a SealBackend recording the calls made to the wrapped
+// backend in SealStats.
+type MeteredBackend struct {
+	SealBackend
+
+	stats *SealStats
+}
+
+var _ ReplicaSealer = (*MeteredBackend)(nil)
+var _ BatchSealer = (*MeteredBackend)(nil)
+
+// NewMeteredBackend wraps backend, recording its calls in stats.
+func NewMeteredBackend(backend SealBackend, stats *SealStats) *MeteredBackend {
+	return &MeteredBackend{SealBackend: backend, stats: stats}
+}
+
+func (mb *MeteredBackend) StartSeal(root cid.Cid) (bool, error) {
+	start := time.Now()
+	ok, err := mb.SealBackend.StartSeal(root)
+	mb.stats.observe("seal_start", callCode(err), time.Since(start))
+	if ok {
+		mb.stats.lock.Lock()
+		mb.stats.sealing[root] = 0
+		mb.stats.lock.Unlock()
+	}
+	return ok, err
+}
+
+func (mb *MeteredBackend) Seal(root cid.Cid, newBlock bool, value []byte) (bool, string, error) {
+	return mb.SealContext(context.Background(), root, newBlock, value)
+}
+
+// SealContext is Seal, given up once ctx is done.
+func (mb *MeteredBackend) SealContext(ctx context.Context, root cid.Cid, newBlock bool, value []byte) (bool, string, error) {
+	start := time.Now()
+	needSeal, path, err := SealContext(ctx, mb.SealBackend, root, newBlock, value)
+	mb.stats.observe("seal", callCode(err), time.Since(start))
+	if needSeal {
+		n := uint64(len(value))
+		mb.stats.lock.Lock()
+		if _, ok := mb.stats.sealing[root]; ok {
+			mb.stats.sealing[root] += n
+		}
+		mb.stats.sealedBytes += n
+		mb.stats.lock.Unlock()
+	}
+	return needSeal, path, err
+}
+
+func (mb *MeteredBackend) SealReplicas(root cid.Cid, newBlock bool, value []byte) (bool, []string, error) {
+	start := time.Now()
+	needSeal, paths, err := SealReplicas(mb.SealBackend, root, newBlock, value)
+	mb.stats.observe("seal", callCode(err), time.Since(start))
+	if needSeal {
+		n := uint64(len(value) * len(paths))
+		mb.stats.lock.Lock()
+		if _, ok := mb.stats.sealing[root]; ok {
+			mb.stats.sealing[root] += n
+		}
+		mb.stats.sealedBytes += n
+		mb.stats.lock.Unlock()
+	}
+	return needSeal, paths, err
+}
+
+func (mb *MeteredBackend) SealBatch(root cid.Cid, newBlock bool, values [][]byte) (bool, [][]string, error) {
+	start := time.Now()
+	needSeal, paths, err := SealBatch(mb.SealBackend, root, newBlock, values)
+	mb.stats.observe("seal_batch", callCode(err), time.Since(start))
+	if needSeal {
+		var n uint64
+		for i, p := range paths {
+			n += uint64(len(values[i]) * len(p))
+		}
+		mb.stats.lock.Lock()
+		if _, ok := mb.stats.sealing[root]; ok {
+			mb.stats.sealing[root] += n
+		}
+		mb.stats.sealedBytes += n
+		mb.stats.lock.Unlock()
+	}
+	return needSeal, paths, err
+}
+
+func (mb *MeteredBackend) EndSeal(root cid.Cid) (bool, error) {
+	start := time.Now()
+	ok, err := mb.SealBackend.EndSeal(root)
+	mb.stats.observe("seal_end", callCode(err), time.Since(start))
+	mb.stats.lock.Lock()
+	delete(mb.stats.sealing, root)
+	mb.stats.lock.Unlock()
+	return ok, err
+}
+
+func (mb *MeteredBackend) Unseal(path string) ([]byte, error, int) {
+	return mb.UnsealContext(context.Background(), path)
+}
+
+// UnsealContext is Unseal, given up once ctx is done.
+func (mb *MeteredBackend) UnsealContext(ctx context.Context, path string) ([]byte, error, int) {
+	start := time.Now()
+	data, err, code := UnsealContext(ctx, mb.SealBackend, path)
+	label := strconv.Itoa(code)
+	if code == 0 {
+		label = "error"
+	}
+	mb.stats.observe("unseal", label, time.Since(start))
+	return data, err, code
+}
+
+func (mb *MeteredBackend) Release(path string) error {
+	return mb.ReleaseContext(context.Background(), path)
+}
+
+// ReleaseContext is Release, given up once ctx is done.
+func (mb *MeteredBackend) ReleaseContext(ctx context.Context, path string) error {
+	start := time.Now()
+	err := ReleaseContext(ctx, mb.SealBackend, path)
+	mb.stats.observe("release", callCode(err), time.Since(start))
+	return err
+}
+
+// Retries returns the number of calls the wrapped backend retried.
+func (mb *MeteredBackend) Retries() uint64 {
+	return Retries(mb.SealBackend)
+}
+
+// ObservePrune records a replica pruned for reason in the stats.
+func (mb *MeteredBackend) ObservePrune(reason string) {
+	mb.stats.lock.Lock()
+	defer mb.stats.lock.Unlock()
+	mb.stats.prunings[reason]++
+}
+
+// ReplicaHealthy reports whether the replica at path is expected to be
+// readable from the wrapped backend.
+func (mb *MeteredBackend) ReplicaHealthy(path string) bool {
+	return ReplicaHealthy(mb.SealBackend, path)
+}
+
+// Reserve sets size bytes aside for root on the wrapped backend.
+func (mb *MeteredBackend) Reserve(ctx context.Context, root cid.Cid, size uint64) error {
+	start := time.Now()
+	err := Reserve(ctx, mb.SealBackend, root, size)
+	mb.stats.observe("reserve", callCode(err), time.Since(start))
+	return err
+}
+
+// Unreserve gives back the bytes set aside for root on the wrapped backend.
+func (mb *MeteredBackend) Unreserve(root cid.Cid) {
+	Unreserve(mb.SealBackend, root)
+}
@@ -0,0 +1,94 @@
+package spacex
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMeteredBackend(t *testing.T) {
+	stats := NewSealStats()
+	mb := NewMeteredBackend(NewLocalBackend(), stats)
+	root := testRoot(t)
+
+	if _, err := mb.StartSeal(root); err != nil {
+		t.Fatal(err)
+	}
+	_, path, err := mb.Seal(root, false, []byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := stats.Sealing()[root]; n != 4 {
+		t.Fatalf("expected 4 bytes sealed for the root, got %d", n)
+	}
+	if _, err := mb.EndSeal(root); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Sealing()) != 0 {
+		t.Fatal("seal session still open")
+	}
+	if stats.SealedBytes() != 4 {
+		t.Fatalf("expected 4 bytes sealed, got %d", stats.SealedBytes())
+	}
+
+	mb.Unseal(path)
+	mb.Unseal("missing")
+	calls := stats.Calls()
+	for key, count := range map[CallKey]uint64{
+		{Op: "seal_start", Code: "200"}: 1,
+		{Op: "seal", Code: "200"}:       1,
+		{Op: "seal_end", Code: "200"}:   1,
+		{Op: "unseal", Code: "200"}:     1,
+		{Op: "unseal", Code: "404"}:     1,
+	} {
+		if calls[key].Count != count {
+			t.Fatalf("expected %d %v calls, got %d", count, key, calls[key].Count)
+		}
+	}
+
+	// Only the replicas removed from a stub count as pruned.
+	if n := stats.Prunings()[PruneMissing]; n != 0 {
+		t.Fatalf("expected no pruning yet, got %d", n)
+	}
+	si := &SealedInfo{Sbs: []SealedBlock{{Path: "missing"}}}
+	if _, _, err := UnsealInfo(mb, nil, root, si); err != ErrNoReplica {
+		t.Fatalf("expected ErrNoReplica, got %v", err)
+	}
+	if n := stats.Prunings()[PruneMissing]; n != 1 {
+		t.Fatalf("expected 1 missing replica pruned, got %d", n)
+	}
+}
+
+func TestSealProgress(t *testing.T) {
+	backend := NewLocalBackend()
+	sp := NewSealProgress(backend)
+	ctx := WithSealProgress(context.Background(), sp)
+
+	ss, err := GetSealSession(GenSealContext(ctx, backend, testRoot(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss.Progress != sp {
+		t.Fatal("expected the session to report to the progress of its context")
+	}
+
+	ss.Progress.AddSealed(4)
+	ss.Progress.AddSealed(6)
+	ss.Progress.AddPlain()
+	ss.Progress.AddError(errors.New("sWorker is down"))
+
+	counts := sp.Value()
+	if counts.Blocks != 2 || counts.Bytes != 10 || counts.Plain != 1 || counts.Errors != 1 {
+		t.Fatalf("unexpected counts %+v", counts)
+	}
+	if counts.LastError != "sWorker is down" {
+		t.Fatalf("unexpected last error %q", counts.LastError)
+	}
+
+	// Sessions opened without a progress don't count.
+	ss, err = GetSealSession(GenSealContext(context.Background(), backend, testRoot(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss.Progress.AddSealed(4)
+}
@@ -0,0 +1,55 @@
+package spacex
+
+// SealState is how far the DAG of a pin made it into sealed storage.
+type SealState string
+
+const (
+	// SealStatePlain is the state of a DAG of which no block is sealed.
+	SealStatePlain SealState = "plain"
+
+	// SealStateSealing is the state of a DAG with an open seal session.
+	SealStateSealing SealState = "sealing"
+
+	// SealStateSealed is the state of a DAG whose blocks are all sealed,
+	// but those the sealing policy keeps in plain.
+	SealStateSealed SealState = "sealed"
+
+	// SealStatePartial is the state of a DAG of which some blocks are
+	// sealed and others are missing, or couldn't be walked since sealed
+	// blocks above them are unreadable.
+	SealStatePartial SealState = "partial"
+
+	// SealStateFailed is the state of a DAG whose last seal failed and
+	// which isn't fully sealed.
+	SealStateFailed SealState = "failed"
+)
+
+// SealStatus tells how the DAG of a pin is stored.
+type SealStatus struct {
+	State SealState
+
+	// Blocks is the number of blocks of the DAG, of which SealedBlocks are
+	// sealed and PlainBlocks are stored in plain.
+	Blocks       int
+	SealedBlocks int
+	PlainBlocks  int
+
+	// Missing is the number of blocks which should be sealed but are stored
+	// in plain, aren't stored at all or lost all their replicas.
+	Missing int `json:",omitempty"`
+
+	// Unreadable is the number of sealed blocks whose replicas couldn't be
+	// read back to walk their links, e.g. since the sWorker is down. The
+	// blocks below unreadable and missing ones aren't counted.
+	Unreadable int `json:",omitempty"`
+
+	// SealedBytes is the size of the sealed blocks. Replicas is the number
+	// of replicas of all sealed blocks, MinReplicas the least number of
+	// replicas of one of them.
+	SealedBytes uint64
+	Replicas    int
+	MinReplicas int
+
+	// LastError is the error the last seal of the root failed with.
+	LastError string `json:",omitempty"`
+}
@@ -2,50 +2,334 @@ package spacex
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ipfs/go-cid"
 )
 
-var sealedMap *safeSealedMap
-var sealBlackSet map[string]bool
-var sealBlackList = []string{
-	"QmQPeNsJPyVWPFDVHb77w8G42Fvo15z4bG2X8D2GhfbSXc",
-	"QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn",
-	"QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n",
+var (
+	// DefaultSWorkerTimeout is the deadline of a single sWorker request
+	DefaultSWorkerTimeout = 2 * time.Minute
+
+	// DefaultSWorkerRetries is the number of times a request failing with
+	// a transient error is retried
+	DefaultSWorkerRetries = 3
+
+	// DefaultSWorkerBreakerThreshold is the number of consecutive failed
+	// requests which open the circuit breaker
+	DefaultSWorkerBreakerThreshold = 5
+
+	// DefaultSWorkerBreakerCooldown is the time the circuit breaker stays
+	// open before a request is let through again
+	DefaultSWorkerBreakerCooldown = 30 * time.Second
+)
+
+// maxErrorBody is the most bytes of an error response read for its message.
+const maxErrorBody = 4096
+
+var (
+	// ErrNotConfigured is returned by the calls of an sWorker without url.
+	ErrNotConfigured = errors.New("sWorker url isn't set")
+
+	// ErrCircuitOpen is returned without calling the sWorker while its
+	// circuit breaker is open.
+	ErrCircuitOpen = errors.New("sWorker circuit breaker is open")
+)
+
+// Error is the error returned by the sWorker calls.
+type Error struct {
+	// Op is the sWorker API called, e.g. "seal".
+	Op string
+
+	// Code is the HTTP status code of the response, 0 if there was none.
+	Code int
+
+	// Status is the non-zero status_code of a failed seal response.
+	Status int64
+
+	// Message is the message of a failed seal response.
+	Message string
+
+	// Err is the cause of a failure without response.
+	Err error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Err != nil:
+		return fmt.Sprintf("sWorker %s: %s", e.Op, e.Err)
+	case e.Status != 0:
+		return fmt.Sprintf("sWorker %s: status code %d: %s", e.Op, e.Status, e.Message)
+	default:
+		return fmt.Sprintf("sWorker %s: HTTP error code %d", e.Op, e.Code)
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
 }
 
-func init() {
-	sealedMap = newSafeSealedMap()
-	sealBlackSet = make(map[string]bool)
-	for _, v := range sealBlackList {
-		sealBlackSet[v] = true
+// Temporary reports whether the call may succeed if retried.
+func (e *Error) Temporary() bool {
+	if e.Err != nil {
+		return e.Err != ErrNotConfigured && e.Err != ErrCircuitOpen && !errors.Is(e.Err, context.Canceled)
 	}
+	switch e.Code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func isTemporary(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Temporary()
+}
+
+// isServerError reports whether the sWorker answered with a server error.
+func isServerError(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code >= 500
+}
+
+// retrySafe reports whether the failed call to op can be sent again. Calls
+// which seal data aren't idempotent: they are only retried when the sWorker
+// refused them, not when they may have been handled but the answer was lost.
+func retrySafe(op string, err error) bool {
+	switch op {
+	case "seal", "seal_start", "seal_batch":
+		var e *Error
+		return errors.As(err, &e) && (e.Code == http.StatusTooManyRequests || e.Code == http.StatusServiceUnavailable)
+	}
+	return true
 }
 
 type sealResponse struct {
-	Path       string `json:"path"`
+	Path       string   `json:"path"`
+	Paths      []string `json:"paths"`
+	Message    string   `json:"message"`
+	StatusCode int64    `json:"status_code"`
+}
+
+type sealStartRequest struct {
+	Cid    string `json:"cid"`
+	CidB58 string `json:"cid_b58"`
+}
+
+type sealBatchRequest struct {
+	Cid      string   `json:"cid"`
+	NewBlock bool     `json:"new_block"`
+	Blocks   [][]byte `json:"blocks"`
+}
+
+type sealEndRequest struct {
+	Cid string `json:"cid"`
+}
+
+type pathRequest struct {
+	Path string `json:"path"`
+}
+
+type capacityResponse struct {
+	Free       uint64 `json:"free"`
 	Message    string `json:"message"`
 	StatusCode int64  `json:"status_code"`
 }
 
+// breaker is the circuit breaker of an sWorker. It opens after threshold
+// consecutive failures and then lets a single request through every
+// cooldown, closing again once one succeeds.
+type breaker struct {
+	lock      sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow reports whether a request may be sent, and whether it is the one
+// probing an open breaker.
+func (b *breaker) allow() (bool, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true, false
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+func (b *breaker) success() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+// abort lets another request probe the sWorker, when the probe gave up
+// without telling whether it is back.
+func (b *breaker) abort() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.probing = false
+}
+
+func (b *breaker) failure() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.failures++
+	b.probing = false
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// SWorkerOption configures an SWorker.
+type SWorkerOption func(*SWorker)
+
+// WithContext aborts the requests of the sWorker once ctx is done.
+func WithContext(ctx context.Context) SWorkerOption {
+	return func(sw *SWorker) {
+		sw.ctx = ctx
+	}
+}
+
+// WithTimeout sets the deadline of each request.
+func WithTimeout(timeout time.Duration) SWorkerOption {
+	return func(sw *SWorker) {
+		sw.timeout = timeout
+	}
+}
+
+// WithRetries sets how many times requests failing with a transient error
+// are retried, waiting minBackoff after the first failure and twice as long
+// after every next one, up to maxBackoff.
+func WithRetries(retries int, minBackoff, maxBackoff time.Duration) SWorkerOption {
+	return func(sw *SWorker) {
+		sw.retries = retries
+		sw.minBackoff = minBackoff
+		sw.maxBackoff = maxBackoff
+	}
+}
+
+// WithCircuitBreaker makes requests fail fast for cooldown after threshold
+// consecutive failures. A threshold of 0 disables the breaker.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) SWorkerOption {
+	return func(sw *SWorker) {
+		sw.breaker.threshold = threshold
+		sw.breaker.cooldown = cooldown
+	}
+}
+
+// WithBearerToken authenticates the requests with token.
+func WithBearerToken(token string) SWorkerOption {
+	return func(sw *SWorker) {
+		sw.token = token
+	}
+}
+
+// WithTLSConfig uses config for https urls, e.g. to present a client
+// certificate.
+func WithTLSConfig(config *tls.Config) SWorkerOption {
+	return func(sw *SWorker) {
+		sw.tlsConfig = config
+	}
+}
+
+// WithUnixSocket sends the requests over the unix socket at path. The host
+// of the url is then ignored.
+func WithUnixSocket(path string) SWorkerOption {
+	return func(sw *SWorker) {
+		sw.socket = path
+	}
+}
+
+// WithCapacityWait makes a reservation the sWorker has no room for wait up
+// to wait for other seals to end before failing.
+func WithCapacityWait(wait time.Duration) SWorkerOption {
+	return func(sw *SWorker) {
+		sw.capacityWait = wait
+	}
+}
+
+// SWorker is the SealBackend talking to an sWorker over HTTP. Requests have a
+// deadline, transient failures are retried with exponential backoff and a
+// circuit breaker makes calls fail fast while the sWorker is down.
 type SWorker struct {
+	retried uint64 // accessed atomically, kept first for alignment
+
 	lock   sync.Mutex
 	url    string
 	client http.Client
+
+	ctx        context.Context
+	timeout    time.Duration
+	retries    int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	token      string
+	tlsConfig  *tls.Config
+	socket     string
+	breaker    breaker
+
+	capacity     *capacityLedger
+	capacityWait time.Duration
+	noCapacity   int32
+	noBatch      int32
+	noRelease    int32
 }
 
-func NewSWorker(url string) *SWorker {
-	client := http.Client{
-		Timeout: 1000 * time.Second,
+var _ CapacityReserver = (*SWorker)(nil)
+var _ BatchSealer = (*SWorker)(nil)
+
+func NewSWorker(url string, opts ...SWorkerOption) *SWorker {
+	sw := &SWorker{
+		url:        url,
+		ctx:        context.Background(),
+		timeout:    DefaultSWorkerTimeout,
+		retries:    DefaultSWorkerRetries,
+		minBackoff: 100 * time.Millisecond,
+		maxBackoff: 5 * time.Second,
+		breaker: breaker{
+			threshold: DefaultSWorkerBreakerThreshold,
+			cooldown:  DefaultSWorkerBreakerCooldown,
+		},
+		capacity: newCapacityLedger(),
+	}
+	for _, opt := range opts {
+		opt(sw)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if sw.tlsConfig != nil {
+		transport.TLSClientConfig = sw.tlsConfig
 	}
-	return &SWorker{url: url, client: client}
+	if sw.socket != "" {
+		socket := sw.socket
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+	}
+	sw.client = http.Client{Transport: transport}
+	return sw
 }
 
 func (sw *SWorker) SetUrl(url string) {
@@ -61,188 +345,343 @@ func (sw *SWorker) GetUrl() string {
 	return url
 }
 
-func (sw *SWorker) StartSeal(root cid.Cid) (bool, error) {
-	// Not config sworker
-	if len(sw.GetUrl()) == 0 {
-		return false, nil
-	}
+func (sw *SWorker) Enabled() bool {
+	return len(sw.GetUrl()) != 0
+}
 
-	if _, ok := sealBlackSet[root.Hash().B58String()]; ok {
-		return false, nil
-	}
+// request sends one request to the sWorker and returns the body of a 200
+// response.
+func (sw *SWorker) request(ctx context.Context, op, method, rawurl string, body []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, sw.timeout)
+	defer cancel()
 
-	// Generate request
-	url := fmt.Sprintf("%s/storage/seal_start", sw.GetUrl())
-	value := fmt.Sprintf("{\"cid\":\"%s\", \"cid_b58\":\"%s\"}", root.String(), root.Hash().B58String())
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(value)))
+	req, err := http.NewRequestWithContext(ctx, method, rawurl, bytes.NewReader(body))
 	if err != nil {
-		return false, fmt.Errorf("Seal: %s", err)
+		return nil, &Error{Op: op, Err: err}
+	}
+	if sw.token != "" {
+		req.Header.Set("Authorization", "Bearer "+sw.token)
 	}
 
-	// Request
 	resp, err := sw.client.Do(req)
 	if err != nil {
-		return false, fmt.Errorf("Seal: %s", err)
+		return nil, &Error{Op: op, Err: err}
 	}
+	defer resp.Body.Close()
 
-	// Deal response
-	if resp.StatusCode == 200 {
-		returnBody, err := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return false, fmt.Errorf("Seal: %s", err)
+	if resp.StatusCode != http.StatusOK {
+		// Keep what the API said, routes it doesn't have answer no JSON.
+		e := &Error{Op: op, Code: resp.StatusCode}
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		var sealResp sealResponse
+		if json.Unmarshal(data, &sealResp) == nil {
+			e.Status, e.Message = sealResp.StatusCode, sealResp.Message
 		}
-		sealResp := &sealResponse{}
-		err = json.Unmarshal(returnBody, sealResp)
-		if err != nil {
-			return false, fmt.Errorf("Seal: %s", err)
+		return nil, e
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &Error{Op: op, Code: resp.StatusCode, Err: err}
+	}
+	return data, nil
+}
+
+// call posts body to the op API of the sWorker under ctx, retrying transient
+// failures through the circuit breaker until ctx or the context of the
+// sWorker is done. Calls the sWorker doesn't answer, or answers with a server
+// error, count as breaker failures.
+func (sw *SWorker) call(ctx context.Context, op string, query url.Values, body []byte) ([]byte, error) {
+	base := sw.GetUrl()
+	if base == "" {
+		return nil, &Error{Op: op, Err: ErrNotConfigured}
+	}
+	rawurl := fmt.Sprintf("%s/storage/%s", base, op)
+	if query != nil {
+		rawurl += "?" + query.Encode()
+	}
+
+	allowed, probe := sw.breaker.allow()
+	if !allowed {
+		return nil, &Error{Op: op, Err: ErrCircuitOpen}
+	}
+	// The probe let through by the breaker must report back, or no other
+	// request would be let through again.
+	reported := false
+	defer func() {
+		if probe && !reported {
+			sw.breaker.abort()
+		}
+	}()
+
+	backoff := sw.minBackoff
+	for attempt := 0; ; attempt++ {
+		data, err := sw.request(ctx, op, "POST", rawurl, body)
+		if !isTemporary(err) {
+			reported = true
+			if isServerError(err) {
+				sw.breaker.failure()
+			} else {
+				// The sWorker answered, even if with an error.
+				sw.breaker.success()
+			}
+			return data, err
+		}
+		if attempt >= sw.retries || !retrySafe(op, err) {
+			reported = true
+			sw.breaker.failure()
+			return nil, err
 		}
 
-		if sealResp.StatusCode != 0 {
-			fmt.Printf("%s\n", string(returnBody))
-			return false, fmt.Errorf("Seal response error code is: %d", sealResp.StatusCode)
+		atomic.AddUint64(&sw.retried, 1)
+		log.Debugf("retrying %s in %s: %s", op, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, &Error{Op: op, Err: ctx.Err()}
+		case <-sw.ctx.Done():
+			return nil, &Error{Op: op, Err: sw.ctx.Err()}
+		}
+		backoff *= 2
+		if backoff > sw.maxBackoff {
+			backoff = sw.maxBackoff
 		}
-		sealedMap.addRoot(root)
-		return true, nil
-	} else {
-		_, _ = io.Copy(ioutil.Discard, resp.Body)
-		resp.Body.Close()
-		return false, fmt.Errorf("Start Seal error code is: %d", resp.StatusCode)
 	}
-
 }
 
-func (sw *SWorker) Seal(root cid.Cid, newBlock bool, value []byte) (bool, string, error) {
-	// Not config sworker
-	if len(sw.GetUrl()) == 0 {
-		return false, "", nil
+// callJSON posts req as JSON and decodes the seal response.
+func (sw *SWorker) callJSON(ctx context.Context, op string, query url.Values, req interface{}, raw []byte) (*sealResponse, error) {
+	body := raw
+	if req != nil {
+		var err error
+		body, err = json.Marshal(req)
+		if err != nil {
+			return nil, &Error{Op: op, Err: err}
+		}
 	}
 
-	// Generate request
-	url := fmt.Sprintf("%s/storage/seal?cid=%s&new_block=%t", sw.GetUrl(), root.String(), newBlock)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(value))
+	data, err := sw.call(ctx, op, query, body)
 	if err != nil {
-		return false, "", fmt.Errorf("Seal: %s", err)
+		return nil, err
 	}
 
-	// Request
-	resp, err := sw.client.Do(req)
+	sealResp := &sealResponse{}
+	if err := json.Unmarshal(data, sealResp); err != nil {
+		return nil, &Error{Op: op, Code: http.StatusOK, Err: err}
+	}
+	if sealResp.StatusCode != 0 {
+		return nil, &Error{Op: op, Code: http.StatusOK, Status: sealResp.StatusCode, Message: sealResp.Message}
+	}
+	return sealResp, nil
+}
+
+// Retries returns the number of calls retried so far.
+func (sw *SWorker) Retries() uint64 {
+	return atomic.LoadUint64(&sw.retried)
+}
+
+// Ping checks that the sWorker answers HTTP requests. It bypasses the circuit
+// breaker and closes it when the sWorker is back.
+func (sw *SWorker) Ping(ctx context.Context) error {
+	_, err := sw.request(ctx, "ping", "GET", sw.GetUrl(), nil)
+	var e *Error
+	if errors.As(err, &e) && e.Code != 0 && e.Code < 500 {
+		err = nil
+	}
+	if err == nil {
+		sw.breaker.success()
+	}
+	return err
+}
+
+// Capacity returns the number of bytes the sWorker has room for.
+func (sw *SWorker) Capacity() (uint64, error) {
+	data, err := sw.call(sw.ctx, "capacity", nil, nil)
 	if err != nil {
-		return false, "", fmt.Errorf("Seal: %s", err)
+		return 0, err
 	}
 
-	// Deal response
-	if resp.StatusCode == 200 {
-		returnBody, err := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return false, "", fmt.Errorf("Seal: %s", err)
-		}
-		sealResp := &sealResponse{}
-		err = json.Unmarshal(returnBody, sealResp)
-		if err != nil {
-			return false, "", fmt.Errorf("Seal: %s", err)
-		}
+	resp := &capacityResponse{}
+	if err := json.Unmarshal(data, resp); err != nil {
+		return 0, &Error{Op: "capacity", Code: http.StatusOK, Err: err}
+	}
+	if resp.StatusCode != 0 {
+		return 0, &Error{Op: "capacity", Code: http.StatusOK, Status: resp.StatusCode, Message: resp.Message}
+	}
+	return resp.Free, nil
+}
 
-		if sealResp.StatusCode != 0 {
-			fmt.Printf("%s\n", string(returnBody))
-			return false, "", fmt.Errorf("Seal response error code is: %d", sealResp.StatusCode)
+// free returns the room left on the sWorker, or false if it predates the
+// capacity API.
+func (sw *SWorker) free() (uint64, bool, error) {
+	free, err := sw.Capacity()
+	var e *Error
+	if errors.As(err, &e) && e.Code == http.StatusNotFound {
+		if atomic.CompareAndSwapInt32(&sw.noCapacity, 0, 1) {
+			log.Warnf("sWorker at %s can't tell its capacity, pins are sealed without checking it", sw.GetUrl())
 		}
-		return true, sealResp.Path, nil
-	} else {
-		_, _ = io.Copy(ioutil.Discard, resp.Body)
-		resp.Body.Close()
-		return false, "", fmt.Errorf("Seal error code is: %d", resp.StatusCode)
+		return 0, false, nil
 	}
+	if err != nil {
+		return 0, false, err
+	}
+	return free, true, nil
+}
 
+// Reserve sets size bytes aside for the seal session of root if the sWorker
+// has room for them besides the other reservations.
+func (sw *SWorker) Reserve(ctx context.Context, root cid.Cid, size uint64) error {
+	// Not config sworker
+	if !sw.Enabled() {
+		return nil
+	}
+	return sw.capacity.reserve(ctx, root, size, sw.capacityWait, sw.free)
 }
 
-func (sw *SWorker) EndSeal(root cid.Cid) (bool, error) {
+// Unreserve gives back the bytes set aside for root.
+func (sw *SWorker) Unreserve(root cid.Cid) {
+	sw.capacity.unreserve(root)
+}
+
+func (sw *SWorker) StartSeal(root cid.Cid) (bool, error) {
 	// Not config sworker
-	if len(sw.GetUrl()) == 0 {
+	if !sw.Enabled() {
 		return false, nil
 	}
 
-	sealedMap.removeRoot(root)
+	req := &sealStartRequest{Cid: root.String(), CidB58: root.Hash().B58String()}
+	if _, err := sw.callJSON(sw.ctx, "seal_start", nil, req, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
 
-	// Generate request
-	url := fmt.Sprintf("%s/storage/seal_end", sw.GetUrl())
-	value := fmt.Sprintf("{\"cid\":\"%s\"}", root.String())
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(value)))
-	if err != nil {
-		return false, fmt.Errorf("Seal: %s", err)
+func (sw *SWorker) Seal(root cid.Cid, newBlock bool, value []byte) (bool, string, error) {
+	return sw.SealContext(sw.ctx, root, newBlock, value)
+}
+
+// SealContext is Seal, given up once ctx is done.
+func (sw *SWorker) SealContext(ctx context.Context, root cid.Cid, newBlock bool, value []byte) (bool, string, error) {
+	// Not config sworker
+	if !sw.Enabled() {
+		return false, "", nil
 	}
 
-	// Request
-	resp, err := sw.client.Do(req)
+	query := url.Values{}
+	query.Set("cid", root.String())
+	query.Set("new_block", strconv.FormatBool(newBlock))
+	if value == nil {
+		value = []byte{}
+	}
+	sealResp, err := sw.callJSON(ctx, "seal", query, nil, value)
 	if err != nil {
-		return false, fmt.Errorf("Seal: %s", err)
+		return false, "", err
 	}
+	sw.capacity.consume(root, uint64(len(value)))
+	return true, sealResp.Path, nil
+}
 
-	// Deal response
-	if resp.StatusCode == 200 {
-		returnBody, err := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return false, fmt.Errorf("Seal: %s", err)
-		}
-		sealResp := &sealResponse{}
-		err = json.Unmarshal(returnBody, sealResp)
-		if err != nil {
-			return false, fmt.Errorf("Seal: %s", err)
-		}
+// SealBatch stores values under the seal session of root in one call. It
+// seals them one by one if the sWorker predates the batch API.
+func (sw *SWorker) SealBatch(root cid.Cid, newBlock bool, values [][]byte) (bool, [][]string, error) {
+	// Not config sworker
+	if !sw.Enabled() {
+		return false, nil, nil
+	}
 
-		if sealResp.StatusCode != 0 {
-			fmt.Printf("%s\n", string(returnBody))
-			return false, fmt.Errorf("Seal response error code is: %d", sealResp.StatusCode)
+	if atomic.LoadInt32(&sw.noBatch) == 0 {
+		req := &sealBatchRequest{Cid: root.String(), NewBlock: newBlock, Blocks: values}
+		sealResp, err := sw.callJSON(sw.ctx, "seal_batch", nil, req, nil)
+		var e *Error
+		switch {
+		case errors.As(err, &e) && e.Code == http.StatusNotFound:
+			atomic.StoreInt32(&sw.noBatch, 1)
+			log.Infof("sWorker at %s can't seal batches, sealing blocks one by one", sw.GetUrl())
+		case err != nil:
+			return false, nil, err
+		case len(sealResp.Paths) != len(values):
+			return false, nil, &Error{Op: "seal_batch", Code: http.StatusOK, Err: fmt.Errorf("got %d paths for %d blocks", len(sealResp.Paths), len(values))}
+		default:
+			paths := make([][]string, len(values))
+			for i, value := range values {
+				sw.capacity.consume(root, uint64(len(value)))
+				paths[i] = []string{sealResp.Paths[i]}
+			}
+			return true, paths, nil
 		}
-
-		return true, nil
-	} else {
-		_, _ = io.Copy(ioutil.Discard, resp.Body)
-		resp.Body.Close()
-		return false, fmt.Errorf("End Seal error code is: %d", resp.StatusCode)
 	}
 
+	paths := make([][]string, 0, len(values))
+	for _, value := range values {
+		_, path, err := sw.Seal(root, newBlock, value)
+		if err != nil {
+			return len(paths) > 0, paths, err
+		}
+		paths = append(paths, []string{path})
+	}
+	return true, paths, nil
 }
 
-func (sw *SWorker) unseal(path string) ([]byte, error, int) {
+func (sw *SWorker) EndSeal(root cid.Cid) (bool, error) {
+	sw.capacity.unreserve(root)
+
 	// Not config sworker
-	if len(sw.GetUrl()) == 0 {
-		return nil, fmt.Errorf("Unseal missing spacex config"), 0
+	if !sw.Enabled() {
+		return false, nil
 	}
 
-	// Generate request
-	url := fmt.Sprintf("%s/storage/unseal", sw.GetUrl())
-	body := fmt.Sprintf("{\"path\":\"%s\"}", path)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(body)))
-	if err != nil {
-		return nil, fmt.Errorf("Unseal: %s", err), 0
+	if _, err := sw.callJSON(sw.ctx, "seal_end", nil, &sealEndRequest{Cid: root.String()}, nil); err != nil {
+		return false, err
 	}
+	return true, nil
+}
 
-	// Request
-	resp, err := sw.client.Do(req)
+func (sw *SWorker) Unseal(path string) ([]byte, error, int) {
+	return sw.UnsealContext(sw.ctx, path)
+}
+
+// UnsealContext is Unseal, given up once ctx is done.
+func (sw *SWorker) UnsealContext(ctx context.Context, path string) ([]byte, error, int) {
+	body, err := json.Marshal(&pathRequest{Path: path})
 	if err != nil {
-		return nil, fmt.Errorf("Unseal: %s", err), 0
+		return nil, &Error{Op: "unseal", Err: err}, 0
 	}
 
-	// Deal response
-	if resp.StatusCode == 200 {
-		returnBody, err := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			return nil, fmt.Errorf("Unseal: %s", err), 0
+	data, err := sw.call(ctx, "unseal", nil, body)
+	if err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			return nil, err, e.Code
 		}
-		return returnBody, nil, 200
-	} else {
-		_, _ = io.Copy(ioutil.Discard, resp.Body)
-		resp.Body.Close()
-		return nil, fmt.Errorf("Unseal error code is: %d", resp.StatusCode), resp.StatusCode
+		return nil, err, 0
 	}
+	return data, nil, http.StatusOK
 }
 
-var Worker *SWorker = nil
+// Release releases the replica at path. A replica the sWorker reports as
+// unknown counts as released, while a 404 without an API answer means the
+// sWorker has no release route and the release fails, to be retried.
+func (sw *SWorker) Release(path string) error {
+	return sw.ReleaseContext(sw.ctx, path)
+}
 
-func init() {
-	Worker = NewSWorker("")
+// ReleaseContext is Release, given up once ctx is done.
+func (sw *SWorker) ReleaseContext(ctx context.Context, path string) error {
+	body, err := json.Marshal(&pathRequest{Path: path})
+	if err != nil {
+		return &Error{Op: "release", Err: err}
+	}
+
+	_, err = sw.call(ctx, "release", nil, body)
+	var e *Error
+	if errors.As(err, &e) && e.Code == http.StatusNotFound {
+		if e.Status != 0 || e.Message != "" {
+			// Already gone is as good as released
+			return nil
+		}
+		if atomic.CompareAndSwapInt32(&sw.noRelease, 0, 1) {
+			log.Warnf("sWorker at %s can't release replicas, releases are kept until it can", sw.GetUrl())
+		}
+	}
+	return err
 }
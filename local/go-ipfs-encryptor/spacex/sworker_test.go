@@ -0,0 +1,386 @@
+package spacex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func testRoot(t *testing.T) cid.Cid {
+	h, err := mh.Sum([]byte("root"), mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cid.NewCidV0(h)
+}
+
+func TestSWorkerRequests(t *testing.T) {
+	root := testRoot(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/api/v0/storage/seal_start":
+			var req sealStartRequest
+			if err := json.Unmarshal(body, &req); err != nil || req.Cid != root.String() {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"status_code":0}`))
+		case "/api/v0/storage/seal":
+			if r.URL.Query().Get("cid") != root.String() || string(body) != "data" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"status_code":0,"path":"p1"}`))
+		case "/api/v0/storage/unseal":
+			var req pathRequest
+			if err := json.Unmarshal(body, &req); err != nil || req.Path != "p1" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte("data"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	sw := NewSWorker(srv.URL+"/api/v0", WithBearerToken("secret"))
+	if ok, err := sw.StartSeal(root); !ok || err != nil {
+		t.Fatalf("seal not started: %v", err)
+	}
+	ok, path, err := sw.Seal(root, false, []byte("data"))
+	if !ok || err != nil || path != "p1" {
+		t.Fatalf("seal: %t %q %v", ok, path, err)
+	}
+	data, err, code := sw.Unseal("p1")
+	if err != nil || code != http.StatusOK || string(data) != "data" {
+		t.Fatalf("unseal: %q %v %d", data, err, code)
+	}
+	if _, err, code := sw.Unseal("p2"); err == nil || code != http.StatusNotFound {
+		t.Fatalf("unseal of unknown path: %v %d", err, code)
+	}
+
+	// A failed seal response is reported as such.
+	_, err = NewSWorker(srv.URL + "/api/v0").StartSeal(root)
+	var e *Error
+	if !errors.As(err, &e) || e.Code != http.StatusUnauthorized || e.Temporary() {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestSWorkerRelease(t *testing.T) {
+	var routed int32 = 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/storage/release" || atomic.LoadInt32(&routed) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		var req pathRequest
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &req); err != nil || req.Path != "p1" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status_code":404,"message":"replica not found"}`))
+			return
+		}
+		w.Write([]byte(`{"status_code":0}`))
+	}))
+	defer srv.Close()
+
+	sw := NewSWorker(srv.URL)
+	if err := sw.Release("p1"); err != nil {
+		t.Fatal(err)
+	}
+	// The sWorker doesn't know the replica, it is as good as released.
+	if err := sw.Release("p2"); err != nil {
+		t.Fatalf("release of unknown path: %v", err)
+	}
+
+	// Without a release route, the release fails and stays queued.
+	atomic.StoreInt32(&routed, 0)
+	var e *Error
+	if err := sw.Release("p1"); !errors.As(err, &e) || e.Code != http.StatusNotFound {
+		t.Fatalf("expected release to fail with 404, got %v", err)
+	}
+	rq := NewReleaseQueue(sw, dssync.MutexWrap(ds.NewMapDatastore()))
+	if err := rq.Release("p1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rq.Flush(); err == nil {
+		t.Fatal("expected flush to fail")
+	}
+	if pending, err := rq.Pending(); err != nil || len(pending) != 1 {
+		t.Fatalf("expected the release to stay queued, got %v %v", pending, err)
+	}
+}
+
+func TestSWorkerRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("data"))
+	}))
+	defer srv.Close()
+
+	sw := NewSWorker(srv.URL, WithRetries(3, time.Millisecond, 10*time.Millisecond))
+	data, err, _ := sw.Unseal("p1")
+	if err != nil || string(data) != "data" {
+		t.Fatalf("unseal: %q %v", data, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Fatalf("expected 3 calls, got %d", n)
+	}
+	if n := Retries(NewMeteredBackend(sw, NewSealStats())); n != 2 {
+		t.Fatalf("expected 2 retries, got %d", n)
+	}
+}
+
+func TestSWorkerCircuitBreaker(t *testing.T) {
+	var calls, down int32 = 0, 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("data"))
+	}))
+	defer srv.Close()
+
+	sw := NewSWorker(srv.URL,
+		WithRetries(0, 0, 0),
+		WithCircuitBreaker(2, 50*time.Millisecond))
+	for i := 0; i < 2; i++ {
+		if _, err, code := sw.Unseal("p1"); err == nil || code != http.StatusBadGateway {
+			t.Fatalf("unseal: %v %d", err, code)
+		}
+	}
+
+	// The breaker is open, the sWorker isn't called.
+	_, err, code := sw.Unseal("p1")
+	if !errors.Is(err, ErrCircuitOpen) || code != 0 {
+		t.Fatalf("expected open circuit, got %v %d", err, code)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("expected 2 calls, got %d", n)
+	}
+
+	// After the cooldown a request goes through and closes it.
+	atomic.StoreInt32(&down, 0)
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if _, err, _ := sw.Unseal("p1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSWorkerAbandonedProbe(t *testing.T) {
+	var status int32 = http.StatusInternalServerError
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code := atomic.LoadInt32(&status); code != http.StatusOK {
+			w.WriteHeader(int(code))
+			return
+		}
+		w.Write([]byte("data"))
+	}))
+	defer srv.Close()
+
+	sw := NewSWorker(srv.URL,
+		WithRetries(1, time.Minute, time.Minute),
+		WithCircuitBreaker(1, 10*time.Millisecond))
+	if _, err, _ := sw.Unseal("p1"); err == nil {
+		t.Fatal("expected unseal to fail")
+	}
+
+	// The probe is given up while waiting to retry.
+	atomic.StoreInt32(&status, http.StatusBadGateway)
+	time.Sleep(20 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err, _ := sw.UnsealContext(ctx, "p1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the probe to time out, got %v", err)
+	}
+
+	// The next request probes again.
+	atomic.StoreInt32(&status, http.StatusOK)
+	if _, err, _ := sw.Unseal("p1"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSWorkerServerErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	// Server errors aren't retried but open the breaker.
+	sw := NewSWorker(srv.URL, WithCircuitBreaker(2, time.Minute))
+	for i := 0; i < 2; i++ {
+		if _, err, code := sw.Unseal("p1"); err == nil || code != http.StatusInternalServerError {
+			t.Fatalf("unseal: %v %d", err, code)
+		}
+	}
+	if _, err, _ := sw.Unseal("p1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected open circuit, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("expected 2 calls, got %d", n)
+	}
+}
+
+func TestSWorkerSealNotRetried(t *testing.T) {
+	root := testRoot(t)
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		// Drop the connection, as if the sWorker crashed mid-request.
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	sw := NewSWorker(srv.URL, WithRetries(3, time.Millisecond, time.Millisecond))
+	if _, _, err := sw.Seal(root, false, []byte("data")); err == nil {
+		t.Fatal("expected seal to fail")
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("seal may have been handled, expected 1 call, got %d", n)
+	}
+
+	// Reads are retried.
+	atomic.StoreInt32(&calls, 0)
+	if _, err, _ := sw.Unseal("p1"); err == nil {
+		t.Fatal("expected unseal to fail")
+	}
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Fatalf("expected 4 calls, got %d", n)
+	}
+}
+
+func TestSWorkerCapacity(t *testing.T) {
+	var free int64 = 100
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/storage/capacity":
+			fmt.Fprintf(w, `{"status_code":0,"free":%d}`, atomic.LoadInt64(&free))
+		case "/storage/seal":
+			atomic.AddInt64(&free, -int64(len(body)))
+			w.Write([]byte(`{"status_code":0,"path":"p1"}`))
+		default:
+			w.Write([]byte(`{"status_code":0}`))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	a, b := testRoot(t), cid.NewCidV1(cid.Raw, testRoot(t).Hash())
+	sw := NewSWorker(srv.URL, WithCapacityWait(50*time.Millisecond))
+	if err := sw.Reserve(ctx, a, 60); err != nil {
+		t.Fatal(err)
+	}
+
+	// What a seals is taken off its reservation, not off the room left.
+	if _, _, err := sw.Seal(a, true, make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Reserve(ctx, b, 60); !errors.Is(err, ErrNoCapacity) {
+		t.Fatalf("expected ErrNoCapacity, got %v", err)
+	}
+
+	// b waits for a to end.
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		sw.EndSeal(a)
+	}()
+	if err := sw.Reserve(ctx, b, 60); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Reserve(ctx, a, 60); !errors.Is(err, ErrNoCapacity) {
+		t.Fatalf("expected ErrNoCapacity, got %v", err)
+	}
+	sw.Unreserve(b)
+
+	// An sWorker which can't tell grants any reservation.
+	old := httptest.NewServer(http.NotFoundHandler())
+	defer old.Close()
+	if err := NewSWorker(old.URL).Reserve(ctx, a, 1<<40); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSWorkerSealBatch(t *testing.T) {
+	var batches, seals int32
+	handler := func(batch bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			switch {
+			case r.URL.Path == "/storage/seal_batch" && batch:
+				atomic.AddInt32(&batches, 1)
+				var req sealBatchRequest
+				if err := json.Unmarshal(body, &req); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				paths := make([]string, len(req.Blocks))
+				for i, b := range req.Blocks {
+					paths[i] = string(b)
+				}
+				json.NewEncoder(w).Encode(&sealResponse{Paths: paths})
+			case r.URL.Path == "/storage/seal":
+				atomic.AddInt32(&seals, 1)
+				json.NewEncoder(w).Encode(&sealResponse{Path: string(body)})
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}
+	}
+
+	root := testRoot(t)
+	values := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	for _, batch := range []bool{true, false} {
+		srv := httptest.NewServer(handler(batch))
+		sw := NewSWorker(srv.URL)
+		// The second batch goes straight to single seals.
+		for i := 0; i < 2; i++ {
+			ok, paths, err := SealBatch(NewMeteredBackend(sw, NewSealStats()), root, false, values)
+			if !ok || err != nil || len(paths) != len(values) {
+				t.Fatalf("seal batch: %t %v %v", ok, paths, err)
+			}
+			for j, p := range paths {
+				if len(p) != 1 || p[0] != string(values[j]) {
+					t.Fatalf("unexpected paths %v", paths)
+				}
+			}
+		}
+		srv.Close()
+	}
+	if batches != 2 || seals != 6 {
+		t.Fatalf("expected 2 batches and 6 single seals, got %d and %d", batches, seals)
+	}
+}
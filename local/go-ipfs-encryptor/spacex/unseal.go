@@ -1,27 +1,112 @@
 package spacex
 
 import (
-	"fmt"
+	"errors"
+	"math/rand"
 
-	"github.com/dgraph-io/badger"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
 )
 
-func Unseal(path string) ([]byte, error, int) {
-	return Worker.unseal(path)
-}
+var log = logging.Logger("spacex")
+
+// ErrNoReplica is returned when none of the replicas of a sealed block can be
+// read back.
+var ErrNoReplica = errors.New("no readable sealed replica")
 
-func GetSize(item *badger.Item) (int, error) {
-	value, err := item.ValueCopy(nil)
-	if err != nil {
-		return -1, err
+// UnsealInfo reads the sealed block c back from one of the replicas in si,
+// starting at a random one and trying the ones the backend knows to be on an
+// unhealthy endpoint last. The unsealed data is checked against the hash of
+// c. Replicas the backend can't find (404) or which unseal to corrupted data
+// are removed from si, the corrupted ones being released, while lost ones
+// (410) and unreachable ones are skipped. The
+// returned bool reports whether si was changed and should be written back.
+// If no replica could be read and some were unreachable, the last transport
+// error is returned instead of ErrNoReplica. If cache is set, it is looked up
+// first and filled with the unsealed data.
+func UnsealInfo(backend SealBackend, cache *UnsealCache, c cid.Cid, si *SealedInfo) ([]byte, bool, error) {
+	sbsLen := len(si.Sbs)
+	if sbsLen == 0 {
+		return nil, false, ErrNoReplica
+	}
+
+	if cache != nil {
+		if data, ok := cache.Get(c, si); ok {
+			return data, false, nil
+		}
 	}
 
-	if ok, si := TryGetSealedInfo(value); ok {
-		if len(si.Sbs) == 0 {
-			return -1, fmt.Errorf("Sbs is empty, can't get block size")
+	order := make([]SealedBlock, 0, sbsLen)
+	var down []SealedBlock
+	rindex := rand.Intn(sbsLen)
+	for i := 0; i < sbsLen; i++ {
+		sb := si.Sbs[(rindex+i)%sbsLen]
+		if ReplicaHealthy(backend, sb.Path) {
+			order = append(order, sb)
+		} else {
+			down = append(down, sb)
+		}
+	}
+	order = append(order, down...)
+
+	pruned := make(map[string]bool)
+	var lastErr error
+	for _, sb := range order {
+		ret, err, code := backend.Unseal(sb.Path)
+		switch {
+		case err == nil && verifyUnsealed(c, ret):
+			if cache != nil {
+				cache.Add(c, sb.Path, ret)
+			}
+			return ret, pruneReplicas(si, pruned), nil
+		case err == nil:
+			log.Errorf("replica %s of %s unsealed to corrupted data, dropping it", sb.Path, c)
+			ObservePrune(backend, PruneCorrupt)
+			pruned[sb.Path] = true
+			if err := backend.Release(sb.Path); err != nil {
+				log.Warnf("releasing corrupted replica %s: %s", sb.Path, err)
+			}
+		// Can't find
+		case code == 404:
+			ObservePrune(backend, PruneMissing)
+			pruned[sb.Path] = true
+		// Lost
+		case code == 410:
+		default:
+			// The backend couldn't be reached, the replica may be fine.
+			lastErr = err
+			continue
+		}
+		if pruned[sb.Path] && cache != nil {
+			cache.Remove(sb.Path)
 		}
-		return si.Sbs[0].Size, nil
 	}
 
-	return len(value), nil
+	changed := pruneReplicas(si, pruned)
+	if lastErr != nil {
+		return nil, changed, lastErr
+	}
+	return nil, changed, ErrNoReplica
+}
+
+// pruneReplicas removes the replicas at the paths of pruned from si and
+// reports whether any was.
+func pruneReplicas(si *SealedInfo, pruned map[string]bool) bool {
+	if len(pruned) == 0 {
+		return false
+	}
+	alive := si.Sbs[:0]
+	for _, sb := range si.Sbs {
+		if !pruned[sb.Path] {
+			alive = append(alive, sb)
+		}
+	}
+	si.Sbs = alive
+	return true
+}
+
+// verifyUnsealed reports whether data hashes to c.
+func verifyUnsealed(c cid.Cid, data []byte) bool {
+	sum, err := c.Prefix().Sum(data)
+	return err == nil && sum.Equals(c)
 }
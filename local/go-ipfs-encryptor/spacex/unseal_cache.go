@@ -0,0 +1,113 @@
+package spacex
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	metrics "github.com/ipfs/go-metrics-interface"
+)
+
+type unsealKey struct {
+	c    cid.Cid
+	path string
+}
+
+type unsealEntry struct {
+	key  unsealKey
+	data []byte
+}
+
+// UnsealCache keeps the data of recently unsealed blocks, keyed by block and
+// replica path, so that popular sealed blocks aren't unsealed on every read.
+// It is bounded by the total size of the data it holds and evicts the least
+// recently used blocks first.
+type UnsealCache struct {
+	lk      sync.Mutex
+	maxSize int
+	size    int
+	lru     *list.List
+	items   map[unsealKey]*list.Element
+	paths   map[string]unsealKey
+
+	hits   metrics.Counter
+	misses metrics.Counter
+}
+
+// NewUnsealCache creates a cache holding up to maxSize bytes of unsealed
+// data. Its metrics are registered in the scope of ctx.
+func NewUnsealCache(ctx context.Context, maxSize int) *UnsealCache {
+	return &UnsealCache{
+		maxSize: maxSize,
+		lru:     list.New(),
+		items:   make(map[unsealKey]*list.Element),
+		paths:   make(map[string]unsealKey),
+		hits:    metrics.NewCtx(ctx, "unseal_cache.hits_total", "Number of unseal cache hits").Counter(),
+		misses:  metrics.NewCtx(ctx, "unseal_cache.misses_total", "Number of unseal cache misses").Counter(),
+	}
+}
+
+// Get returns the cached data of c, unsealed from one of the replicas in si.
+func (uc *UnsealCache) Get(c cid.Cid, si *SealedInfo) ([]byte, bool) {
+	uc.lk.Lock()
+	defer uc.lk.Unlock()
+
+	for _, sb := range si.Sbs {
+		if e, ok := uc.items[unsealKey{c: c, path: sb.Path}]; ok {
+			uc.lru.MoveToFront(e)
+			uc.hits.Inc()
+			return e.Value.(*unsealEntry).data, true
+		}
+	}
+	uc.misses.Inc()
+	return nil, false
+}
+
+// Add caches data, unsealed from the replica of c at path. Data larger than
+// the cache isn't kept.
+func (uc *UnsealCache) Add(c cid.Cid, path string, data []byte) {
+	if len(data) > uc.maxSize {
+		return
+	}
+
+	uc.lk.Lock()
+	defer uc.lk.Unlock()
+
+	key := unsealKey{c: c, path: path}
+	if e, ok := uc.items[key]; ok {
+		uc.lru.MoveToFront(e)
+		return
+	}
+
+	for uc.size+len(data) > uc.maxSize {
+		uc.remove(uc.lru.Back())
+	}
+	uc.items[key] = uc.lru.PushFront(&unsealEntry{key: key, data: data})
+	uc.paths[path] = key
+	uc.size += len(data)
+}
+
+// Remove drops the data unsealed from the replica at path.
+func (uc *UnsealCache) Remove(path string) {
+	uc.lk.Lock()
+	defer uc.lk.Unlock()
+
+	if key, ok := uc.paths[path]; ok {
+		uc.remove(uc.items[key])
+	}
+}
+
+func (uc *UnsealCache) remove(e *list.Element) {
+	entry := uc.lru.Remove(e).(*unsealEntry)
+	delete(uc.items, entry.key)
+	delete(uc.paths, entry.key.path)
+	uc.size -= len(entry.data)
+}
+
+// Size returns the total size of the cached data.
+func (uc *UnsealCache) Size() int {
+	uc.lk.Lock()
+	defer uc.lk.Unlock()
+	return uc.size
+}
@@ -0,0 +1,82 @@
+package spacex
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// unreachableBackend fails to unseal as if its sWorker was down.
+type unreachableBackend struct {
+	*LocalBackend
+	calls int32
+}
+
+func (ub *unreachableBackend) Unseal(path string) ([]byte, error, int) {
+	atomic.AddInt32(&ub.calls, 1)
+	return nil, errors.New("connection refused"), 0
+}
+
+func TestUnsealInfoUnreachable(t *testing.T) {
+	up := NewLocalBackend()
+	down := &unreachableBackend{LocalBackend: NewLocalBackend()}
+	pool, err := NewPool([]Endpoint{
+		{Name: "up", Backend: up},
+		{Name: "down", Backend: down},
+	}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := []byte("sealed block")
+	h, err := mh.Sum(value, mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cid.NewCidV1(cid.Raw, h)
+	seal := func(name string, lb *LocalBackend) string {
+		if _, err := lb.StartSeal(c); err != nil {
+			t.Fatal(err)
+		}
+		_, path, err := lb.Seal(c, false, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return joinPath(name, path)
+	}
+	good, unreachable := seal("up", up), seal("down", down.LocalBackend)
+
+	// An unreachable replica is skipped, not pruned, and its endpoint is
+	// tried last from then on.
+	for i := 0; i < 10; i++ {
+		si := &SealedInfo{Sbs: []SealedBlock{{Path: unreachable}, {Path: good}}}
+		data, changed, err := UnsealInfo(pool, nil, c, si)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, value) {
+			t.Fatal("unsealed data differs from the original")
+		}
+		if changed || len(si.Sbs) != 2 {
+			t.Fatalf("expected no replica to be pruned, got %v", si.Sbs)
+		}
+	}
+	if calls := atomic.LoadInt32(&down.calls); calls > 1 {
+		t.Fatalf("unhealthy endpoint called %d times", calls)
+	}
+
+	// Without a readable replica, the transport error is returned and only
+	// the missing replica is pruned.
+	si := &SealedInfo{Sbs: []SealedBlock{{Path: joinPath("up", "missing")}, {Path: unreachable}}}
+	_, changed, err := UnsealInfo(pool, nil, c, si)
+	if err == nil || err == ErrNoReplica {
+		t.Fatalf("expected the transport error, got %v", err)
+	}
+	if !changed || len(si.Sbs) != 1 || si.Sbs[0].Path != unreachable {
+		t.Fatalf("expected only the missing replica to be pruned, got %v", si.Sbs)
+	}
+}
@@ -9,11 +9,14 @@ import (
 	"fmt"
 	"path"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 	"github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/query"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	ipfspinner "github.com/ipfs/go-ipfs-pinner"
 	"github.com/ipfs/go-ipfs-pinner/dsindex"
 	ipld "github.com/ipfs/go-ipld-format"
@@ -92,6 +95,9 @@ type pinner struct {
 
 	dserv  ipld.DAGService
 	dstore ds.Datastore
+	sealer spacex.SealBackend
+	sealJn *spacex.SealJournal
+	policy *spacex.SealPolicy
 
 	cidDIndex dsindex.Indexer
 	cidRIndex dsindex.Indexer
@@ -99,9 +105,42 @@ type pinner struct {
 
 	clean int64
 	dirty int64
+
+	sealHook atomic.Value // func(cid.Cid, error)
 }
 
 var _ ipfspinner.Pinner = (*pinner)(nil)
+var _ SealNotifier = (*pinner)(nil)
+var _ RootSealer = (*pinner)(nil)
+var _ SealResumer = (*pinner)(nil)
+
+// SealNotifier is implemented by pinners telling when the seal of a pinned
+// root ends.
+type SealNotifier interface {
+	// OnSeal sets hook to be called with the root of each seal ending, and
+	// the error it failed with or nil if it completed. hook must not block.
+	OnSeal(hook func(root cid.Cid, err error))
+}
+
+// RootSealer is implemented by pinners sealing pinned roots on demand.
+type RootSealer interface {
+	// SealRoot seals the DAG of the recursively pinned root, whatever the
+	// seal policy says, and stores sealed stubs in place of its blocks. The
+	// blocks ks are sealed as they are, without reading their links: the
+	// DAG is only walked from root down to them. A root sealed already is
+	// left as it is.
+	SealRoot(ctx context.Context, root cid.Cid, ks []cid.Cid) error
+}
+
+// SealResumer is implemented by pinners resuming the seal sessions a previous
+// run left open in the seal journal.
+type SealResumer interface {
+	// ResumeSeal resumes the open seal session of root the way it was
+	// opened: an interrupted pin is pinned again, an interrupted update
+	// updated again and the seal of a root pinned already completed. The
+	// sWorker side of the session must have been ended.
+	ResumeSeal(ctx context.Context, root cid.Cid) error
+}
 
 type pin struct {
 	Id       string
@@ -132,12 +171,25 @@ type syncDAGService interface {
 // New creates a new pinner and loads its keysets from the given datastore. If
 // there is no data present in the datastore, then an empty pinner is returned.
 func New(ctx context.Context, dstore ds.Datastore, dserv ipld.DAGService) (ipfspinner.Pinner, error) {
+	return NewWithSealBackend(ctx, dstore, dserv, spacex.NewNoopBackend(), nil)
+}
+
+// NewWithSealBackend creates a new pinner like New, which seals recursively
+// pinned DAGs through the given seal backend when policy allows it. A nil
+// policy is spacex.DefaultSealPolicy.
+func NewWithSealBackend(ctx context.Context, dstore ds.Datastore, dserv ipld.DAGService, sealer spacex.SealBackend, policy *spacex.SealPolicy) (ipfspinner.Pinner, error) {
+	if policy == nil {
+		policy = spacex.DefaultSealPolicy()
+	}
 	p := &pinner{
 		cidDIndex: dsindex.New(dstore, ds.NewKey(pinCidDIndexPath)),
 		cidRIndex: dsindex.New(dstore, ds.NewKey(pinCidRIndexPath)),
 		nameIndex: dsindex.New(dstore, ds.NewKey(pinNameIndexPath)),
 		dserv:     dserv,
 		dstore:    dstore,
+		sealer:    sealer,
+		sealJn:    spacex.NewSealJournal(dstore),
+		policy:    policy,
 	}
 
 	data, err := dstore.Get(dirtyKey)
@@ -191,35 +243,79 @@ func (p *pinner) Pin(ctx context.Context, node ipld.Node, recurse bool) error {
 		// temporary unlock to fetch the entire graph
 		p.lock.Unlock()
 
-		// Start seal
-		needSeal, err := spacex.Worker.StartSeal(c)
-
-		if err != nil {
-			p.lock.Lock()
-			return err
+		// Start seal, if the policy allows it and the backend has room
+		needSeal := false
+		decision := p.policy.CheckRoot(c, dagSize(node), len(node.RawData()))
+		if decision.Sealed {
+			if err = spacex.Reserve(ctx, p.sealer, c, dagSize(node)); err != nil {
+				p.recordSeal(c, err)
+				p.lock.Lock()
+				return err
+			}
+			needSeal, err = p.sealer.StartSeal(c)
+			if err != nil || !needSeal {
+				spacex.Unreserve(p.sealer, c)
+			}
+			if err != nil {
+				p.recordSeal(c, err)
+				p.lock.Lock()
+				return err
+			}
+			if !needSeal {
+				decision.Sealed = false
+				decision.Reason = "sealing is disabled"
+			}
 		}
 
-		if needSeal {
-			ctx = spacex.GenSealContext(ctx, c)
+		var ss *spacex.SealSession
+		if !needSeal {
+			// Pinned blocks are written to the blockstore, not left to
+			// the fetch cache.
+			ctx = spacex.WithStoragePolicy(ctx, spacex.StorePlain)
+		} else {
+			ctx, err = p.sealJn.GenSealContext(ctx, p.sealer, c)
+			if err != nil {
+				p.sealer.EndSeal(c)
+				p.recordSeal(c, err)
+				p.lock.Lock()
+				return err
+			}
+			ss, _ = spacex.GetSealSession(ctx)
+			ss.Policy = p.policy
 		}
 
 		// Fetch graph starting at node identified by cid
 		err = mdag.FetchGraph(ctx, c, p.dserv)
+		if needSeal {
+			// Wait for the blocks queued to be sealed.
+			if closeErr := ss.Close(); err == nil {
+				err = closeErr
+			}
+		}
 		if err != nil {
 			p.lock.Lock()
 			if needSeal {
-				spacex.Worker.EndSeal(c)
+				p.sealer.EndSeal(c)
+				p.sealJn.Finish(c)
+				p.recordSeal(c, err)
 			}
 			return err
 		}
 		p.lock.Lock()
 
 		if needSeal {
-			_, err = spacex.Worker.EndSeal(c)
+			_, err = p.sealer.EndSeal(c)
+			if err == nil {
+				err = p.sealJn.Finish(c)
+			}
+			p.recordSeal(c, err)
 			if err != nil {
 				return err
 			}
+			decision.PlainBlocks = ss.PlainBlocks()
 		}
+		p.policy.Record(decision)
+		log.Infof("pin of %s sealed: %t (%s)", c, decision.Sealed, decision.Reason)
 
 		// Only look again if something has changed.
 		if p.dirty != dirtyBefore {
@@ -262,6 +358,16 @@ func (p *pinner) Pin(ctx context.Context, node ipld.Node, recurse bool) error {
 	return nil
 }
 
+// dagSize returns the size of the DAG rooted at node as told by node, e.g.
+// the cumulative size of a unixfs node, or 0 if it can't tell.
+func dagSize(node ipld.Node) uint64 {
+	size, err := node.Size()
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
 func (p *pinner) addPin(ctx context.Context, c cid.Cid, mode ipfspinner.Mode, name string) (string, error) {
 	// Create new pin and store in datastore
 	pp := newPin(c, mode, name)
@@ -375,14 +481,184 @@ func (p *pinner) Unpin(ctx context.Context, c cid.Cid, recursive bool) error {
 		}
 	}
 
-	_, err = p.removePinsForCid(ctx, c, ipfspinner.Any)
+	removed, err := p.removePinsForCid(ctx, c, ipfspinner.Any)
+	if err != nil {
+		return err
+	}
+
+	if removed && recursive {
+		return p.forgetSealed(c)
+	}
+	return nil
+}
+
+// forgetSealed moves the replicas sealed under root aside and forgets its
+// seal error. The replicas themselves are kept: their blocks may still be
+// referenced by other pins or by MFS, and each is released along with its
+// stub once GC deletes the block, or on demand.
+func (p *pinner) forgetSealed(root cid.Cid) error {
+	if err := p.sealJn.ClearFailure(root); err != nil {
+		return err
+	}
+	return p.sealJn.Unpin(root)
+}
+
+// SealRoot seals the DAG of root, see RootSealer.
+func (p *pinner) SealRoot(ctx context.Context, root cid.Cid, ks []cid.Cid) error {
+	p.lock.RLock()
+	found, err := p.cidRIndex.HasAny(ctx, root.KeyString())
+	p.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%s is not pinned recursively", root)
+	}
+	if sealed, err := p.sealJn.Sealed(root); err != nil || sealed {
+		return err
+	}
+
+	node, err := p.dserv.Get(ctx, root)
 	if err != nil {
 		return err
 	}
 
+	if err := spacex.Reserve(ctx, p.sealer, root, dagSize(node)); err != nil {
+		p.recordSeal(root, err)
+		return err
+	}
+	needSeal, err := p.sealer.StartSeal(root)
+	if err != nil || !needSeal {
+		spacex.Unreserve(p.sealer, root)
+	}
+	if err != nil {
+		p.recordSeal(root, err)
+		return err
+	}
+	if !needSeal {
+		return errors.New("sealing is disabled")
+	}
+
+	sctx, err := p.sealJn.GenSessionContext(ctx, p.sealer, root, spacex.Session{Kind: spacex.SessionSealRoot})
+	if err != nil {
+		p.sealer.EndSeal(root)
+		p.recordSeal(root, err)
+		return err
+	}
+	ss, _ := spacex.GetSealSession(sctx)
+
+	err = sealBlocks(sctx, p.dserv, root, ks)
+	if closeErr := ss.Close(); err == nil {
+		err = closeErr
+	}
+	if _, endErr := p.sealer.EndSeal(root); err == nil {
+		err = endErr
+	}
+	if finErr := p.sealJn.Finish(root); err == nil {
+		err = finErr
+	}
+	p.recordSeal(root, err)
+	if err != nil {
+		return err
+	}
+
+	p.policy.Record(spacex.SealDecision{
+		Root:        root,
+		Sealed:      true,
+		Reason:      "sealing was asked for",
+		Priority:    p.policy.Priority(root),
+		PlainBlocks: ss.PlainBlocks(),
+		Time:        time.Now(),
+	})
+	log.Infof("pin of %s sealed: true (sealing was asked for)", root)
 	return nil
 }
 
+// ResumeSeal resumes the seal session of root, see SealResumer.
+func (p *pinner) ResumeSeal(ctx context.Context, root cid.Cid) error {
+	session, err := p.sealJn.Session(root)
+	if err != nil {
+		return err
+	}
+
+	p.lock.RLock()
+	pinned, err := p.cidRIndex.HasAny(ctx, root.KeyString())
+	fromPinned := false
+	if err == nil && session.Kind == spacex.SessionUpdate {
+		fromPinned, err = p.cidRIndex.HasAny(ctx, session.From.KeyString())
+	}
+	p.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case session.Kind == spacex.SessionReseal:
+		// The scrubber seals the lost blocks again on its next pass.
+		return p.sealJn.Finish(root)
+	case pinned:
+		// The root was sealed on demand, or pinned again since: only its
+		// seal is left.
+		return p.SealRoot(ctx, root, nil)
+	case session.Kind == spacex.SessionSealRoot:
+		log.Warnf("%s was unpinned while sealed, dropping its seal", root)
+		return p.sealJn.Finish(root)
+	case session.Kind == spacex.SessionUpdate && fromPinned:
+		return p.Update(ctx, session.From, root, session.Unpin)
+	}
+
+	node, err := p.dserv.Get(ctx, root)
+	if err != nil {
+		return err
+	}
+	return p.Pin(ctx, node, true)
+}
+
+// sealBlocks reads the blocks ks and the DAG of root down to them through
+// dserv, under the seal session of ctx.
+func sealBlocks(ctx context.Context, dserv ipld.DAGService, root cid.Cid, ks []cid.Cid) error {
+	given := cid.NewSet()
+	for _, c := range ks {
+		given.Add(c)
+	}
+
+	seen := cid.NewSet()
+	err := mdag.Walk(ctx, mdag.GetLinksDirect(dserv), root, func(c cid.Cid) bool {
+		return !given.Has(c) && seen.Visit(c)
+	}, mdag.Concurrent())
+	if err != nil {
+		return err
+	}
+
+	for opt := range dserv.GetMany(ctx, given.Keys()) {
+		if opt.Err != nil {
+			return opt.Err
+		}
+	}
+	return ctx.Err()
+}
+
+// OnSeal sets hook to be called with the root of each seal ending.
+func (p *pinner) OnSeal(hook func(root cid.Cid, err error)) {
+	p.sealHook.Store(hook)
+}
+
+// recordSeal keeps err as the error the seal of root failed with, or forgets
+// the previous one if root was sealed, and tells the seal hook.
+func (p *pinner) recordSeal(root cid.Cid, err error) {
+	if hook, ok := p.sealHook.Load().(func(cid.Cid, error)); ok && hook != nil {
+		defer hook(root, err)
+	}
+
+	jerr := p.sealJn.ClearFailure(root)
+	if err != nil {
+		jerr = p.sealJn.SetFailure(root, err)
+	}
+	if jerr != nil {
+		log.Errorf("recording the seal of %s: %s", root, jerr)
+	}
+}
+
 // IsPinned returns whether or not the given key is pinned
 // and an explanation of why its pinned
 func (p *pinner) IsPinned(ctx context.Context, c cid.Cid) (string, bool, error) {
@@ -816,6 +1092,10 @@ func (p *pinner) InternalPins(ctx context.Context) ([]cid.Cid, error) {
 // Update updates a recursive pin from one cid to another.  This is equivalent
 // to pinning the new one and unpinning the old one.
 //
+// Only the blocks of the new DAG which aren't in the old one are fetched and
+// sealed. When the old pin is removed, its replicas are forgotten for the
+// blocks the new DAG doesn't reference and kept for the new pin otherwise.
+//
 // TODO: This will not work when multiple pins are supported
 func (p *pinner) Update(ctx context.Context, from, to cid.Cid, unpin bool) error {
 	p.lock.Lock()
@@ -843,9 +1123,9 @@ func (p *pinner) Update(ctx context.Context, from, to cid.Cid, unpin bool) error
 		return errors.New("'to' cid was already recursively pinned")
 	}
 
-	// Temporarily unlock while we fetch the differences.
+	// Temporarily unlock while we fetch and seal the differences.
 	p.lock.Unlock()
-	err = dagutils.DiffEnumerate(ctx, p.dserv, from, to)
+	removed, err := p.fetchUpdate(ctx, from, to, unpin)
 	p.lock.Lock()
 
 	if err != nil {
@@ -866,7 +1146,141 @@ func (p *pinner) Update(ctx context.Context, from, to cid.Cid, unpin bool) error
 		return err
 	}
 
-	return nil
+	return p.forgetReplaced(from, to, removed)
+}
+
+// fetchUpdate fetches the blocks of to which aren't in from, sealing them
+// under to if the policy allows it. If from is to be unpinned, it returns the
+// blocks of from which aren't in to. Otherwise all of to is sealed, since the
+// blocks shared with from are only sealed under from, which stays pinned.
+func (p *pinner) fetchUpdate(ctx context.Context, from, to cid.Cid, unpin bool) (*cid.Set, error) {
+	node, err := p.dserv.Get(spacex.WithStoragePolicy(ctx, spacex.StorePlain), to)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start seal, if the policy allows it and the backend has room
+	needSeal := false
+	decision := p.policy.CheckRoot(to, dagSize(node), len(node.RawData()))
+	if decision.Sealed {
+		if err = spacex.Reserve(ctx, p.sealer, to, dagSize(node)); err != nil {
+			p.recordSeal(to, err)
+			return nil, err
+		}
+		needSeal, err = p.sealer.StartSeal(to)
+		if err != nil || !needSeal {
+			spacex.Unreserve(p.sealer, to)
+		}
+		if err != nil {
+			p.recordSeal(to, err)
+			return nil, err
+		}
+		if !needSeal {
+			decision.Sealed = false
+			decision.Reason = "sealing is disabled"
+		}
+	}
+
+	var ss *spacex.SealSession
+	var toGetter ipld.NodeGetter = p.dserv
+	if !needSeal {
+		ctx = spacex.WithStoragePolicy(ctx, spacex.StorePlain)
+	} else {
+		session := spacex.Session{Kind: spacex.SessionUpdate, From: from, Unpin: unpin}
+		sctx, err := p.sealJn.GenSessionContext(ctx, p.sealer, to, session)
+		if err != nil {
+			p.sealer.EndSeal(to)
+			p.recordSeal(to, err)
+			return nil, err
+		}
+		ss, _ = spacex.GetSealSession(sctx)
+		ss.Policy = p.policy
+		toGetter = &sessionGetter{NodeGetter: p.dserv, ss: ss}
+	}
+
+	var removed *cid.Set
+	switch {
+	case unpin:
+		_, removed, err = dagutils.DiffBlocks(ctx, p.dserv, toGetter, from, to)
+	case needSeal:
+		err = mdag.FetchGraph(spacex.WithSealSession(ctx, ss), to, p.dserv)
+	default:
+		err = dagutils.DiffEnumerate(ctx, p.dserv, from, to)
+	}
+
+	if needSeal {
+		if closeErr := ss.Close(); err == nil {
+			err = closeErr
+		}
+		if _, endErr := p.sealer.EndSeal(to); err == nil {
+			err = endErr
+		}
+		if finErr := p.sealJn.Finish(to); err == nil {
+			err = finErr
+		}
+		p.recordSeal(to, err)
+		decision.PlainBlocks = ss.PlainBlocks()
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.policy.Record(decision)
+	log.Infof("pin of %s sealed: %t (%s)", to, decision.Sealed, decision.Reason)
+	return removed, nil
+}
+
+// forgetReplaced moves the replicas sealed under from for the blocks of
+// removed, which to doesn't reference, aside and hands the other replicas
+// sealed under from over to to. As with forgetSealed, the replicas of removed
+// are kept for whatever else references their blocks, GC releases them with
+// the stubs otherwise.
+func (p *pinner) forgetReplaced(from, to cid.Cid, removed *cid.Set) error {
+	paths, err := p.sealJn.Replicas(from)
+	if err != nil || len(paths) == 0 {
+		return err
+	}
+	owned := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		owned[path] = true
+	}
+
+	err = removed.ForEach(func(c cid.Cid) error {
+		si, err := blockstore.GetSealedInfo(p.dstore, c)
+		if err == blockstore.ErrNotFound || (err == nil && si == nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, sb := range si.Sbs {
+			if !owned[sb.Path] {
+				continue
+			}
+			if err := p.sealJn.UnpinReplica(from, sb.Path); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return p.sealJn.MoveReplicas(from, to)
+}
+
+// sessionGetter gets nodes under the seal session ss, whatever the context
+// they are asked for with.
+type sessionGetter struct {
+	ipld.NodeGetter
+	ss *spacex.SealSession
+}
+
+func (g *sessionGetter) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	return g.NodeGetter.Get(spacex.WithSealSession(ctx, g.ss), c)
+}
+
+func (g *sessionGetter) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	return g.NodeGetter.GetMany(spacex.WithSealSession(ctx, g.ss), cids)
 }
 
 // Flush encodes and writes pinner keysets to the datastore
@@ -1,6 +1,7 @@
 package dspinner
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -8,20 +9,28 @@ import (
 	"testing"
 	"time"
 
+	bitswap "github.com/ipfs/go-bitswap"
+	testinstance "github.com/ipfs/go-bitswap/testinstance"
+	tn "github.com/ipfs/go-bitswap/testnet"
 	bs "github.com/ipfs/go-blockservice"
 	mdag "github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-merkledag/dagutils"
 
 	cid "github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
 	dssync "github.com/ipfs/go-datastore/sync"
 	lds "github.com/ipfs/go-ds-leveldb"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	delay "github.com/ipfs/go-ipfs-delay"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
 	offline "github.com/ipfs/go-ipfs-exchange-offline"
 	ipfspin "github.com/ipfs/go-ipfs-pinner"
 	"github.com/ipfs/go-ipfs-pinner/ipldpinner"
+	mockrouting "github.com/ipfs/go-ipfs-routing/mock"
 	util "github.com/ipfs/go-ipfs-util"
 	ipld "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 )
 
 var rand = util.NewTimeSeededRand()
@@ -630,6 +639,858 @@ func TestPinUpdate(t *testing.T) {
 	assertPinned(t, p, c1, "c1 should be pinned now")
 }
 
+func TestPinSealed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dstore := spacex.NewSealedDatastore(dssync.MutexWrap(ds.NewMapDatastore()))
+	sealer := spacex.NewLocalBackend()
+	bstore := blockstore.NewSealingBlockstore(dstore, sealer, nil, 0)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p, err := NewWithSealBackend(ctx, dstore, dserv, sealer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := randNode()
+	b, bk := randNode()
+	if err = a.AddNodeLink("child", b); err != nil {
+		t.Fatal(err)
+	}
+	if err = dserv.AddMany(ctx, []ipld.Node{a, b}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	assertPinned(t, p, a.Cid(), "a should be pinned")
+
+	if sealer.Replicas() != 2 {
+		t.Fatalf("expected 2 sealed replicas, got %d", sealer.Replicas())
+	}
+
+	for _, nd := range []ipld.Node{a, b} {
+		raw, err := dstore.Get(blockstore.BlockPrefix.Child(dshelp.CidToDsKey(nd.Cid())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(raw, nd.RawData()) {
+			t.Fatal("expected block to be replaced by a sealed stub")
+		}
+	}
+
+	got, err := bstore.Get(bk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.RawData(), b.RawData()) {
+		t.Fatal("unsealed block differs from the original")
+	}
+
+	roots, err := spacex.NewSealJournal(dstore).Roots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 0 {
+		t.Fatal("expected finished seal to be dropped from the journal")
+	}
+}
+
+func TestPinSealedSharedLeaves(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dstore := spacex.NewSealedDatastore(dssync.MutexWrap(ds.NewMapDatastore()))
+	sealer := spacex.NewLocalBackend()
+	bstore := blockstore.NewSealingBlockstore(dstore, sealer, nil, 0)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p, err := NewWithSealBackend(ctx, dstore, dserv, sealer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a -> {b, c}, b -> d, c -> d
+	a, _ := randNode()
+	b, _ := randNode()
+	c, _ := randNode()
+	d, _ := randNode()
+	for _, l := range []struct{ from, to *mdag.ProtoNode }{{b, d}, {c, d}, {a, b}, {a, c}} {
+		if err = l.from.AddNodeLink(l.to.Cid().String(), l.to); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = dserv.AddMany(ctx, []ipld.Node{a, b, c, d}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	if sealer.Replicas() != 4 {
+		t.Fatalf("expected each of the 4 blocks sealed once, got %d replicas", sealer.Replicas())
+	}
+	paths, err := spacex.NewSealJournal(dstore).Replicas(a.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 4 {
+		t.Fatalf("expected 4 replicas recorded under a, got %d", len(paths))
+	}
+}
+
+func TestPinSealPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dstore := spacex.NewSealedDatastore(dssync.MutexWrap(ds.NewMapDatastore()))
+	sealer := spacex.NewLocalBackend()
+	bstore := blockstore.NewSealingBlockstore(dstore, sealer, nil, 0)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	denied, _ := randNode()
+	policy := spacex.NewSealPolicy(spacex.SealRules{
+		Deny:   map[string]bool{string(denied.Cid().Hash()): true},
+		Codecs: map[uint64]bool{cid.DagProtobuf: true},
+	})
+	p, err := NewWithSealBackend(ctx, dstore, dserv, sealer, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A denied root is pinned without being sealed.
+	if err = dserv.Add(ctx, denied); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Pin(ctx, denied, true); err != nil {
+		t.Fatal(err)
+	}
+	if sealer.Replicas() != 0 {
+		t.Fatalf("denied root was sealed to %d replicas", sealer.Replicas())
+	}
+	d, ok := policy.Decision(denied.Cid())
+	if !ok || d.Sealed || d.Reason != "root is in the deny list" {
+		t.Fatalf("unexpected decision %+v", d)
+	}
+
+	// Blocks of a codec which isn't allowed are kept in plain.
+	a, _ := randNode()
+	raw := mdag.NewRawNode([]byte("raw child"))
+	if err = a.AddNodeLink("child", raw); err != nil {
+		t.Fatal(err)
+	}
+	if err = dserv.AddMany(ctx, []ipld.Node{a, raw}); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	if sealer.Replicas() != 1 {
+		t.Fatalf("expected 1 sealed replica, got %d", sealer.Replicas())
+	}
+	value, err := dstore.Get(blockstore.BlockPrefix.Child(dshelp.CidToDsKey(raw.Cid())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(spacex.UnescapeRaw(value), raw.RawData()) {
+		t.Fatal("expected the raw block to be kept in plain")
+	}
+	d, ok = policy.Decision(a.Cid())
+	if !ok || !d.Sealed || d.PlainBlocks["codec raw isn't allowed"] != 1 {
+		t.Fatalf("unexpected decision %+v", d)
+	}
+}
+
+func TestPinFetchCache(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(0))
+	ig := testinstance.NewTestInstanceGenerator(net, nil, []bitswap.Option{bitswap.WithFetchCache(1 << 20)})
+	defer ig.Close()
+	peers := ig.Instances(2)
+	remote, local := peers[0], peers[1]
+
+	a, _ := randNode()
+	b, bk := randNode()
+	c, ck := randNode()
+	if err := a.AddNodeLink("child", b); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddNodeLink("update", c); err != nil {
+		t.Fatal(err)
+	}
+	rserv := mdag.NewDAGService(bs.New(remote.Blockstore(), remote.Exchange))
+	if err := rserv.AddMany(ctx, []ipld.Node{b, c}); err != nil {
+		t.Fatal(err)
+	}
+	for _, nd := range []ipld.Node{b, c} {
+		if err := remote.Exchange.HasBlock(nd.(*mdag.ProtoNode)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Blocks fetched while pinning are stored even though fetches are
+	// otherwise only cached, and so are those fetched by an update.
+	dserv := mdag.NewDAGService(bs.New(local.Blockstore(), local.Exchange))
+	p, err := New(ctx, dssync.MutexWrap(ds.NewMapDatastore()), dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Pin(ctx, b, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Update(ctx, bk, ck, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []cid.Cid{bk, ck} {
+		if has, _ := local.Blockstore().Has(k); !has {
+			t.Fatalf("pinned block %s wasn't stored", k)
+		}
+	}
+}
+
+func TestSealRoot(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dstore := spacex.NewSealedDatastore(dssync.MutexWrap(ds.NewMapDatastore()))
+	sealer := spacex.NewLocalBackend()
+	bstore := blockstore.NewSealingBlockstore(dstore, sealer, nil, 0)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	a, _ := randNode()
+	b, _ := randNode()
+	c, _ := randNode()
+	if err := b.AddNodeLink("child", c); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddNodeLink("child", b); err != nil {
+		t.Fatal(err)
+	}
+	if err := dserv.AddMany(ctx, []ipld.Node{a, b, c}); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := spacex.NewSealPolicy(spacex.SealRules{
+		Deny: map[string]bool{string(a.Cid().Hash()): true},
+	})
+	p, err := NewWithSealBackend(ctx, dstore, dserv, sealer, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := p.(RootSealer)
+
+	if err = rs.SealRoot(ctx, a.Cid(), nil); err == nil {
+		t.Fatal("expected sealing a root which isn't pinned to fail")
+	}
+
+	// The policy keeps a in plain when pinned, sealing it is asked for once
+	// it is pinned already. b and c are given, only a is read to reach them.
+	if err = p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	if sealer.Replicas() != 0 {
+		t.Fatalf("denied root was sealed to %d replicas", sealer.Replicas())
+	}
+	if err = rs.SealRoot(ctx, a.Cid(), []cid.Cid{b.Cid(), c.Cid()}); err != nil {
+		t.Fatal(err)
+	}
+	if sealer.Replicas() != 3 {
+		t.Fatalf("expected 3 sealed replicas, got %d", sealer.Replicas())
+	}
+	for _, nd := range []ipld.Node{a, b, c} {
+		raw, err := dstore.Get(blockstore.BlockPrefix.Child(dshelp.CidToDsKey(nd.Cid())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, _ := spacex.TryGetSealedInfo(raw); !ok {
+			t.Fatalf("expected a sealed stub for %s", nd.Cid())
+		}
+	}
+	d, ok := policy.Decision(a.Cid())
+	if !ok || !d.Sealed || d.Reason != "sealing was asked for" {
+		t.Fatalf("unexpected decision %+v", d)
+	}
+
+	// Sealing again leaves the blocks sealed under a as they are.
+	if err = rs.SealRoot(ctx, a.Cid(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if sealer.Replicas() != 3 {
+		t.Fatalf("expected 3 sealed replicas, got %d", sealer.Replicas())
+	}
+}
+
+func TestPinSealedResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dstore := spacex.NewSealedDatastore(dssync.MutexWrap(ds.NewMapDatastore()))
+	sealer := spacex.NewLocalBackend()
+	bstore := blockstore.NewSealingBlockstore(dstore, sealer, nil, 0)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p, err := NewWithSealBackend(ctx, dstore, dserv, sealer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := randNode()
+	b, bk := randNode()
+	if err = a.AddNodeLink("child", b); err != nil {
+		t.Fatal(err)
+	}
+	if err = dserv.AddMany(ctx, []ipld.Node{a, b}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Seal part of the DAG and "crash" before the session is ended.
+	journal := spacex.NewSealJournal(dstore)
+	if _, err = sealer.StartSeal(a.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	sctx, err := journal.GenSealContext(ctx, sealer, a.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = dserv.Get(sctx, bk); err != nil {
+		t.Fatal(err)
+	}
+
+	roots, err := journal.Roots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 || !roots[0].Equals(a.Cid()) {
+		t.Fatalf("expected interrupted seal of %s in the journal, got %v", a.Cid(), roots)
+	}
+
+	// Resuming picks up the store flags, b must not get a second stub.
+	if err = p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := dstore.Get(blockstore.BlockPrefix.Child(dshelp.CidToDsKey(bk)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, si := spacex.TryGetSealedInfo(raw)
+	if !ok || len(si.Sbs) != 1 {
+		t.Fatalf("expected exactly one sealed replica of b, got %s", raw)
+	}
+
+	roots, err = journal.Roots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 0 {
+		t.Fatal("expected resumed seal to be dropped from the journal")
+	}
+}
+
+func TestResumeSeal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dstore := spacex.NewSealedDatastore(dssync.MutexWrap(ds.NewMapDatastore()))
+	sealer := spacex.NewLocalBackend()
+	bstore := blockstore.NewSealingBlockstore(dstore, sealer, nil, 0)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+	journal := spacex.NewSealJournal(dstore)
+
+	p, err := NewWithSealBackend(ctx, dstore, dserv, sealer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a -> b is added with --seal: pinned first, then sealed on demand.
+	a, _ := randNode()
+	b, bk := randNode()
+	if err = a.AddNodeLink("child", b); err != nil {
+		t.Fatal(err)
+	}
+	// a1 -> c is updated to a2 -> {c, d}.
+	a1, _ := randNode()
+	a2, _ := randNode()
+	c, _ := randNode()
+	d, dk := randNode()
+	if err = a1.AddNodeLink("keep", c); err != nil {
+		t.Fatal(err)
+	}
+	if err = a2.AddNodeLink("keep", c); err != nil {
+		t.Fatal(err)
+	}
+	if err = a2.AddNodeLink("new", d); err != nil {
+		t.Fatal(err)
+	}
+	if err = dserv.AddMany(ctx, []ipld.Node{a, b, a1, a2, c, d}); err != nil {
+		t.Fatal(err)
+	}
+	p.PinWithMode(a.Cid(), ipfspin.Recursive)
+	if err = p.Pin(ctx, a1, true); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Seal b and d and "crash" before the sessions are ended.
+	interrupt := func(root, k cid.Cid, session spacex.Session) {
+		if _, err := sealer.StartSeal(root); err != nil {
+			t.Fatal(err)
+		}
+		sctx, err := journal.GenSessionContext(ctx, sealer, root, session)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = dserv.Get(sctx, k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	interrupt(a.Cid(), bk, spacex.Session{Kind: spacex.SessionSealRoot})
+	interrupt(a2.Cid(), dk, spacex.Session{Kind: spacex.SessionUpdate, From: a1.Cid(), Unpin: true})
+
+	// On restart the sWorker sessions are ended and the journaled ones
+	// resumed the way they were opened.
+	for _, root := range []cid.Cid{a.Cid(), a2.Cid()} {
+		if _, err = sealer.EndSeal(root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err = NewWithSealBackend(ctx, dstore, dserv, sealer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr := p.(SealResumer)
+	for _, root := range []cid.Cid{a.Cid(), a2.Cid()} {
+		if err = sr.ResumeSeal(ctx, root); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertPinned(t, p, a.Cid(), "a should still be pinned")
+	assertPinned(t, p, a2.Cid(), "a2 should be pinned by the update")
+	assertUnpinned(t, p, a1.Cid(), "a1 should be unpinned by the update")
+	for _, root := range []cid.Cid{a.Cid(), a2.Cid()} {
+		if sealed, err := journal.Sealed(root); err != nil || !sealed {
+			t.Fatalf("expected %s to be sealed, got %t, %v", root, sealed, err)
+		}
+	}
+	for _, k := range []cid.Cid{bk, dk} {
+		raw, err := dstore.Get(blockstore.BlockPrefix.Child(dshelp.CidToDsKey(k)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, si := spacex.TryGetSealedInfo(raw)
+		if !ok || len(si.Sbs) != 1 {
+			t.Fatalf("expected exactly one sealed replica of %s, got %s", k, raw)
+		}
+	}
+	roots, err := journal.Roots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 0 {
+		t.Fatalf("expected resumed seals to be dropped from the journal, got %v", roots)
+	}
+}
+
+func TestUnpinSealedKeepsShared(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dstore := spacex.NewSealedDatastore(dssync.MutexWrap(ds.NewMapDatastore()))
+	local := spacex.NewLocalBackend()
+	sealer := spacex.NewReleaseQueue(local, dstore)
+	bstore := blockstore.NewSealingBlockstore(dstore, sealer, nil, 0)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p, err := NewWithSealBackend(ctx, dstore, dserv, sealer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := randNode()
+	b, bk := randNode()
+	if err = a.AddNodeLink("child", b); err != nil {
+		t.Fatal(err)
+	}
+	if err = dserv.AddMany(ctx, []ipld.Node{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	// b is pinned on its own too, its stub is shared with a.
+	if err = p.Pin(ctx, b, false); err != nil {
+		t.Fatal(err)
+	}
+	if local.Replicas() != 2 {
+		t.Fatalf("expected 2 sealed replicas, got %d", local.Replicas())
+	}
+
+	if err = p.Unpin(ctx, a.Cid(), true); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := sealer.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("expected unpin to release nothing, got %d queued releases", len(pending))
+	}
+	journal := spacex.NewSealJournal(dstore)
+	paths, err := journal.Replicas(a.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 0 {
+		t.Fatalf("expected the replicas of a to be forgotten, got %d", len(paths))
+	}
+	// They are kept aside for 'ipfs spacex release'.
+	if paths, err = journal.Unpinned(a.Cid()); err != nil || len(paths) != 2 {
+		t.Fatalf("expected the 2 replicas of a to be moved aside, got %d, %v", len(paths), err)
+	}
+	got, err := bstore.Get(bk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.RawData(), b.RawData()) {
+		t.Fatal("unsealed block differs from the original")
+	}
+
+	// Deleting the stubs, as GC does, releases what they point to.
+	if err = bstore.DeleteBlock(a.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err = bstore.DeleteBlock(bk); err != nil {
+		t.Fatal(err)
+	}
+	pending, err = sealer.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("expected 2 queued releases, got %d", len(pending))
+	}
+	if _, err = sealer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if local.Replicas() != 0 {
+		t.Fatalf("expected all replicas released, %d left", local.Replicas())
+	}
+}
+
+func TestPinUpdateSealed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dstore := spacex.NewSealedDatastore(dssync.MutexWrap(ds.NewMapDatastore()))
+	sealer := spacex.NewLocalBackend()
+	bstore := blockstore.NewSealingBlockstore(dstore, sealer, nil, 0)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p, err := NewWithSealBackend(ctx, dstore, dserv, sealer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a1 -> {b, c} is updated to a2 -> {b, d}.
+	a1, _ := randNode()
+	a2, _ := randNode()
+	b, bk := randNode()
+	c, _ := randNode()
+	d, dk := randNode()
+	if err = a1.AddNodeLink("keep", b); err != nil {
+		t.Fatal(err)
+	}
+	if err = a1.AddNodeLink("old", c); err != nil {
+		t.Fatal(err)
+	}
+	if err = a2.AddNodeLink("keep", b); err != nil {
+		t.Fatal(err)
+	}
+	if err = a2.AddNodeLink("new", d); err != nil {
+		t.Fatal(err)
+	}
+	if err = dserv.AddMany(ctx, []ipld.Node{a1, b, c}); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Pin(ctx, a1, true); err != nil {
+		t.Fatal(err)
+	}
+	if sealer.Replicas() != 3 {
+		t.Fatalf("expected 3 sealed replicas, got %d", sealer.Replicas())
+	}
+
+	if err = dserv.AddMany(ctx, []ipld.Node{a2, d}); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Update(ctx, a1.Cid(), a2.Cid(), true); err != nil {
+		t.Fatal(err)
+	}
+	assertPinned(t, p, a2.Cid(), "a2 should be pinned now")
+	assertUnpinned(t, p, a1.Cid(), "a1 should no longer be pinned")
+
+	// a2 and d are sealed, the replicas of a1 and c are kept until GC
+	// deletes their stubs.
+	if sealer.Replicas() != 5 {
+		t.Fatalf("expected 5 sealed replicas, got %d", sealer.Replicas())
+	}
+	for _, k := range []cid.Cid{a1.Cid(), c.Cid()} {
+		if err = bstore.DeleteBlock(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sealer.Replicas() != 3 {
+		t.Fatalf("expected 3 sealed replicas, got %d", sealer.Replicas())
+	}
+	raw, err := dstore.Get(blockstore.BlockPrefix.Child(dshelp.CidToDsKey(dk)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := spacex.TryGetSealedInfo(raw); !ok {
+		t.Fatal("expected d to be sealed")
+	}
+	raw, err = dstore.Get(blockstore.BlockPrefix.Child(dshelp.CidToDsKey(bk)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, si := spacex.TryGetSealedInfo(raw); !ok || len(si.Sbs) != 1 {
+		t.Fatalf("expected b to keep its only replica, got %s", raw)
+	}
+	got, err := bstore.Get(bk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.RawData(), b.RawData()) {
+		t.Fatal("unsealed block differs from the original")
+	}
+
+	// The replica of b now goes with a2.
+	journal := spacex.NewSealJournal(dstore)
+	paths, err := journal.Replicas(a1.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 0 {
+		t.Fatalf("expected no replicas left under a1, got %d", len(paths))
+	}
+	paths, err = journal.Replicas(a2.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 3 {
+		t.Fatalf("expected 3 replicas under a2, got %d", len(paths))
+	}
+
+	// Unpinning forgets the replicas of a2, GC releases them with the stubs.
+	if err = p.Unpin(ctx, a2.Cid(), true); err != nil {
+		t.Fatal(err)
+	}
+	paths, err = journal.Replicas(a2.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 0 {
+		t.Fatalf("expected no replicas left under a2, got %d", len(paths))
+	}
+	if sealer.Replicas() != 3 {
+		t.Fatalf("expected 3 sealed replicas until GC, got %d", sealer.Replicas())
+	}
+}
+
+// failingSealer fails to seal while fail is set, and to unseal while down
+// is. It counts the unseal calls.
+type failingSealer struct {
+	*spacex.LocalBackend
+	fail    bool
+	down    bool
+	unseals int
+}
+
+func (fs *failingSealer) Unseal(path string) ([]byte, error, int) {
+	fs.unseals++
+	if fs.down {
+		return nil, errors.New("sWorker unreachable"), 0
+	}
+	return fs.LocalBackend.Unseal(path)
+}
+
+func (fs *failingSealer) Seal(root cid.Cid, newBlock bool, value []byte) (bool, string, error) {
+	if fs.fail {
+		return false, "", errors.New("sWorker is out of space")
+	}
+	return fs.LocalBackend.Seal(root, newBlock, value)
+}
+
+func TestPinSealStatus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dstore := spacex.NewSealedDatastore(dssync.MutexWrap(ds.NewMapDatastore()))
+	sealer := &failingSealer{LocalBackend: spacex.NewLocalBackend(), fail: true}
+	bstore := blockstore.NewSealingBlockstore(dstore, sealer, nil, 0)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p, err := NewWithSealBackend(ctx, dstore, dserv, sealer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := randNode()
+	b, bk := randNode()
+	r := mdag.NewRawNode([]byte("raw leaf"))
+	if err = a.AddNodeLink("child", b); err != nil {
+		t.Fatal(err)
+	}
+	if err = a.AddNodeLink("raw", r); err != nil {
+		t.Fatal(err)
+	}
+	if err = dserv.AddMany(ctx, []ipld.Node{a, b, r}); err != nil {
+		t.Fatal(err)
+	}
+
+	status := func() *spacex.SealStatus {
+		t.Helper()
+		st, err := dagutils.SealStatus(ctx, dstore, dserv, nil, a.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st
+	}
+	var ended []error
+	p.(SealNotifier).OnSeal(func(root cid.Cid, err error) {
+		if root.Equals(a.Cid()) {
+			ended = append(ended, err)
+		}
+	})
+	journal := spacex.NewSealJournal(dstore)
+
+	if err = p.Pin(ctx, a, true); err == nil {
+		t.Fatal("expected the pin to fail")
+	}
+	if st := status(); st.State != spacex.SealStateFailed || st.LastError == "" || st.SealedBlocks != 0 {
+		t.Fatalf("expected a failed seal, got %+v", st)
+	}
+	if sealed, _ := journal.Sealed(a.Cid()); len(ended) != 1 || ended[0] == nil || sealed {
+		t.Fatalf("expected the hook to tell the seal failed, got %v", ended)
+	}
+
+	sealer.fail = false
+	if err = p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	if sealed, _ := journal.Sealed(a.Cid()); len(ended) != 2 || ended[1] != nil || !sealed {
+		t.Fatalf("expected the hook to tell the seal completed, got %v", ended)
+	}
+	st := status()
+	if st.State != spacex.SealStateSealed || st.LastError != "" {
+		t.Fatalf("expected the pin to be sealed, got %+v", st)
+	}
+	if st.Blocks != 3 || st.SealedBlocks != 3 || st.Replicas != 3 || st.MinReplicas != 1 {
+		t.Fatalf("unexpected counts: %+v", st)
+	}
+	size := len(a.RawData()) + len(b.RawData()) + len(r.RawData())
+	if st.SealedBytes != uint64(size) {
+		t.Fatalf("expected %d sealed bytes, got %d", size, st.SealedBytes)
+	}
+
+	// The raw leaf is told from its stub, only a and b are unsealed.
+	sealer.unseals = 0
+	if st = status(); st.State != spacex.SealStateSealed || sealer.unseals != 2 {
+		t.Fatalf("expected 2 blocks to be unsealed, got %d", sealer.unseals)
+	}
+
+	// While the sWorker is down, the blocks with links can't be walked.
+	sealer.down = true
+	st = status()
+	if st.Unreadable != 1 || st.Missing != 0 || st.Blocks != 1 || st.SealedBlocks != 1 {
+		t.Fatalf("expected the root to be unreadable, got %+v", st)
+	}
+	if st.State != spacex.SealStatePartial {
+		t.Fatalf("expected a pin which can't be walked to be partial, got %s", st.State)
+	}
+	sealer.down = false
+	if failure, _ := spacex.NewSealJournal(dstore).Failure(a.Cid()); failure != "" {
+		t.Fatalf("expected the failure to be cleared, got %q", failure)
+	}
+
+	// The sWorker loses the replica of b.
+	si, err := blockstore.GetSealedInfo(dstore, bk)
+	if err != nil || si == nil {
+		t.Fatal("expected b to be sealed")
+	}
+	if err = sealer.Release(si.Sbs[0].Path); err != nil {
+		t.Fatal(err)
+	}
+	if st = status(); st.State != spacex.SealStatePartial || st.Missing != 1 || st.SealedBlocks != 2 {
+		t.Fatalf("expected a partial seal missing 1 block, got %+v", st)
+	}
+}
+
+func TestPinSealCapacity(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dstore := spacex.NewSealedDatastore(dssync.MutexWrap(ds.NewMapDatastore()))
+	sealer := spacex.NewLocalBackend()
+	bstore := blockstore.NewSealingBlockstore(dstore, sealer, nil, 0)
+	bserv := bs.New(bstore, offline.Exchange(bstore))
+	dserv := mdag.NewDAGService(bserv)
+
+	p, err := NewWithSealBackend(ctx, dstore, dserv, sealer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := randNode()
+	b, _ := randNode()
+	if err = a.AddNodeLink("child", b); err != nil {
+		t.Fatal(err)
+	}
+	if err = dserv.AddMany(ctx, []ipld.Node{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	size, err := a.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The DAG doesn't fit, the pin is rejected before anything is sealed.
+	sealer.SetCapacity(size - 1)
+	if err = p.Pin(ctx, a, true); !errors.Is(err, spacex.ErrNoCapacity) {
+		t.Fatalf("expected ErrNoCapacity, got %v", err)
+	}
+	assertUnpinned(t, p, a.Cid(), "a should not be pinned")
+	if sealer.Replicas() != 0 || sealer.Reserved() != 0 {
+		t.Fatalf("expected nothing sealed nor reserved, got %d replicas, %d bytes reserved", sealer.Replicas(), sealer.Reserved())
+	}
+	if failure, _ := spacex.NewSealJournal(dstore).Failure(a.Cid()); failure == "" {
+		t.Fatal("expected the rejection to be recorded")
+	}
+
+	sealer.SetCapacity(size)
+	if err = p.Pin(ctx, a, true); err != nil {
+		t.Fatal(err)
+	}
+	if sealer.Replicas() != 2 || sealer.Reserved() != 0 {
+		t.Fatalf("expected 2 replicas and the reservation given back, got %d replicas, %d bytes reserved", sealer.Replicas(), sealer.Reserved())
+	}
+}
+
 func TestLoadDirty(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
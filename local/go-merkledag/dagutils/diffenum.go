@@ -55,6 +55,126 @@ func DiffEnumerate(ctx context.Context, dserv ipld.NodeGetter, from, to cid.Cid)
 	return nil
 }
 
+// DiffBlocks fetches every object in the graph pointed to by 'to' that is
+// not in 'from', like DiffEnumerate, and returns them as added along with
+// the objects of 'from' which aren't in 'to' as removed. Objects of 'from'
+// are read with fromGetter and objects of 'to' with toGetter; subtrees linked
+// from both sides of a changed link are not read with toGetter. Added may
+// hold objects which were in 'from' after all, removed never holds an object
+// of 'to': the subtrees common to both graphs are walked with fromGetter to
+// make sure.
+func DiffBlocks(ctx context.Context, fromGetter, toGetter ipld.NodeGetter, from, to cid.Cid) (added, removed *cid.Set, err error) {
+	added = cid.NewSet()
+	removed = cid.NewSet()
+
+	// Roots of the subtrees only in 'from', only in 'to' and in both.
+	var fromOnly, toOnly, kept []cid.Cid
+
+	var diff func(f, t cid.Cid) error
+	diff = func(f, t cid.Cid) error {
+		if removed.Has(f) && added.Has(t) {
+			return nil
+		}
+		fnd, err := fromGetter.Get(ctx, f)
+		if err != nil {
+			return fmt.Errorf("get %s: %s", f, err)
+		}
+		tnd, err := toGetter.Get(ctx, t)
+		if err != nil {
+			return fmt.Errorf("get %s: %s", t, err)
+		}
+		removed.Add(f)
+		added.Add(t)
+
+		inFrom := make(map[string]bool)
+		for _, l := range fnd.Links() {
+			inFrom[l.Cid.KeyString()] = true
+		}
+		inTo := make(map[string]bool)
+		for _, l := range tnd.Links() {
+			key := l.Cid.KeyString()
+			inTo[key] = true
+			if inFrom[key] {
+				kept = append(kept, l.Cid)
+			}
+		}
+
+		paired := cid.NewSet()
+		for _, c := range getLinkDiff(fnd, tnd) {
+			if !c.bef.Defined() {
+				toOnly = append(toOnly, c.aft)
+				continue
+			}
+			paired.Add(c.bef)
+			if err := diff(c.bef, c.aft); err != nil {
+				return err
+			}
+		}
+		for _, l := range fnd.Links() {
+			if !inTo[l.Cid.KeyString()] && !paired.Has(l.Cid) {
+				fromOnly = append(fromOnly, l.Cid)
+			}
+		}
+		return nil
+	}
+	if err := diff(from, to); err != nil {
+		return nil, nil, err
+	}
+
+	fromLinks := getLinksSkipRaw(fromGetter)
+	for _, c := range fromOnly {
+		if err := mdag.Walk(ctx, fromLinks, c, removed.Visit, mdag.Concurrent()); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Subtrees moved from 'from' are not fetched again.
+	visitTo := func(c cid.Cid) bool {
+		if removed.Has(c) {
+			kept = append(kept, c)
+			return false
+		}
+		return added.Visit(c)
+	}
+	for _, c := range toOnly {
+		if err := mdag.Walk(ctx, mdag.GetLinksDirect(toGetter), c, visitTo, mdag.Concurrent()); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	added.ForEach(func(c cid.Cid) error {
+		removed.Remove(c)
+		return nil
+	})
+	if removed.Len() == 0 {
+		return added, removed, nil
+	}
+
+	seen := cid.NewSet()
+	visitKept := func(c cid.Cid) bool {
+		removed.Remove(c)
+		return seen.Visit(c)
+	}
+	for _, c := range kept {
+		if err := mdag.Walk(ctx, fromLinks, c, visitKept, mdag.Concurrent()); err != nil {
+			return nil, nil, err
+		}
+	}
+	return added, removed, nil
+}
+
+// getLinksSkipRaw is GetLinksDirect, without reading raw blocks which can't
+// have links.
+func getLinksSkipRaw(serv ipld.NodeGetter) mdag.GetLinks {
+	getLinks := mdag.GetLinksDirect(serv)
+	return func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+		if c.Type() == cid.Raw {
+			return nil, nil
+		}
+		return getLinks(ctx, c)
+	}
+}
+
 // if both bef and aft are not nil, then that signifies bef was replaces with aft.
 // if bef is nil and aft is not, that means aft was newly added
 // if aft is nil and bef is not, that means bef was deleted
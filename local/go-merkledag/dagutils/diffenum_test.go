@@ -247,3 +247,63 @@ func TestDiffEnumRecurse(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+var tg6 = map[string]ndesc{
+	"a1": ndesc{
+		"keep": "b",
+		"old":  "c",
+	},
+	"a2": ndesc{
+		"keep": "b",
+		"old":  "c2",
+	},
+	"b": ndesc{"dup": "d"},
+	"c": ndesc{
+		"leaf": "x",
+		"gone": "e",
+	},
+	"c2": ndesc{"leaf": "x"},
+	"e":  ndesc{"dup": "d"},
+	"d":  ndesc{},
+	"x":  ndesc{},
+}
+
+func TestDiffBlocks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nds := mkGraph(tg6)
+
+	ds := mdtest.Mock()
+	lgds := &getLogger{ds: ds}
+
+	for _, nd := range nds {
+		err := ds.Add(ctx, nd)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	added, removed, err := DiffBlocks(ctx, ds, lgds, nds["a1"].Cid(), nds["a2"].Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the new objects are read from 'to'.
+	err = assertCidList(lgds.log, []cid.Cid{nds["a2"].Cid(), nds["c2"].Cid()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.Len() != 2 || !added.Has(nds["a2"].Cid()) || !added.Has(nds["c2"].Cid()) {
+		t.Fatalf("unexpected added objects %v", added.Keys())
+	}
+
+	// d is still linked from b, x from c2.
+	if removed.Len() != 3 {
+		t.Fatalf("expected 3 removed objects, got %v", removed.Keys())
+	}
+	for _, s := range []string{"a1", "c", "e"} {
+		if !removed.Has(nds[s].Cid()) {
+			t.Fatalf("expected %s to be removed", s)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package dagutils
+
+import (
+	"context"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	ipld "github.com/ipfs/go-ipld-format"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
+)
+
+// SealStatus walks the DAG of root and tells how it is stored by the
+// blockstore kept in dstore. Sealed blocks are counted from their stubs, only
+// those which may have links are read back through getter, unsealing them,
+// so that their children are walked. getter should not fetch missing blocks
+// from the network. Blocks kept in plain are missing if policy, when set,
+// would seal them.
+func SealStatus(ctx context.Context, dstore ds.Datastore, getter ipld.NodeGetter, policy *spacex.SealPolicy, root cid.Cid) (*spacex.SealStatus, error) {
+	journal := spacex.NewSealJournal(dstore)
+	st := &spacex.SealStatus{}
+	countSealed := func(si *spacex.SealedInfo) {
+		st.SealedBlocks++
+		st.SealedBytes += uint64(si.Sbs[0].Size)
+		st.Replicas += len(si.Sbs)
+		if st.MinReplicas == 0 || len(si.Sbs) < st.MinReplicas {
+			st.MinReplicas = len(si.Sbs)
+		}
+	}
+
+	// Plain blocks only count as missing in a DAG which is sealed at all.
+	sealable := 0
+	seen := cid.NewSet()
+	todo := []cid.Cid{root}
+	for len(todo) > 0 {
+		c := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if !seen.Visit(c) {
+			continue
+		}
+		st.Blocks++
+
+		si, err := blockstore.GetSealedInfo(dstore, c)
+		if err == blockstore.ErrNotFound {
+			st.Missing++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if si != nil {
+			if len(si.Sbs) == 0 {
+				// All its replicas were pruned.
+				st.Missing++
+				continue
+			}
+			if c.Type() == cid.Raw {
+				// Raw leaves have no links, there is no need to
+				// unseal them.
+				countSealed(si)
+				continue
+			}
+		}
+
+		nd, err := getter.Get(ctx, c)
+		if si != nil {
+			// Reading may have pruned dead replicas from the stub.
+			if si, err := blockstore.GetSealedInfo(dstore, c); err == nil && si != nil && len(si.Sbs) > 0 {
+				countSealed(si)
+			} else {
+				st.Missing++
+				continue
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if si != nil {
+				// The replicas are there but couldn't be read, e.g.
+				// since the sWorker is down.
+				st.Unreadable++
+			} else {
+				st.Missing++
+			}
+			continue
+		}
+		for _, l := range nd.Links() {
+			todo = append(todo, l.Cid)
+		}
+
+		if si == nil {
+			st.PlainBlocks++
+			if policy != nil && policy.CheckBlock(c, len(nd.RawData()), st.SealedBytes) == "" {
+				sealable++
+			}
+		}
+	}
+	if st.SealedBlocks > 0 {
+		st.Missing += sealable
+	}
+
+	sealing, err := journal.Roots()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range sealing {
+		if c.Equals(root) {
+			st.State = spacex.SealStateSealing
+			return st, nil
+		}
+	}
+	failure, err := journal.Failure(root)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case failure != "" && (st.Missing > 0 || st.SealedBlocks == 0):
+		st.State = spacex.SealStateFailed
+		st.LastError = failure
+	case st.SealedBlocks == 0:
+		st.State = spacex.SealStatePlain
+	case st.Missing > 0 || st.Unreadable > 0:
+		// The blocks below unreadable ones may be missing as well.
+		st.State = spacex.SealStatePartial
+	default:
+		st.State = spacex.SealStateSealed
+	}
+	return st, nil
+}
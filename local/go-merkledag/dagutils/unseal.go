@@ -0,0 +1,248 @@
+package dagutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	ipld "github.com/ipfs/go-ipld-format"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
+)
+
+// UnsealPrefix namespaces the roots being unsealed in the repo datastore.
+var UnsealPrefix = ds.NewKey("/spacex/unseal")
+
+// UnsealResult is what unsealing one block of a DAG did.
+type UnsealResult struct {
+	Cid cid.Cid
+
+	// Unsealed is set when the block was sealed and is now stored in
+	// plain, Size is then its size in bytes.
+	Unsealed bool
+	Size     int
+
+	// Released is the number of replicas of the block released.
+	Released int
+
+	// Err is set when the block couldn't be read or stored in plain. Its
+	// links aren't followed then.
+	Err error
+}
+
+// Unsealer moves sealed DAGs back to the local blockstore: every sealed block
+// is read back, verified and stored in plain in place of its stub, then its
+// replicas are released. Roots are recorded under UnsealPrefix until done,
+// so that an interrupted unseal can be resumed; blocks already in plain are
+// skipped.
+type Unsealer struct {
+	dstore  ds.Datastore
+	getter  ipld.NodeGetter
+	backend spacex.SealBackend
+	journal *spacex.SealJournal
+	locker  blockstore.GCLocker
+}
+
+// NewUnsealer returns an unsealer of the DAGs stored by a blockstore kept in
+// dstore and read through getter. Blocks are stored in plain under the pin
+// lock of locker.
+func NewUnsealer(dstore ds.Datastore, getter ipld.NodeGetter, backend spacex.SealBackend, locker blockstore.GCLocker) *Unsealer {
+	return &Unsealer{
+		dstore:  dstore,
+		getter:  getter,
+		backend: backend,
+		journal: spacex.NewSealJournal(dstore),
+		locker:  locker,
+	}
+}
+
+func unsealKey(root cid.Cid) ds.Key {
+	return UnsealPrefix.ChildString(root.String())
+}
+
+// Pending returns the roots whose unseal was interrupted.
+func (u *Unsealer) Pending() ([]cid.Cid, error) {
+	res, err := u.dstore.Query(dsq.Query{Prefix: UnsealPrefix.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var roots []cid.Cid
+	for e := range res.Next() {
+		if e.Error != nil {
+			return nil, e.Error
+		}
+		c, err := cid.Decode(ds.RawKey(e.Key).Name())
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, c)
+	}
+	return roots, nil
+}
+
+// Unseal walks the DAG of root, unsealing at most rate sealed blocks per
+// second if rate is positive, and returns what it did for each block. Once
+// the whole DAG is in plain, the replicas still recorded under root are
+// released too.
+func (u *Unsealer) Unseal(ctx context.Context, root cid.Cid, rate int) (<-chan UnsealResult, error) {
+	sealing, err := u.journal.Roots()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range sealing {
+		if c.Equals(root) {
+			return nil, errors.New("root is being sealed")
+		}
+	}
+	owners, err := u.journal.ReplicaRoots()
+	if err != nil {
+		return nil, err
+	}
+	if err := u.dstore.Put(unsealKey(root), []byte{}); err != nil {
+		return nil, err
+	}
+
+	out := make(chan UnsealResult)
+	go func() {
+		defer close(out)
+
+		var tick <-chan time.Time
+		if rate > 0 {
+			ticker := time.NewTicker(time.Second / time.Duration(rate))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		failed := false
+		seen := cid.NewSet()
+		todo := []cid.Cid{root}
+		for len(todo) > 0 {
+			c := todo[len(todo)-1]
+			todo = todo[:len(todo)-1]
+			if !seen.Visit(c) {
+				continue
+			}
+
+			res, links := u.unsealBlock(ctx, c, owners, tick)
+			if res.Err != nil {
+				failed = true
+			}
+			todo = append(todo, links...)
+
+			if !res.Unsealed && res.Err == nil {
+				continue
+			}
+			select {
+			case out <- res:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if failed || ctx.Err() != nil {
+			return
+		}
+
+		if err := u.finish(ctx, root); err != nil {
+			select {
+			case out <- UnsealResult{Cid: root, Err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out, nil
+}
+
+// unsealBlock stores c in plain if it is sealed and returns its links. tick,
+// if set, paces the blocks read back from the seal backend.
+func (u *Unsealer) unsealBlock(ctx context.Context, c cid.Cid, owners map[string]cid.Cid, tick <-chan time.Time) (UnsealResult, []cid.Cid) {
+	res := UnsealResult{Cid: c}
+
+	si, err := blockstore.GetSealedInfo(u.dstore, c)
+	if err != nil && err != blockstore.ErrNotFound {
+		res.Err = err
+		return res, nil
+	}
+	if si != nil && tick != nil {
+		select {
+		case <-tick:
+		case <-ctx.Done():
+			res.Err = ctx.Err()
+			return res, nil
+		}
+	}
+
+	unlocker := u.locker.PinLock()
+	defer unlocker.Unlock()
+
+	nd, err := u.getter.Get(ctx, c)
+	if err != nil {
+		res.Err = err
+		return res, nil
+	}
+	links := make([]cid.Cid, 0, len(nd.Links()))
+	for _, l := range nd.Links() {
+		links = append(links, l.Cid)
+	}
+
+	// Read again under the lock, the stub may have changed meanwhile.
+	si, err = blockstore.GetSealedInfo(u.dstore, c)
+	if err == blockstore.ErrNotFound || (err == nil && si == nil) {
+		return res, links
+	}
+	if err != nil {
+		res.Err = err
+		return res, links
+	}
+
+	data := nd.RawData()
+	if sum, err := c.Prefix().Sum(data); err != nil || !sum.Equals(c) {
+		res.Err = fmt.Errorf("read back data doesn't match %s", c)
+		return res, nil
+	}
+	if err := u.dstore.Put(blockstore.BlockPrefix.Child(dshelp.CidToDsKey(c)), spacex.EscapeRaw(data)); err != nil {
+		res.Err = err
+		return res, nil
+	}
+	res.Unsealed = true
+	res.Size = len(data)
+
+	for _, sb := range si.Sbs {
+		if err := spacex.ReleaseContext(ctx, u.backend, sb.Path); err != nil {
+			res.Err = fmt.Errorf("releasing %s: %s", sb.Path, err)
+			return res, links
+		}
+		if owner, ok := owners[sb.Path]; ok {
+			if err := u.journal.DropReplica(owner, sb.Path); err != nil {
+				res.Err = err
+				return res, links
+			}
+		}
+		res.Released++
+	}
+	return res, links
+}
+
+// finish releases the replicas left under root, which no stub points to
+// anymore, and forgets about root.
+func (u *Unsealer) finish(ctx context.Context, root cid.Cid) error {
+	paths, err := u.journal.Replicas(root)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := spacex.ReleaseContext(ctx, u.backend, path); err != nil {
+			return err
+		}
+	}
+	if err := u.journal.DropReplicas(root); err != nil {
+		return err
+	}
+	return u.dstore.Delete(unsealKey(root))
+}
@@ -0,0 +1,140 @@
+package dagutils
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	bsrv "github.com/ipfs/go-blockservice"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipld "github.com/ipfs/go-ipld-format"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
+
+	dag "github.com/ipfs/go-merkledag"
+)
+
+func TestUnsealer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	backend := spacex.NewLocalBackend()
+	bstore := blockstore.NewSealingBlockstore(dstore, backend, nil, 0)
+	dserv := dag.NewDAGService(bsrv.New(bstore, offline.Exchange(bstore)))
+
+	// a -> {b, c}, a and b sealed, c in plain.
+	a := dag.NodeWithData([]byte("a"))
+	b := dag.NodeWithData([]byte("b"))
+	c := dag.NewRawNode([]byte("c"))
+	if err := a.AddNodeLink("b", b); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddNodeLink("c", c); err != nil {
+		t.Fatal(err)
+	}
+	if err := dserv.AddMany(ctx, []ipld.Node{a, b, c}); err != nil {
+		t.Fatal(err)
+	}
+
+	root := a.Cid()
+	journal := spacex.NewSealJournal(dstore)
+	if _, err := backend.StartSeal(root); err != nil {
+		t.Fatal(err)
+	}
+	seal := func(nd ipld.Node) string {
+		t.Helper()
+		_, path, err := backend.Seal(root, false, nd.RawData())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := journal.SetStoreFlag(root, nd.Cid(), path); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	stub := func(nd ipld.Node, path string) {
+		t.Helper()
+		si := &spacex.SealedInfo{Sbs: []spacex.SealedBlock{{Path: path, Size: len(nd.RawData())}}}
+		if err := dstore.Put(blockstore.BlockPrefix.Child(dshelp.CidToDsKey(nd.Cid())), si.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stub(a, seal(a))
+	bpath := seal(b)
+	stub(b, bpath)
+	seal(c) // left behind by an earlier seal, no stub points to it
+	if err := journal.Finish(root); err != nil {
+		t.Fatal(err)
+	}
+
+	unsealer := NewUnsealer(dstore, dserv, backend, blockstore.NewGCLocker())
+	run := func() (unsealed, released int, failed bool) {
+		t.Helper()
+		results, err := unsealer.Unseal(ctx, root, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for res := range results {
+			if res.Err != nil {
+				failed = true
+			}
+			if res.Unsealed {
+				unsealed++
+			}
+			released += res.Released
+		}
+		return
+	}
+
+	// b lost its replica, the unseal stops short of the end.
+	data, _, _ := backend.Unseal(bpath)
+	if err := backend.Release(bpath); err != nil {
+		t.Fatal(err)
+	}
+	if unsealed, _, failed := run(); unsealed != 1 || !failed {
+		t.Fatalf("expected a to be unsealed and b to fail, got %d unsealed", unsealed)
+	}
+	pending, err := unsealer.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || !pending[0].Equals(root) {
+		t.Fatalf("expected %s to be pending, got %v", root, pending)
+	}
+
+	// Resuming once b is back unseals the rest.
+	if _, err := backend.StartSeal(root); err != nil {
+		t.Fatal(err)
+	}
+	_, bpath, err = backend.Seal(root, false, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub(b, bpath)
+	if unsealed, released, failed := run(); unsealed != 1 || released != 1 || failed {
+		t.Fatalf("unexpected resume: %d unsealed, %d released, failed: %t", unsealed, released, failed)
+	}
+
+	if backend.Replicas() != 0 {
+		t.Fatalf("expected all replicas released, %d left", backend.Replicas())
+	}
+	if paths, _ := journal.Replicas(root); len(paths) != 0 {
+		t.Fatalf("expected no replicas left in the journal, got %v", paths)
+	}
+	if pending, _ = unsealer.Pending(); len(pending) != 0 {
+		t.Fatalf("expected nothing pending, got %v", pending)
+	}
+	for _, nd := range []ipld.Node{a, b, c} {
+		value, err := dstore.Get(blockstore.BlockPrefix.Child(dshelp.CidToDsKey(nd.Cid())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(spacex.UnescapeRaw(value), nd.RawData()) {
+			t.Fatalf("expected %s to be stored in plain", nd.Cid())
+		}
+	}
+}
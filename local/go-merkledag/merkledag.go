@@ -6,12 +6,12 @@ import (
 	"fmt"
 	"sync"
 
-	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 	blocks "github.com/ipfs/go-block-format"
 	bserv "github.com/ipfs/go-blockservice"
 	cid "github.com/ipfs/go-cid"
 	ipldcbor "github.com/ipfs/go-ipld-cbor"
 	ipld "github.com/ipfs/go-ipld-format"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 )
 
 // TODO: We should move these registrations elsewhere. Really, most of the IPLD
@@ -167,6 +167,11 @@ func FetchGraph(ctx context.Context, root cid.Cid, serv ipld.DAGService) error {
 // maxDepth=1 means "fetch root and its direct children" and so on...
 // maxDepth=-1 means unlimited.
 func FetchGraphWithDepthLimit(ctx context.Context, root cid.Cid, depthLim int, serv ipld.DAGService) error {
+	// The fetched graph is kept: written to the blockstore, or sealed under
+	// a seal session, never only held by the fetch cache.
+	if spacex.StoragePolicyFromContext(ctx) == spacex.StoreDefault {
+		ctx = spacex.WithStoragePolicy(ctx, spacex.StorePlain)
+	}
 	var ng ipld.NodeGetter = NewSession(ctx, serv)
 
 	set := make(map[cid.Cid]int)
@@ -465,12 +470,8 @@ func parallelWalkDepth(ctx context.Context, getLinks GetLinks, root cid.Cid, vis
 
 	var visitlk sync.Mutex
 	var wg sync.WaitGroup
-	var inSealing bool = false
 
 	errChan := make(chan error)
-	if _, err := spacex.GetRootFromSealContext(ctx); err == nil {
-		inSealing = true
-	}
 	fetchersCtx, cancel := context.WithCancel(ctx)
 	defer wg.Wait()
 	defer cancel()
@@ -493,10 +494,6 @@ func parallelWalkDepth(ctx context.Context, getLinks GetLinks, root cid.Cid, vis
 					shouldVisit = true
 				}
 
-				if inSealing {
-					shouldVisit = true
-				}
-
 				if shouldVisit {
 					links, err := getLinks(ctx, ci)
 					if err != nil && options.ErrorHandler != nil {
@@ -74,6 +74,21 @@ var measureConfig = []byte(`{
           "type": "measure"
 }`)
 
+var sealedConfig = []byte(`{
+          "child": {
+            "child": {
+              "path": "blocks",
+              "shardFunc": "/repo/flatfs/shard/v1/next-to-last/2",
+              "sync": true,
+              "type": "flatfs"
+            },
+            "prefix": "flatfs.datastore",
+            "type": "measure"
+          },
+          "mountpoint": "/blocks",
+          "type": "sealed"
+}`)
+
 func TestDefaultDatastoreConfig(t *testing.T) {
 	loader, err := loader.NewPluginLoader("")
 	if err != nil {
@@ -234,3 +249,37 @@ func TestMeasureConfig(t *testing.T) {
 		t.Errorf("expected '*measure.measure' got '%s'", typ)
 	}
 }
+
+func TestSealedConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipfs-datastore-config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // clean up
+
+	spec := make(map[string]interface{})
+	err = json.Unmarshal(sealedConfig, &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dsc, err := fsrepo.AnyDatastoreConfig(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The stubs are stored by the child, the disk spec doesn't change.
+	expected := `{"path":"blocks","shardFunc":"/repo/flatfs/shard/v1/next-to-last/2","type":"flatfs"}`
+	if dsc.DiskSpec().String() != expected {
+		t.Errorf("expected '%s' got '%s' as DiskId", expected, dsc.DiskSpec().String())
+	}
+
+	ds, err := dsc.Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if typ := reflect.TypeOf(ds).String(); typ != "*spacex.SealedDatastore" {
+		t.Errorf("expected '*spacex.SealedDatastore' got '%s'", typ)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/ipfs/go-datastore/mount"
 	dssync "github.com/ipfs/go-datastore/sync"
 	"github.com/ipfs/go-ds-measure"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 )
 
 // ConfigFromMap creates a new datastore config from a map
@@ -61,6 +62,7 @@ func init() {
 		"mem":     MemDatastoreConfig,
 		"log":     LogDatastoreConfig,
 		"measure": MeasureDatastoreConfig,
+		"sealed":  SealedDatastoreConfig,
 	}
 }
 
@@ -246,3 +248,52 @@ func (c measureDatastoreConfig) Create(path string) (repo.Datastore, error) {
 	}
 	return measure.New(c.prefix, child), nil
 }
+
+type sealedDatastoreConfig struct {
+	child DatastoreConfig
+}
+
+// SealedDatastoreConfig returns a sealed DatastoreConfig from a spec. Its
+// child stores the stubs of the blocks sealed to the sWorker.
+func SealedDatastoreConfig(params map[string]interface{}) (DatastoreConfig, error) {
+	childField, ok := params["child"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("'child' field is missing or not a map")
+	}
+	child, err := AnyDatastoreConfig(childField)
+	if err != nil {
+		return nil, err
+	}
+	return &sealedDatastoreConfig{child}, nil
+}
+
+func (c *sealedDatastoreConfig) DiskSpec() DiskSpec {
+	return c.child.DiskSpec()
+}
+
+func (c *sealedDatastoreConfig) Create(path string) (repo.Datastore, error) {
+	child, err := c.child.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return spacex.NewSealedDatastore(child), nil
+}
+
+// hasSealedDatastore reports whether c declares a sealed datastore.
+func hasSealedDatastore(c DatastoreConfig) bool {
+	switch c := c.(type) {
+	case *sealedDatastoreConfig:
+		return true
+	case *mountDatastoreConfig:
+		for _, m := range c.mounts {
+			if hasSealedDatastore(m.ds) {
+				return true
+			}
+		}
+	case *logDatastoreConfig:
+		return hasSealedDatastore(c.child)
+	case *measureDatastoreConfig:
+		return hasSealedDatastore(c.child)
+	}
+	return false
+}
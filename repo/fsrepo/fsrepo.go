@@ -24,6 +24,7 @@ import (
 	serialize "github.com/ipfs/go-ipfs-config/serialize"
 	util "github.com/ipfs/go-ipfs-util"
 	logging "github.com/ipfs/go-log"
+	spacex "github.com/mannheim-network/go-ipfs-encryptor/spacex"
 	homedir "github.com/mitchellh/go-homedir"
 	ma "github.com/multiformats/go-multiaddr"
 )
@@ -443,6 +444,13 @@ func (r *FSRepo) openDatastore() error {
 	}
 	r.ds = d
 
+	// Specs written before the sealed datastore existed relied on the
+	// datastores themselves to store sealed blocks.
+	if !hasSealedDatastore(dsc) {
+		log.Info("Datastore.Spec declares no sealed datastore, storing sealed blocks in the whole datastore")
+		r.ds = spacex.NewSealedDatastore(r.ds)
+	}
+
 	// Wrap it with metrics gathering
 	prefix := "ipfs.fsrepo.datastore"
 	r.ds = measure.New(prefix, r.ds)